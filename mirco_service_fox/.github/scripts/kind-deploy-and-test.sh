@@ -19,11 +19,11 @@ kubectl apply -f https://raw.githubusercontent.com/rancher/local-path-provisione
 kubectl patch storageclass local-path -p '{"metadata": {"annotations":{"storageclass.kubernetes.io/is-default-class":"true"}}}'
 
 echo "[kind] Building images and loading into cluster"
-docker build -t gateway:latest ./gateway
-docker build -t experiment-service:latest ./experiment-service
-docker build -t user-service:latest ./user-service
-docker build -t notification-service:latest ./notification-service
-docker build -t submission-service:latest ./submission-service
+docker build -t gateway:latest -f gateway/Dockerfile .
+docker build -t experiment-service:latest -f experiment-service/Dockerfile .
+docker build -t user-service:latest -f user-service/Dockerfile .
+docker build -t notification-service:latest -f notification-service/Dockerfile .
+docker build -t submission-service:latest -f submission-service/Dockerfile .
 docker build -t judge-service:latest ./judge-service
 
 kind load docker-image gateway:latest --name "$CLUSTER_NAME"
@@ -33,6 +33,32 @@ kind load docker-image notification-service:latest --name "$CLUSTER_NAME"
 kind load docker-image submission-service:latest --name "$CLUSTER_NAME"
 kind load docker-image judge-service:latest --name "$CLUSTER_NAME"
 
+echo "[k8s] Creating secrets"
+# user-service 的令牌签名私钥，已存在时保留，避免重复运行时轮换密钥让已签发的令牌失效
+if ! kubectl get secret user-jwt-keys >/dev/null 2>&1; then
+  KEY_DIR=$(mktemp -d)
+  openssl genpkey -algorithm EC -pkeyopt ec_paramgen_curve:P-256 -out "$KEY_DIR/key-1.pem"
+  kubectl create secret generic user-jwt-keys --from-file="$KEY_DIR/key-1.pem"
+  rm -rf "$KEY_DIR"
+fi
+
+# 网关签发、各服务校验内部身份断言的共用密钥
+if ! kubectl get secret internal-identity >/dev/null 2>&1; then
+  kubectl create secret generic internal-identity --from-literal=secret="$(openssl rand -hex 32)"
+fi
+
+# 服务间调用 /internal 接口的令牌，每个调用方一个 key
+if ! kubectl get secret service-tokens >/dev/null 2>&1; then
+  kubectl create secret generic service-tokens \
+    --from-literal=gateway="$(openssl rand -hex 32)" \
+    --from-literal=experiment-service="$(openssl rand -hex 32)" \
+    --from-literal=notification-service="$(openssl rand -hex 32)" \
+    --from-literal=submission-service="$(openssl rand -hex 32)"
+fi
+
+echo "[k8s] Creating gateway route config"
+kubectl create configmap gateway-routes --from-file=gateway/gateway.yaml --dry-run=client -o yaml | kubectl apply -f -
+
 echo "[k8s] Applying manifests"
 kubectl apply -f user-service/k8s/
 kubectl apply -f experiment-service/k8s/
@@ -2,13 +2,14 @@ package config
 
 import (
 	"fmt"
-	"log"
 	"os"
 
 	"experiment-service/models"
+	"shared/logger"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	otelgorm "gorm.io/plugin/opentelemetry/tracing"
 )
 
 var DB *gorm.DB
@@ -23,7 +24,11 @@ func InitDB() {
 		dbUser, dbPassword, dbHost, dbPort, dbName)
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
 	if err != nil {
-		log.Fatalf("failed to connect database: %v", err)
+		logger.Log.WithError(err).Fatal("failed to connect database")
+	}
+	// 每条 SQL 记录一个 span，挂在请求的链路下（需要 db.WithContext）
+	if err := db.Use(otelgorm.NewPlugin(otelgorm.WithoutMetrics())); err != nil {
+		logger.Log.WithError(err).Warn("failed to register gorm tracing plugin")
 	}
 	db.AutoMigrate(&models.Experiment{}, &models.Attachment{}, &models.Question{}, &models.TestCase{})
 	DB = db
@@ -1,9 +1,10 @@
 package config
 
 import (
-	"log"
+	"shared/logger"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/sirupsen/logrus"
 )
 
 // OSS 配置 - 建议从环境变量或配置文件读取
@@ -34,29 +35,27 @@ func InitOSS() {
 	OssAccessKeyID = getEnv("OSS_ACCESS_KEY_ID", "LTAI5tQMwimSzeLg5g3Bhtz8")
 	OssAccessKeySecret = getEnv("OSS_ACCESS_KEY_SECRET", "oNInCEryrUNOMFcd9wgNDhpc54IXCP")
 	OssBucketName = getEnv("OSS_BUCKET_NAME", "wechat921")
-	// +++ 添加这些日志打印 +++
-	log.Println("--- OSS Configuration ---")
-	log.Printf("Read OSS_ENDPOINT: [%s]", OssEndpoint)
-	log.Printf("Read OSS_BUCKET_NAME: [%s]", OssBucketName)
 	// 对于 AccessKey ID 和 Secret，请谨慎打印，确保不在生产环境日志中暴露
-	// log.Printf("Read OSS_ACCESS_KEY_ID: [%s]", ossAccessKeyID)
-	log.Println("-------------------------")
+	logger.Log.WithFields(logrus.Fields{
+		"endpoint": OssEndpoint,
+		"bucket":   OssBucketName,
+	}).Info("OSS configuration loaded")
 	// 简单的校验
 	if OssEndpoint == "" || OssAccessKeyID == "" || OssAccessKeySecret == "" || OssBucketName == "" {
-		log.Fatal("OSS_ENDPOINT, OSS_ACCESS_KEY_ID, OSS_ACCESS_KEY_SECRET, and OSS_BUCKET_NAME environment variables must be set.")
+		logger.Log.Fatal("OSS_ENDPOINT, OSS_ACCESS_KEY_ID, OSS_ACCESS_KEY_SECRET, and OSS_BUCKET_NAME environment variables must be set.")
 	}
 
 	var err error
 	// 创建OSSClient实例。
 	OssClient, err = oss.New(OssEndpoint, OssAccessKeyID, OssAccessKeySecret)
 	if err != nil {
-		log.Fatalf("Failed to create OSS client: %v", err)
+		logger.Log.WithError(err).Fatal("Failed to create OSS client")
 	}
 
 	// 获取存储空间。
 	Bucket, err = OssClient.Bucket(OssBucketName)
 	if err != nil {
-		log.Fatalf("Failed to get OSS bucket: %v", err)
+		logger.Log.WithError(err).Fatal("Failed to get OSS bucket")
 	}
-	log.Println("OSS client initialized successfully.")
+	logger.Log.Info("OSS client initialized successfully.")
 }
@@ -2,18 +2,22 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"experiment-service/config"
 	"fmt"
 	"net/http"
+	"shared/identity"
+	"shared/logger"
+	"shared/tracing"
 	"time"
 )
 
-// callNotificationService 调用通知服务的API
-func callNotificationService(notificationData map[string]interface{}) error {
+// callNotificationService 调用通知服务的内部接口创建通知
+func callNotificationService(ctx context.Context, notificationData map[string]interface{}) error {
 	// 通知服务的URL - 你需要根据实际部署情况修改这个URL
 	cfg := config.LoadConfig()
-	notificationServiceURL := fmt.Sprintf("%s/api/teacher/experiments/notifications", cfg.NotificationServiceURL)
+	notificationServiceURL := fmt.Sprintf("%s/internal/notifications", cfg.NotificationServiceURL)
 	// 序列化请求数据
 	jsonData, err := json.Marshal(notificationData)
 	if err != nil {
@@ -21,17 +25,19 @@ func callNotificationService(notificationData map[string]interface{}) error {
 	}
 
 	// 创建HTTP请求
-	req, err := http.NewRequest("POST", notificationServiceURL, bytes.NewBuffer(jsonData))
+	req, err := logger.NewRequest(ctx, http.MethodPost, notificationServiceURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create HTTP request: %v", err)
 	}
 
 	// 设置请求头
 	req.Header.Set("Content-Type", "application/json")
+	identity.SetServiceCredentials(req)
 
 	// 发送请求
 	client := &http.Client{
-		Timeout: 10 * time.Second, // 设置超时时间
+		Timeout:   10 * time.Second,                         // 设置超时时间
+		Transport: tracing.Transport(http.DefaultTransport), // 记录调用链路并传递 traceparent
 	}
 
 	resp, err := client.Do(req)
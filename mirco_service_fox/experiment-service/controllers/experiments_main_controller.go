@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"experiment-service/config"
+	"experiment-service/metrics"
 	"experiment-service/models"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"shared/identity"
+	"shared/logger"
 	"strconv"
 	"strings"
 	"time"
@@ -20,33 +23,22 @@ import (
 )
 
 func GetExperiments_Student(c *gin.Context) {
-	db := config.DB
+	db := config.DB.WithContext(c.Request.Context())
 	// 解析分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	status := c.DefaultQuery("status", "all")
 
 	offset := (page - 1) * limit
-	// 从请求头获取用户ID（微服务架构）
-	studentIDStr := c.GetHeader("X-User-ID")
-	if studentIDStr == "" {
-		studentIDStr = c.Query("user_id") // 也支持查询参数
-	}
-	if studentIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
+	// 用户ID来自网关签发、已校验的身份断言
+	studentID := int(identity.UserID(c))
+	if studentID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  "error",
 			"message": "User ID is required",
 		})
 		return
 	}
-	studentID, err := strconv.Atoi(studentIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": "Invalid user ID",
-		})
-		return
-	}
 	now := time.Now()
 
 	// 查询分配给该学生的实验
@@ -87,7 +79,7 @@ func GetExperiments_Student(c *gin.Context) {
 		// 	First(&submission).Error; err == nil {
 		// 	submissionStatus = strings.ToLower(submission.Status)
 		// }
-		submissionStatus := GetStudentSubmission(exp.ID, uint(studentID)).Status
+		submissionStatus := GetStudentSubmission(c.Request.Context(), exp.ID, uint(studentID)).Status
 
 		// 确定实验状态
 		expStatus := "active"
@@ -120,27 +112,16 @@ func GetExperiments_Student(c *gin.Context) {
 // GetExperiment 获取实验详细信息
 func GetExperimentDetail_Student(c *gin.Context) {
 	// 获取实验 ID
-	db := config.DB
-	// 从请求头获取用户ID（微服务架构）
-	studentIDStr := c.GetHeader("X-User-ID")
-	if studentIDStr == "" {
-		studentIDStr = c.Query("user_id") // 也支持查询参数
-	}
-	if studentIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
+	db := config.DB.WithContext(c.Request.Context())
+	// 用户ID来自网关签发、已校验的身份断言
+	studentID := int(identity.UserID(c))
+	if studentID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  "error",
 			"message": "User ID is required",
 		})
 		return
 	}
-	studentID, err := strconv.Atoi(studentIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": "Invalid user ID",
-		})
-		return
-	}
 	experimentID := c.Param("experiment_id")
 	var experiment models.Experiment
 	// 查询实验详情，包括关联的阶段和资源
@@ -153,7 +134,7 @@ func GetExperimentDetail_Student(c *gin.Context) {
 	}
 
 	// 获取学生提交记录
-	var submission = GetStudentSubmission(experimentID, uint(studentID))
+	var submission = GetStudentSubmission(c.Request.Context(), experimentID, uint(studentID))
 	submissionStatus := "not_started"
 	totalScore := 0
 
@@ -184,7 +165,7 @@ func GetExperimentDetail_Student(c *gin.Context) {
 			questionData["explanation"] = q.Explanation
 		}
 		// 获取学生答案和反馈
-		var qSubmission = GetStudentQuestionSubmission(submission.ID, q.ID)
+		var qSubmission = GetStudentQuestionSubmission(c.Request.Context(), submission.ID, q.ID)
 
 		if qSubmission != nil {
 			if q.Type == "code" {
@@ -234,43 +215,45 @@ func GetExperimentDetail_Student(c *gin.Context) {
 	})
 }
 
+// QuestionInput 题目输入结构体
+type QuestionInput struct {
+	Type          string            `json:"type" binding:"required,oneof=choice blank code"`
+	Content       string            `json:"content" binding:"required"`
+	Options       []string          `json:"options" binding:"required_if=Type choice"`
+	CorrectAnswer string            `json:"correct_answer" binding:"required_if=Type choice required_if=Type blank"`
+	Score         int               `json:"score" binding:"required,gt=0"`
+	ImageURL      string            `json:"image_url" binding:"omitempty"`
+	Explanation   string            `json:"explanation" binding:"omitempty"`
+	TestCases     []models.TestCase `json:"test_cases" binding:"required_if=Type code"`
+}
+
+// CreateExperimentRequest 请求结构体
+type CreateExperimentRequest struct {
+	Title       string          `json:"title" binding:"required"`
+	Description string          `json:"description"`
+	Permission  *int            `json:"permission" binding:"required,oneof=1 0"`
+	Deadline    time.Time       `json:"deadline" binding:"required"`
+	StudentIDs  []int           `json:"student_ids" binding:"required"`
+	Questions   []QuestionInput `json:"questions" binding:"required,dive"`
+}
+
+// ExperimentResponseData 响应数据
+type ExperimentResponseData struct {
+	ExperimentID string    `json:"experiment_id"`
+	Title        string    `json:"title"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CreateExperimentResponse 响应结构体
+type CreateExperimentResponse struct {
+	Status  string                 `json:"status"`
+	Data    ExperimentResponseData `json:"data,omitempty"`
+	Message string                 `json:"message,omitempty"`
+}
+
 // CreateExperiment 创建实验
 func CreateExperiment(c *gin.Context) {
-
-	// QuestionInput 题目输入结构体
-	type QuestionInput struct {
-		Type          string            `json:"type" binding:"required,oneof=choice blank code"`
-		Content       string            `json:"content" binding:"required"`
-		Options       []string          `json:"options" binding:"required_if=Type choice"`
-		CorrectAnswer string            `json:"correct_answer" binding:"required_if=Type choice required_if=Type blank"`
-		Score         int               `json:"score" binding:"required,gt=0"`
-		ImageURL      string            `json:"image_url" binding:"omitempty"`
-		Explanation   string            `json:"explanation" binding:"omitempty"`
-		TestCases     []models.TestCase `json:"test_cases" binding:"required_if=Type code"`
-	}
-	// CreateExperimentRequest 请求结构体
-	type CreateExperimentRequest struct {
-		Title       string          `json:"title" binding:"required"`
-		Description string          `json:"description"`
-		Permission  *int            `json:"permission" binding:"required,oneof=1 0"`
-		Deadline    time.Time       `json:"deadline" binding:"required"`
-		StudentIDs  []int           `json:"student_ids" binding:"required"`
-		Questions   []QuestionInput `json:"questions" binding:"required,dive"`
-	}
-	// ExperimentResponseData 响应数据
-	type ExperimentResponseData struct {
-		ExperimentID string    `json:"experiment_id"`
-		Title        string    `json:"title"`
-		CreatedAt    time.Time `json:"created_at"`
-	}
-	// CreateExperimentResponse 响应结构体
-	type CreateExperimentResponse struct {
-		Status  string                 `json:"status"`
-		Data    ExperimentResponseData `json:"data,omitempty"`
-		Message string                 `json:"message,omitempty"`
-	}
-
-	db := config.DB
+	db := config.DB.WithContext(c.Request.Context())
 	var req CreateExperimentRequest
 	if err := c.ShouldBind(&req); err != nil {
 		c.JSON(http.StatusBadRequest, CreateExperimentResponse{
@@ -377,6 +360,8 @@ func CreateExperiment(c *gin.Context) {
 		return
 	}
 
+	metrics.ExperimentsCreated.Inc()
+
 	//下发通知
 	notificationRequest := map[string]interface{}{
 		"title":         fmt.Sprintf("新实验发布：%s", experiment.Title),
@@ -387,8 +372,8 @@ func CreateExperiment(c *gin.Context) {
 	}
 
 	//调用通知接口
-	if err := callNotificationService(notificationRequest); err != nil {
-		fmt.Printf("创建通知失败: %v\n", err)
+	if err := callNotificationService(c.Request.Context(), notificationRequest); err != nil {
+		logger.FromContext(c.Request.Context()).WithError(err).WithField("experiment_id", experiment.ID).Error("创建通知失败")
 	}
 
 	// 返回成功响应
@@ -403,36 +388,41 @@ func CreateExperiment(c *gin.Context) {
 
 }
 
+// UpdateQuestionInput 修改或新增的题目，带 question_id 的修改已有题目
+type UpdateQuestionInput struct {
+	QuestionID    string            `json:"question_id" binding:"omitempty,required_if=Type ''"`
+	Type          string            `json:"type" binding:"omitempty,oneof=choice blank code"`
+	Content       string            `json:"content" binding:"omitempty,min=1"`
+	Options       []string          `json:"options" binding:"omitempty,required_if=Type choice"`
+	CorrectAnswer string            `json:"correct_answer" binding:"omitempty,required_if=Type choice required_if=Type blank"`
+	Score         int               `json:"score" binding:"omitempty,gt=0"`
+	ImageURL      string            `json:"image_url" binding:"omitempty"`
+	Explanation   string            `json:"explanation" binding:"omitempty"`
+	TestCases     []models.TestCase `json:"test_cases" binding:"omitempty,required_if=Type code"`
+}
+
+// UpdateExperimentRequest 请求结构体，只修改传了的字段
+type UpdateExperimentRequest struct {
+	Title           string                `json:"title" binding:"omitempty,min=1"`
+	Description     string                `json:"description" binding:"omitempty"`
+	Deadline        time.Time             `json:"deadline" binding:"omitempty"`
+	Questions       []UpdateQuestionInput `json:"questions" binding:"omitempty,dive"`
+	RemoveQuestions []string              `json:"remove_questions" binding:"omitempty"`
+	Permission      *int                  `json:"permission" binding:"omitempty,oneof=0 1"`
+}
+
+// UpdateExperimentResponse 响应结构体
+type UpdateExperimentResponse struct {
+	Status       string    `json:"status"`
+	ExperimentID string    `json:"experiment_id,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+	Message      string    `json:"message,omitempty"`
+}
+
 // UpdateExperiment 更新实验
 func UpdateExperiment(c *gin.Context) {
-	type UpdateQuestionInput struct {
-		QuestionID    string            `json:"question_id" binding:"omitempty,required_if=Type ''"`
-		Type          string            `json:"type" binding:"omitempty,oneof=choice blank code"`
-		Content       string            `json:"content" binding:"omitempty,min=1"`
-		Options       []string          `json:"options" binding:"omitempty,required_if=Type choice"`
-		CorrectAnswer string            `json:"correct_answer" binding:"omitempty,required_if=Type choice required_if=Type blank"`
-		Score         int               `json:"score" binding:"omitempty,gt=0"`
-		ImageURL      string            `json:"image_url" binding:"omitempty"`
-		Explanation   string            `json:"explanation" binding:"omitempty"`
-		TestCases     []models.TestCase `json:"test_cases" binding:"omitempty,required_if=Type code"`
-	}
-	type UpdateExperimentRequest struct {
-		Title           string                `json:"title" binding:"omitempty,min=1"`
-		Description     string                `json:"description" binding:"omitempty"`
-		Deadline        time.Time             `json:"deadline" binding:"omitempty"`
-		Questions       []UpdateQuestionInput `json:"questions" binding:"omitempty,dive"`
-		RemoveQuestions []string              `json:"remove_questions" binding:"omitempty"`
-		Permission      *int                  `json:"permission" binding:"omitempty,oneof=0 1"`
-	}
-	type UpdateExperimentResponse struct {
-		Status       string    `json:"status"`
-		ExperimentID string    `json:"experiment_id,omitempty"`
-		Title        string    `json:"title,omitempty"`
-		UpdatedAt    time.Time `json:"updated_at,omitempty"`
-		Message      string    `json:"message,omitempty"`
-	}
-
-	db := config.DB
+	db := config.DB.WithContext(c.Request.Context())
 	experimentID := c.Param("experiment_id")
 
 	// 检查实验是否存在
@@ -647,7 +637,7 @@ func UpdateExperiment(c *gin.Context) {
 		return nil
 	})
 	//更新experiment_submissions表中对应实验状态为in_progress
-	UpdateSubmissionsInProgress(experimentID)
+	UpdateSubmissionsInProgress(c.Request.Context(), experimentID)
 	// db.Model(&models.ExperimentSubmission{}).Where("experiment_id = ?", experimentID).Update("status", "in_progress")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, UpdateExperimentResponse{
@@ -667,11 +657,11 @@ func UpdateExperiment(c *gin.Context) {
 
 // DeleteExperiment 删除实验及关联数据
 func DeleteExperiment(c *gin.Context) {
-	db := config.DB
+	db := config.DB.WithContext(c.Request.Context())
 
 	// 需要验证嘛？
 	// 验证用户角色
-	userRole := c.GetHeader("X-User-Role")
+	userRole := identity.Role(c)
 	if userRole != "teacher" {
 		c.JSON(http.StatusForbidden, gin.H{
 			"status":  "error",
@@ -703,7 +693,7 @@ func DeleteExperiment(c *gin.Context) {
 	tx := db.Begin()
 
 	// 1. 调用submission-service删除关联的提交记录
-	if err := callSubmissionService(experimentID); err != nil {
+	if err := callSubmissionService(c.Request.Context(), experimentID); err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
@@ -743,6 +733,7 @@ func DeleteExperiment(c *gin.Context) {
 	}
 
 	tx.Commit()
+	metrics.ExperimentsDeleted.Inc()
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"experiment-service/config"
@@ -8,21 +10,37 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
+	"shared/identity"
+	"shared/logger"
+	"shared/tracing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
-func GetStudentSubmission(experimentId string, studentId uint) *models.ExperimentSubmission {
+// serviceClient 调用其他服务时使用，带链路追踪
+var serviceClient = tracing.Client(10 * time.Second)
+
+func GetStudentSubmission(ctx context.Context, experimentId string, studentId uint) *models.ExperimentSubmission {
 	cfg := config.LoadConfig()
 	submission := &models.ExperimentSubmission{}
-	submissionServiceURL := fmt.Sprintf("%s/api/student/submissions/%s/%d/status", cfg.SubmissionServiceURL, experimentId, studentId)
+	submissionServiceURL := fmt.Sprintf("%s/internal/submissions/%s/%d/status", cfg.SubmissionServiceURL, experimentId, studentId)
 
-	resp, err := http.Get(submissionServiceURL)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		defer resp.Body.Close()
+	req, err := logger.NewRequest(ctx, http.MethodGet, submissionServiceURL, nil)
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("创建HTTP请求失败")
+		return submission
+	}
+	identity.SetServiceCredentials(req)
+	resp, err := serviceClient.Do(req)
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("获取提交状态失败")
+		return submission
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		var result struct {
 			Status string                      `json:"status"`
@@ -41,9 +59,9 @@ type GSQSRequest struct {
 	QId   string `json:"question_id" bingding:"required"`
 }
 
-func GetStudentQuestionSubmission(submissionId string, qId string) *models.QuestionSubmission {
+func GetStudentQuestionSubmission(ctx context.Context, submissionId string, qId string) *models.QuestionSubmission {
 	cfg := config.LoadConfig()
-	submissionServiceURL := fmt.Sprintf("%s/api/student/submissions/%s/GetStudentAns", cfg.SubmissionServiceURL, submissionId)
+	submissionServiceURL := fmt.Sprintf("%s/internal/submissions/%s/GetStudentAns", cfg.SubmissionServiceURL, submissionId)
 
 	// 构建请求数据
 	requestData := GSQSRequest{
@@ -51,31 +69,40 @@ func GetStudentQuestionSubmission(submissionId string, qId string) *models.Quest
 		QId:   qId,
 	}
 
+	log := logger.FromContext(ctx).WithField("submission_id", submissionId)
+
 	// 序列化请求数据
 	jsonData, err := json.Marshal(requestData)
 	if err != nil {
-		fmt.Printf("序列化请求数据失败: %v\n", err)
+		log.WithError(err).Error("序列化请求数据失败")
 		return nil
 	}
 
 	// 创建POST请求
-	resp, err := http.Post(submissionServiceURL, "application/json", strings.NewReader(string(jsonData)))
+	req, err := logger.NewRequest(ctx, http.MethodPost, submissionServiceURL, bytes.NewReader(jsonData))
+	if err != nil {
+		log.WithError(err).Error("创建HTTP请求失败")
+		return nil
+	}
+	identity.SetServiceCredentials(req)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := serviceClient.Do(req)
 	if err != nil {
-		fmt.Printf("发送HTTP请求失败: %v\n", err)
+		log.WithError(err).Error("发送HTTP请求失败")
 		return nil
 	}
 	defer resp.Body.Close()
 
 	// 检查状态码
 	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("请求失败，状态码: %d\n", resp.StatusCode)
+		log.WithField("status_code", resp.StatusCode).Warn("请求失败")
 		return nil
 	}
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("读取响应失败: %v\n", err)
+		log.WithError(err).Error("读取响应失败")
 		return nil
 	}
 
@@ -86,39 +113,43 @@ func GetStudentQuestionSubmission(submissionId string, qId string) *models.Quest
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		fmt.Printf("解析响应失败: %v\n", err)
+		log.WithError(err).Error("解析响应失败")
 		return nil
 	}
 
 	if result.Status != "success" {
-		fmt.Printf("接口返回错误状态: %s\n", result.Status)
+		log.WithField("result_status", result.Status).Warn("接口返回错误状态")
 		return nil
 	}
 
 	return &result.Data
 }
-func UpdateSubmissionsInProgress(experimentId string) {
+func UpdateSubmissionsInProgress(ctx context.Context, experimentId string) {
 	cfg := config.LoadConfig()
-	submissionServiceURL := fmt.Sprintf("%s/api/student/submissions/%s/UpdateExperimentStatus", cfg.SubmissionServiceURL, experimentId)
+	submissionServiceURL := fmt.Sprintf("%s/internal/submissions/%s/UpdateExperimentStatus", cfg.SubmissionServiceURL, experimentId)
+
+	log := logger.FromContext(ctx).WithField("experiment_id", experimentId)
 
 	// 创建HTTP请求
-	req, err := http.NewRequest("PUT", submissionServiceURL, nil)
+	req, err := logger.NewRequest(ctx, http.MethodPut, submissionServiceURL, nil)
 	if err != nil {
-		fmt.Printf("创建HTTP请求失败: %v\n", err)
+		log.WithError(err).Error("创建HTTP请求失败")
 		return
 	}
+	identity.SetServiceCredentials(req)
 
 	// 设置请求头
 	req.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
 	client := &http.Client{
-		Timeout: 10 * time.Second, // 设置超时时间
+		Timeout:   10 * time.Second,                         // 设置超时时间
+		Transport: tracing.Transport(http.DefaultTransport), // 记录调用链路并传递 traceparent
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		fmt.Printf("发送HTTP请求失败: %v\n", err)
+		log.WithError(err).Error("发送HTTP请求失败")
 		return
 	}
 	defer resp.Body.Close()
@@ -126,21 +157,21 @@ func UpdateSubmissionsInProgress(experimentId string) {
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("读取响应失败: %v\n", err)
+		log.WithError(err).Error("读取响应失败")
 		return
 	}
 
 	// 检查响应状态
 	if resp.StatusCode == http.StatusOK {
-		fmt.Printf("实验状态更新成功: %s\n", string(body))
+		log.Info("实验状态更新成功")
 	} else {
-		fmt.Printf("实验状态更新失败，状态码: %d, 响应: %s\n", resp.StatusCode, string(body))
+		log.WithFields(logrus.Fields{"status_code": resp.StatusCode, "response": string(body)}).Error("实验状态更新失败")
 	}
 }
 
 func GetExperimentById(c *gin.Context) {
 	experimentID := c.Param("experiment_id")
-	db := config.DB
+	db := config.DB.WithContext(c.Request.Context())
 	tx := db.Begin()
 	//验证试验是否存在
 	var experiment models.Experiment
@@ -173,7 +204,7 @@ func ValidQuestion(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Invalid request"})
 		return
 	}
-	db := config.DB
+	db := config.DB.WithContext(c.Request.Context())
 	tx := db.Begin()
 
 	var validQuestions []models.Question
@@ -210,7 +241,7 @@ func GetQuestionDetail(c *gin.Context) {
 		return
 	}
 
-	db := config.DB
+	db := config.DB.WithContext(c.Request.Context())
 	var question models.Question
 	if err := db.Where("id = ?", req.QuestionID).First(&question).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "Question not found"})
@@ -242,7 +273,7 @@ func GetExperimentDetail(c *gin.Context) {
 		return
 	}
 
-	db := config.DB
+	db := config.DB.WithContext(c.Request.Context())
 	var experiment models.Experiment
 	if err := db.Preload("Questions").First(&experiment, "id = ?", req.ExperimentID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "Experiment not found"})
@@ -266,22 +297,24 @@ func GetExperimentDetail(c *gin.Context) {
 }
 
 // callSubmissionService 调用提交服务的API删除实验相关提交记录
-func callSubmissionService(experimentID string) error {
+func callSubmissionService(ctx context.Context, experimentID string) error {
 	cfg := config.LoadConfig()
-	submissionServiceURL := fmt.Sprintf("%s/api/student/experiments/%s/submissions", cfg.SubmissionServiceURL, experimentID)
+	submissionServiceURL := fmt.Sprintf("%s/internal/experiments/%s/submissions", cfg.SubmissionServiceURL, experimentID)
 
 	// 创建HTTP请求
-	req, err := http.NewRequest("DELETE", submissionServiceURL, nil)
+	req, err := logger.NewRequest(ctx, http.MethodDelete, submissionServiceURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create HTTP request: %v", err)
 	}
+	identity.SetServiceCredentials(req)
 
 	// 设置请求头
 	req.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
 	client := &http.Client{
-		Timeout: 10 * time.Second, // 设置超时时间
+		Timeout:   10 * time.Second,                         // 设置超时时间
+		Transport: tracing.Transport(http.DefaultTransport), // 记录调用链路并传递 traceparent
 	}
 
 	resp, err := client.Do(req)
@@ -2,10 +2,21 @@ package controllers
 
 import (
 	"net/http"
+	"shared/server"
 
 	"github.com/gin-gonic/gin"
 )
 
+// HealthCheck 就绪检查，正在退出时返回 503，让负载均衡和网关摘掉本实例
 func HealthCheck(c *gin.Context) {
+	if server.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
+
+// LiveCheck 存活检查，退出过程中也返回 200，避免被 k8s 提前杀掉
+func LiveCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
@@ -1,15 +1,41 @@
 package main
 
 import (
+	"context"
 	"experiment-service/config"
 	"experiment-service/routers"
+	"net/http"
 	"os"
+	"shared/identity"
+	"shared/logger"
+	"shared/server"
+	"shared/tracing"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	r := gin.Default()
+	logger.Init("experiment-service")
+	// 初始化链路追踪
+	shutdownTracing, err := tracing.Init(context.Background(), "experiment-service")
+	if err != nil {
+		logger.Log.WithError(err).Fatal("Failed to init tracing")
+	}
+	defer shutdownTracing(context.Background())
+	// 内部身份断言的签名密钥，没有时无法识别用户，直接退出
+	if err := identity.Init(); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to init identity verification")
+	}
+	// 调用提交服务内部接口的令牌，以及允许调用本服务内部接口的提交服务令牌
+	if err := identity.InitServiceToken("experiment-service"); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to init service token")
+	}
+	if err := identity.AllowCallers("submission-service"); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to init service token")
+	}
+
+	r := gin.New()
+	r.Use(gin.Recovery(), tracing.Middleware("experiment-service"), logger.Middleware(), identity.Middleware())
 
 	routers.RegisterRoutes(r)
 
@@ -19,5 +45,18 @@ func main() {
 		config.InitOSS()
 	}
 
-	r.Run(":8082")
+	// 收到 SIGTERM 后等正在处理的请求结束，再关闭数据库连接池
+	srv := &http.Server{Addr: ":8082", Handler: r}
+	if err := server.Run(srv, server.OptionsFromEnv(), closeDB); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to start server")
+	}
+}
+
+// closeDB 关闭数据库连接池
+func closeDB() error {
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
@@ -0,0 +1,20 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// ExperimentsCreated 创建的实验数
+	ExperimentsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "experiments_created_total",
+		Help: "Number of experiments created.",
+	})
+
+	// ExperimentsDeleted 删除的实验数
+	ExperimentsDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "experiments_deleted_total",
+		Help: "Number of experiments deleted.",
+	})
+)
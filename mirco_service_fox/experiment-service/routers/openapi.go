@@ -0,0 +1,141 @@
+package routers
+
+import (
+	"experiment-service/controllers"
+	"mime/multipart"
+	"net/http"
+	"shared/openapi"
+)
+
+// 下面的结构体只用于生成文档，描述接口中用 gin.H 拼出的请求参数和响应
+
+type pagination struct {
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"`
+	Total int64 `json:"total"`
+}
+
+type studentExperimentQuery struct {
+	Page   int    `form:"page,default=1"`
+	Limit  int    `form:"limit,default=10"`
+	Status string `form:"status,default=all" doc:"all | active（未截止）| expired（已截止）"`
+}
+
+type studentExperiment struct {
+	ExperimentID     string `json:"experiment_id"`
+	Title            string `json:"title"`
+	Description      string `json:"description"`
+	Deadline         string `json:"deadline" doc:"RFC3339 时间"`
+	Status           string `json:"status" doc:"active | expired"`
+	SubmissionStatus string `json:"submission_status"`
+}
+
+type studentExperimentList struct {
+	Status     string              `json:"status"`
+	Data       []studentExperiment `json:"data"`
+	Pagination pagination          `json:"pagination"`
+}
+
+type studentQuestion struct {
+	QuestionID      string   `json:"question_id"`
+	Type            string   `json:"type" doc:"choice | blank | code"`
+	Content         string   `json:"content"`
+	Score           int      `json:"score"`
+	ImageURL        string   `json:"image_url"`
+	Options         []string `json:"options,omitempty" doc:"选择题的选项"`
+	CorrectAnswer   string   `json:"correct_answer,omitempty" doc:"截止后返回，代码题没有"`
+	Explanation     string   `json:"explanation,omitempty" doc:"截止后返回"`
+	StudentAnswer   string   `json:"student_answer,omitempty" doc:"选择题、填空题的答案"`
+	StudentCode     string   `json:"student_code,omitempty" doc:"代码题的代码"`
+	StudentLanguage string   `json:"student_language,omitempty"`
+	Feedback        string   `json:"feedback"`
+}
+
+type studentAttachment struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type studentExperimentDetail struct {
+	ExperimentID     string              `json:"experiment_id"`
+	Permission       int                 `json:"permission"`
+	Title            string              `json:"title"`
+	Description      string              `json:"description"`
+	Deadline         string              `json:"deadline" doc:"RFC3339 时间"`
+	Questions        []studentQuestion   `json:"questions"`
+	Attachments      []studentAttachment `json:"attachments"`
+	SubmissionStatus string              `json:"submission_status"`
+	TotalScore       int                 `json:"total_score"`
+}
+
+type studentExperimentDetailResponse struct {
+	Status string                  `json:"status"`
+	Data   studentExperimentDetail `json:"data"`
+}
+
+type statusMessage struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type uploadFileForm struct {
+	File *multipart.FileHeader `json:"file" binding:"required"`
+}
+
+type uploadFileResponse struct {
+	Message      string `json:"message"`
+	ExperimentID string `json:"experimentId"`
+	Filename     string `json:"filename"`
+	ObjectKey    string `json:"objectKey"`
+}
+
+type fileList struct {
+	ExperimentID string   `json:"experimentId"`
+	Files        []string `json:"files"`
+}
+
+// Spec 生成本服务对外接口的 OpenAPI 文档，修改接口时同步更新
+func Spec() *openapi.Spec {
+	spec := openapi.New("experiment-service", "1.0.0")
+	params := map[string]string{"experiment_id": "实验 ID", "filename": "文件名"}
+
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/student/experiments", Tag: "student",
+		Summary: "学生的实验列表", Query: studentExperimentQuery{}, Response: studentExperimentList{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/student/experiments/:experiment_id", Tag: "student",
+		Summary: "实验详情", Description: "包含题目、附件和学生已保存的答案，截止后返回标准答案和解析",
+		Params: params, Response: studentExperimentDetailResponse{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodPost, Path: "/api/teacher/experiments", Tag: "teacher",
+		Summary: "创建实验", Body: controllers.CreateExperimentRequest{},
+		Status: http.StatusCreated, Response: controllers.CreateExperimentResponse{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodPut, Path: "/api/teacher/experiments/:experiment_id", Tag: "teacher",
+		Summary: "修改实验", Description: "只修改传了的字段，questions 中带 question_id 的修改已有题目，不带的新增题目",
+		Params: params, Body: controllers.UpdateExperimentRequest{}, Response: controllers.UpdateExperimentResponse{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodDelete, Path: "/api/teacher/experiments/:experiment_id", Tag: "teacher",
+		Summary: "删除实验", Params: params, Response: statusMessage{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodPost, Path: "/api/teacher/experiments/:experiment_id/uploadFile", Tag: "teacher",
+		Summary: "上传实验附件", Params: params,
+		Body: uploadFileForm{}, BodyType: "multipart/form-data", Response: uploadFileResponse{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/experiments/:experiment_id/files", Tag: "files",
+		Summary: "实验附件列表", Params: params, Response: fileList{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/experiments/:experiment_id/files/:filename/download", Tag: "files",
+		Summary: "下载实验附件", Description: "重定向到带签名的下载地址",
+		Params: params, Status: http.StatusFound,
+	})
+	return spec
+}
@@ -2,13 +2,22 @@ package routers
 
 import (
 	"experiment-service/controllers"
+	"shared/identity"
+	"shared/logger"
+	"shared/metrics"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterRoutes(r *gin.Engine) {
+	r.Use(metrics.Middleware())
 	// Health endpoint for k8s probes
 	r.GET("/health", controllers.HealthCheck)
+	r.GET("/live", controllers.LiveCheck)
+	r.GET("/metrics", metrics.Handler())
+	// 接口文档，网关合并各服务的文档后对外提供
+	spec := Spec()
+	r.GET("/openapi.json", spec.Handler())
 	student := r.Group("/api/student")
 	{
 
@@ -16,7 +25,7 @@ func RegisterRoutes(r *gin.Engine) {
 		student.GET("/experiments/:experiment_id", controllers.GetExperimentDetail_Student)
 	}
 
-	teacher := r.Group("/api/teacher")
+	teacher := r.Group("/api/teacher", identity.RequireRole("teacher"))
 	{
 		teacher.POST("/experiments", controllers.CreateExperiment)
 		teacher.PUT("/experiments/:experiment_id", controllers.UpdateExperiment)
@@ -27,11 +36,16 @@ func RegisterRoutes(r *gin.Engine) {
 	r.GET("/api/experiments/:experiment_id/files", controllers.HandleStudentListFiles)
 	r.GET("/api/experiments/:experiment_id/files/:filename/download", controllers.HandleStudentDownloadFile)
 
-	external := r.Group("/api/experiments")
+	// 提交服务调用的内部接口（questionDetail 含标准答案），网关不对外暴露
+	internal := r.Group("/internal/experiments", identity.RequireService())
 	{
-		external.GET("/getById/:experiment_id", controllers.GetExperimentById)
-		external.POST("/validQuestion", controllers.ValidQuestion)
-		external.POST("/questionDetail", controllers.GetQuestionDetail)
-		external.POST("/experimentDetail", controllers.GetExperimentDetail)
+		internal.GET("/getById/:experiment_id", controllers.GetExperimentById)
+		internal.POST("/validQuestion", controllers.ValidQuestion)
+		internal.POST("/questionDetail", controllers.GetQuestionDetail)
+		internal.POST("/experimentDetail", controllers.GetExperimentDetail)
+	}
+
+	for _, route := range spec.Missing(r.Routes()) {
+		logger.Log.Warnf("接口 %s 没有写入 OpenAPI 文档", route)
 	}
 }
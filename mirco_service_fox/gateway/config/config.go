@@ -1,25 +1,841 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"shared/identity"
+	"shared/logger"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// 上游实例的发现方式
+const (
+	DiscoveryStatic = "static"
+	DiscoveryDNS    = "dns"
+)
+
+// 上游实例的负载均衡策略
+const (
+	BalancerRoundRobin = "round_robin"
+	BalancerLeastConn  = "least_conn"
+	BalancerUserHash   = "user_hash"
+)
+
+// 路由匹配方式
+const (
+	MatchExact  = "exact"
+	MatchPrefix = "prefix"
+	MatchRegex  = "regex"
+)
 
 type ServiceConfig struct {
-	UserServiceURL         string
-	ExperimentServiceURL   string
-	NotificationServiceURL string
-	SubmissionServiceURL   string
-	GatewayPort            string
-	JWTSecret              string
+	UserServiceURL         string `yaml:"-"`
+	ExperimentServiceURL   string `yaml:"-"`
+	NotificationServiceURL string `yaml:"-"`
+	SubmissionServiceURL   string `yaml:"-"`
+	GatewayPort            string `yaml:"-"`
+	// 内部身份断言的签名密钥，来自 INTERNAL_IDENTITY_SECRET 环境变量，与各服务一致
+	InternalIdentitySecret string `yaml:"-"`
+	// 调用各服务 /internal 接口使用的服务令牌，来自 SERVICE_TOKEN_GATEWAY 环境变量
+	ServiceToken string `yaml:"-"`
+	// 配置文件路径，来自 GATEWAY_CONFIG 环境变量
+	File string `yaml:"-"`
+
+	// 上游连接池默认参数，可以在单个上游中覆盖
+	Transport TransportConfig `yaml:"transport"`
+	// 熔断默认参数，可以在单个上游中覆盖
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+	// 实例被动摘除默认参数，可以在单个上游中覆盖
+	Ejection EjectionConfig `yaml:"ejection"`
+	// 上游主动健康检查
+	HealthCheck HealthCheckConfig `yaml:"health_check"`
+	// 长连接（SSE、WebSocket）代理参数
+	Streaming StreamingConfig `yaml:"streaming"`
+	// 上游服务表，key 为路由中引用的上游名称
+	Upstreams map[string]UpstreamConfig `yaml:"upstreams"`
+	// 路由表
+	Routes []RouteConfig `yaml:"routes"`
+	// 路由未设置 timeout、max_body_size 时使用的默认值
+	RouteDefaults RouteDefaults `yaml:"route_defaults"`
+	// 限流配置
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	// 可信的反向代理（IP 或 CIDR），只有来自这些地址的 X-Forwarded-For、X-Real-IP 才用于确定客户端 IP；
+	// 默认为空，客户端 IP 取连接的对端地址。修改后需要重启
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// 跨域配置
+	CORS CORSConfig `yaml:"cors"`
+	// 响应压缩
+	Compression CompressionConfig `yaml:"compression"`
+	// GET 响应的 ETag 和条件请求
+	ETag ETagConfig `yaml:"etag"`
+	// 配置热加载
+	Reload ReloadConfig `yaml:"reload"`
+	// 认证配置
+	Auth AuthConfig `yaml:"auth"`
+	// 管理接口
+	Admin AdminConfig `yaml:"admin"`
+	// 教师写操作的审计日志
+	Audit AuditConfig `yaml:"audit"`
+	// 合并各服务 OpenAPI 文档的 /openapi.json 和 /docs
+	OpenAPI OpenAPIConfig `yaml:"openapi"`
+	// 按合并后的 OpenAPI 文档校验请求
+	Validation ValidationConfig `yaml:"validation"`
+}
+
+// AdminConfig 管理接口单独监听，只在配置了 GATEWAY_ADMIN_TOKEN 时启动，修改后需要重启
+type AdminConfig struct {
+	Addr string `yaml:"addr"` // 监听地址，默认 :9090，不要通过 Service/Ingress 暴露到集群外
+}
+
+// 审计日志的存储方式
+const (
+	AuditStoreMemory = "memory"
+	AuditStoreMySQL  = "mysql"
+)
+
+// AuditConfig /api/teacher 下非 GET 请求的审计日志，修改后需要重启
+type AuditConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Store        string   `yaml:"store"`         // memory（默认，只在单实例内、最多保留 max_entries 条）或 mysql（多实例共享）
+	DSN          string   `yaml:"-"`             // mysql 连接串，来自 AUDIT_DB_DSN 环境变量
+	MaxEntries   int      `yaml:"max_entries"`   // memory 存储保留的条数，超过后丢弃最早的
+	RedactFields []string `yaml:"redact_fields"` // 请求体中字段名包含这些词（不区分大小写）的值记为 "***"
+}
+
+// OpenAPIConfig 网关从各上游的 /openapi.json 取文档，只保留路由表中对外暴露的接口后合并
+type OpenAPIConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	CacheTTL time.Duration `yaml:"cache_ttl"` // 合并结果的缓存时间，路由表重新加载后立即失效
+	Timeout  time.Duration `yaml:"timeout"`   // 取单个上游文档的超时时间，取不到时沿用上次的结果
+}
+
+// ValidationConfig 转发前按合并后的 OpenAPI 文档校验路径参数、查询参数和 JSON 请求体，需要开启 openapi
+type ValidationConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	ReportOnly  bool     `yaml:"report_only"`   // 只记录不符合文档的请求，照常转发，用于正式开启前观察
+	MaxBodySize ByteSize `yaml:"max_body_size"` // 超过这个大小的请求体不校验
+}
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"` // 允许的来源，包含 "*" 时允许任意来源
+}
+
+// CompressionConfig 按 Accept-Encoding 用 br 或 gzip 压缩响应，只压缩 2xx 响应
+type CompressionConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	MinSize      ByteSize `yaml:"min_size"`      // 小于这个大小的响应不压缩
+	ContentTypes []string `yaml:"content_types"` // 压缩的响应类型，如 application/json
+}
+
+// ETagConfig GET 请求的 200 响应带 ETag，If-None-Match 匹配时返回 304。
+// 上游返回了 ETag 时直接使用，否则由网关按响应体计算
+type ETagConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	MaxBodySize ByteSize `yaml:"max_body_size"` // 网关计算 ETag 时最多缓存的响应体大小，超过的响应不带 ETag
+}
+
+// ReloadConfig 配置热加载参数
+type ReloadConfig struct {
+	WatchInterval time.Duration `yaml:"watch_interval"` // 检查配置文件是否变化的间隔，0 表示只在收到 SIGHUP 时重新加载
+}
+
+// 令牌注销记录的存储方式
+const (
+	RevocationStoreMemory = "memory"
+	RevocationStoreMySQL  = "mysql"
+)
+
+// AuthConfig 认证相关配置
+type AuthConfig struct {
+	Revocation RevocationConfig `yaml:"revocation"`
+	JWKS       JWKSConfig       `yaml:"jwks"`
+	// 管理接口的访问令牌，来自 GATEWAY_ADMIN_TOKEN 环境变量，为空时关闭管理接口
+	AdminToken string `yaml:"-"`
+	// 机器客户端使用的 API Key
+	APIKeys APIKeyConfig `yaml:"api_keys"`
+}
+
+// APIKeyConfig 请求携带 X-API-Key 时到 user-service 校验，结果在网关缓存 CacheTTL
+type APIKeyConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	CacheTTL time.Duration `yaml:"cache_ttl"` // 作废的 Key 最多在这段时间后失效
+}
+
+// JWKSConfig 令牌校验公钥的来源，由 user-service 发布
+type JWKSConfig struct {
+	URL             string        `yaml:"url"`              // 默认为 user 上游的 /.well-known/jwks.json
+	RefreshInterval time.Duration `yaml:"refresh_interval"` // 定期刷新的间隔，遇到未知 kid 时会提前刷新
+	StartupTimeout  time.Duration `yaml:"startup_timeout"`  // 启动时等待 user-service 发布公钥的最长时间，超时后启动失败
+}
+
+// RevocationConfig 已注销令牌的存储
+type RevocationConfig struct {
+	Store string `yaml:"store"` // memory（默认，只在单实例内生效）或 mysql（多实例共享）
+	DSN   string `yaml:"-"`     // mysql 连接串，来自 REVOCATION_DB_DSN 环境变量
+}
+
+// UpstreamConfig 上游服务配置
+type UpstreamConfig struct {
+	URL            string               `yaml:"url"`
+	Instances      []string             `yaml:"instances"`       // 多个实例的地址，设置后忽略 url
+	Discovery      string               `yaml:"discovery"`       // static（默认）或 dns：定期解析 url 的主机名，每个地址作为一个实例
+	DNSRefresh     time.Duration        `yaml:"dns_refresh"`     // dns 发现的解析间隔，默认 30s
+	Balancer       string               `yaml:"balancer"`        // round_robin（默认）/ least_conn / user_hash
+	HealthPath     string               `yaml:"health_path"`     // 健康检查路径，默认 /health
+	Transport      TransportConfig      `yaml:"transport"`       // 未设置的字段使用全局 transport
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"` // 未设置的字段使用全局 circuit_breaker
+	Ejection       EjectionConfig       `yaml:"ejection"`        // 未设置的字段使用全局 ejection
+}
+
+// Targets 返回上游的实例地址，没有配置 instances 时只有 url 一个
+func (u UpstreamConfig) Targets() []string {
+	if len(u.Instances) > 0 {
+		return u.Instances
+	}
+	return []string{u.URL}
+}
+
+// EjectionConfig 实例被动摘除参数：实例连续失败达到 ConsecutiveFailures 次后摘除 Duration，
+// 同时被摘除的实例不超过 MaxPercent%，只有一个实例的上游不会被摘除，交给熔断处理
+type EjectionConfig struct {
+	ConsecutiveFailures int           `yaml:"consecutive_failures"`
+	Duration            time.Duration `yaml:"duration"`
+	MaxPercent          int           `yaml:"max_percent"`
+}
+
+// DefaultEjectionConfig 被动摘除默认参数
+func DefaultEjectionConfig() EjectionConfig {
+	return EjectionConfig{
+		ConsecutiveFailures: 5,
+		Duration:            30 * time.Second,
+		MaxPercent:          50,
+	}
+}
+
+// Merge 用 e 中非零的字段覆盖 base
+func (e EjectionConfig) Merge(base EjectionConfig) EjectionConfig {
+	if e.ConsecutiveFailures > 0 {
+		base.ConsecutiveFailures = e.ConsecutiveFailures
+	}
+	if e.Duration > 0 {
+		base.Duration = e.Duration
+	}
+	if e.MaxPercent > 0 {
+		base.MaxPercent = e.MaxPercent
+	}
+	return base
+}
+
+// StreamingConfig 长连接代理参数。SSE 和 WebSocket 请求不受上游 response_header_timeout 限制，
+// 只要两个方向都有数据就一直保持
+type StreamingConfig struct {
+	FlushInterval time.Duration `yaml:"flush_interval"` // 向客户端刷新数据的间隔，0 表示收到数据立即刷新
+	IdleTimeout   time.Duration `yaml:"idle_timeout"`   // 两个方向都没有数据超过这个时间就断开连接
+}
+
+// CircuitBreakerConfig 熔断参数
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `yaml:"failure_threshold"`  // 连续失败多少次后熔断
+	OpenTimeout      time.Duration `yaml:"open_timeout"`       // 熔断后多久进入半开状态放行探测请求
+	HalfOpenRequests int           `yaml:"half_open_requests"` // 半开状态放行的探测请求数，全部成功后恢复
+}
+
+// DefaultCircuitBreakerConfig 熔断默认参数
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      10 * time.Second,
+		HalfOpenRequests: 1,
+	}
+}
+
+// Merge 用 b 中非零的字段覆盖 base
+func (b CircuitBreakerConfig) Merge(base CircuitBreakerConfig) CircuitBreakerConfig {
+	if b.FailureThreshold > 0 {
+		base.FailureThreshold = b.FailureThreshold
+	}
+	if b.OpenTimeout > 0 {
+		base.OpenTimeout = b.OpenTimeout
+	}
+	if b.HalfOpenRequests > 0 {
+		base.HalfOpenRequests = b.HalfOpenRequests
+	}
+	return base
+}
+
+// HealthCheckConfig 上游主动健康检查参数
+type HealthCheckConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// TransportConfig 上游连接池参数，时间使用 "30s" 这样的格式
+type TransportConfig struct {
+	MaxIdleConns          int           `yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host"`
+	MaxConnsPerHost       int           `yaml:"max_conns_per_host"` // 0 表示不限制
+	DialTimeout           time.Duration `yaml:"dial_timeout"`
+	KeepAlive             time.Duration `yaml:"keep_alive"`
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout"`
+	IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout"`
+}
+
+// DefaultTransportConfig 连接池默认参数
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:          200,
+		MaxIdleConnsPerHost:   100,
+		DialTimeout:           5 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		ResponseHeaderTimeout: 0, // 请求超时由路由的 timeout 控制
+	}
+}
+
+// Merge 用 t 中非零的字段覆盖 base
+func (t TransportConfig) Merge(base TransportConfig) TransportConfig {
+	if t.MaxIdleConns > 0 {
+		base.MaxIdleConns = t.MaxIdleConns
+	}
+	if t.MaxIdleConnsPerHost > 0 {
+		base.MaxIdleConnsPerHost = t.MaxIdleConnsPerHost
+	}
+	if t.MaxConnsPerHost > 0 {
+		base.MaxConnsPerHost = t.MaxConnsPerHost
+	}
+	if t.DialTimeout > 0 {
+		base.DialTimeout = t.DialTimeout
+	}
+	if t.KeepAlive > 0 {
+		base.KeepAlive = t.KeepAlive
+	}
+	if t.TLSHandshakeTimeout > 0 {
+		base.TLSHandshakeTimeout = t.TLSHandshakeTimeout
+	}
+	if t.IdleConnTimeout > 0 {
+		base.IdleConnTimeout = t.IdleConnTimeout
+	}
+	if t.ResponseHeaderTimeout > 0 {
+		base.ResponseHeaderTimeout = t.ResponseHeaderTimeout
+	}
+	return base
+}
+
+// RouteConfig 单条路由配置
+type RouteConfig struct {
+	Name        string   `yaml:"name"`
+	Match       string   `yaml:"match"`        // exact / prefix / regex，默认 prefix
+	Path        string   `yaml:"path"`         // 匹配的路径、前缀或正则表达式
+	Methods     []string `yaml:"methods"`      // 允许的方法，为空表示不限制
+	Upstream    string   `yaml:"upstream"`     // 转发的上游名称
+	StripPrefix string   `yaml:"strip_prefix"` // 转发前去掉的路径前缀
+	Rewrite     string   `yaml:"rewrite"`      // 路径重写，regex 路由中可以使用 $1 等分组引用
+	Role        string   `yaml:"role"`         // 需要的角色，为空表示登录即可
+	RateLimit   string   `yaml:"rate_limit"`   // 限流类别，为空时使用 rate_limit.default_class
+	// 转发请求的超时时间，从收到请求到上游响应结束，超时返回 504；SSE 和 WebSocket 不受限制
+	Timeout time.Duration `yaml:"timeout"`
+	// 请求体大小上限，超过返回 413
+	MaxBodySize ByteSize `yaml:"max_body_size"`
+	// 使用 API Key 访问时需要的权限范围，未声明的路由不接受 API Key
+	Scopes RouteScopes `yaml:"scopes"`
+	// 金丝雀发布：部分请求转发到另一个上游
+	Canary *CanaryConfig `yaml:"canary"`
+	// 流量复制：请求复制一份发到影子上游，比较响应后丢弃
+	Mirror *MirrorConfig `yaml:"mirror"`
+}
+
+// 金丝雀请求头、cookie 的取值
+const (
+	CanaryAlways = "always" // 总是转发到金丝雀上游
+	CanaryNever  = "never"  // 总是转发到原上游
+)
+
+// CanaryConfig 把部分请求转发到 upstream（通常是新版本的服务），依次判断：
+// 请求头 header 或 cookie 的值为 always/never 时按其决定；用户在 user_ids 中时转发；
+// 否则按 weight 的比例转发，按用户 ID（未登录按客户端 IP）哈希，同一用户固定落到同一版本
+type CanaryConfig struct {
+	Upstream string   `yaml:"upstream"`
+	Weight   int      `yaml:"weight"`   // 百分比 0-100
+	Header   string   `yaml:"header"`   // 如 X-Canary
+	Cookie   string   `yaml:"cookie"`   // 如 canary
+	UserIDs  []string `yaml:"user_ids"` // 总是转发到金丝雀的用户
+}
+
+// MirrorConfig 把请求复制一份发到影子上游，影子的响应不返回给客户端。
+// 影子请求带 X-Gateway-Mirror: 1 请求头，影子上游应使用独立的数据库，避免重复写入
+type MirrorConfig struct {
+	Upstream    string        `yaml:"upstream"`
+	Percent     int           `yaml:"percent"`       // 复制的请求比例 0-100，默认 100
+	Timeout     time.Duration `yaml:"timeout"`       // 影子请求的超时时间，默认 10s
+	MaxBodySize ByteSize      `yaml:"max_body_size"` // 请求体超过这个大小的请求不复制，也是比较响应时最多缓存的大小，默认 1MB
+	// 比较两边的状态码和响应体，不一致时记录日志
+	Compare bool `yaml:"compare"`
+	// 比较 JSON 响应体时忽略的字段名（任意层级），如 id、created_at
+	IgnoreFields []string `yaml:"ignore_fields"`
+	// 复制的方法，默认只有 GET、HEAD。影子请求带着真实用户的身份，写请求会在影子上游以该用户的身份执行一遍，
+	// 只有影子使用独立的数据库时才能显式加上 POST 等方法
+	Methods []string `yaml:"methods"`
+}
+
+// RouteScopes 路由读写操作分别需要的 API Key 权限范围，GET/HEAD 为读，其余为写
+type RouteScopes struct {
+	Read  string `yaml:"read"`
+	Write string `yaml:"write"`
+}
+
+// RequiredScope 返回 API Key 以 method 访问路由需要的权限范围，为空表示不允许 API Key 访问
+func (r RouteConfig) RequiredScope(method string) string {
+	if method == http.MethodGet || method == http.MethodHead {
+		return r.Scopes.Read
+	}
+	return r.Scopes.Write
+}
+
+// RouteDefaults 路由的默认超时时间和请求体大小上限
+type RouteDefaults struct {
+	Timeout     time.Duration `yaml:"timeout"`
+	MaxBodySize ByteSize      `yaml:"max_body_size"`
+}
+
+// ByteSize 字节数，配置中可以写 512KB、10MB、1GB 这样的格式（按 1024 换算），也可以写纯数字
+type ByteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseByteSize 解析 10MB 这样的字节数
+func ParseByteSize(s string) (ByteSize, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			v = strings.TrimSpace(strings.TrimSuffix(v, u.suffix))
+			unit = u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/unit {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	return ByteSize(n * unit), nil
+}
+
+func (b *ByteSize) UnmarshalYAML(value *yaml.Node) error {
+	size, err := ParseByteSize(value.Value)
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+// String 返回便于阅读的格式，用于错误提示
+func (b ByteSize) String() string {
+	for _, u := range byteUnits {
+		if int64(b) >= u.size && int64(b)%u.size == 0 {
+			return strconv.FormatInt(int64(b)/u.size, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(b), 10) + "B"
 }
 
+// RateLimitConfig 限流配置，按路由类别分别设置令牌桶
+type RateLimitConfig struct {
+	Enabled      bool                     `yaml:"enabled"`
+	DefaultClass string                   `yaml:"default_class"`
+	Classes      map[string]RateLimitRule `yaml:"classes"`
+}
+
+// RateLimitRule 令牌桶参数：每 Per 时间补充 Requests 个令牌，桶容量为 Burst
+type RateLimitRule struct {
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+	Burst    int           `yaml:"burst"` // 为 0 时等于 Requests
+}
+
+// Rule 返回类别对应的限流规则，类别为空时使用默认类别
+func (r RateLimitConfig) Rule(class string) (string, RateLimitRule, bool) {
+	if class == "" {
+		class = r.DefaultClass
+	}
+	rule, ok := r.Classes[class]
+	return class, rule, ok
+}
+
+// 内置上游名称与对应的环境变量
+var builtinUpstreams = map[string]string{
+	"user":         "USER_SERVICE_URL",
+	"experiment":   "EXPERIMENT_SERVICE_URL",
+	"notification": "NOTIFICATION_SERVICE_URL",
+	"submission":   "SUBMISSION_SERVICE_URL",
+}
+
+// LoadConfig 读取环境变量和路由配置文件，配置错误时直接退出
 func LoadConfig() *ServiceConfig {
-	return &ServiceConfig{
+	path := getEnv("GATEWAY_CONFIG", "gateway.yaml")
+	cfg, err := Load(path)
+	if err != nil {
+		logger.Log.WithError(err).Fatal("Failed to load gateway config")
+	}
+	return cfg
+}
+
+// Load 从指定文件读取配置并校验
+func Load(path string) (*ServiceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file %s: %w", path, err)
+	}
+	return parse(path, data)
+}
+
+// parse 解析配置文件内容，补全默认值并校验
+func parse(path string, data []byte) (*ServiceConfig, error) {
+	cfg := &ServiceConfig{
 		UserServiceURL:         getEnv("USER_SERVICE_URL", "http://localhost:8081"),
 		ExperimentServiceURL:   getEnv("EXPERIMENT_SERVICE_URL", "http://localhost:8082"),
 		NotificationServiceURL: getEnv("NOTIFICATION_SERVICE_URL", "http://localhost:8083"),
 		SubmissionServiceURL:   getEnv("SUBMISSION_SERVICE_URL", "http://localhost:8084"),
 		GatewayPort:            getEnv("GATEWAY_PORT", "8080"),
-		JWTSecret:              getEnv("JWT_SECRET", "a_secret_crect"),
+		InternalIdentitySecret: os.Getenv("INTERNAL_IDENTITY_SECRET"),
+		ServiceToken:           os.Getenv(identity.ServiceTokenEnv(identity.GatewayName)),
+		File:                   path,
+	}
+	cfg.Transport = DefaultTransportConfig()
+	cfg.CircuitBreaker = DefaultCircuitBreakerConfig()
+	cfg.Ejection = DefaultEjectionConfig()
+	cfg.HealthCheck = HealthCheckConfig{Enabled: true, Interval: 10 * time.Second, Timeout: 2 * time.Second}
+	cfg.Streaming = StreamingConfig{IdleTimeout: 5 * time.Minute}
+	cfg.Compression = CompressionConfig{Enabled: true, MinSize: 1 << 10, ContentTypes: []string{"application/json"}}
+	cfg.ETag = ETagConfig{Enabled: true, MaxBodySize: 1 << 20}
+	cfg.OpenAPI = OpenAPIConfig{Enabled: true, CacheTTL: time.Minute, Timeout: 3 * time.Second}
+	cfg.Validation = ValidationConfig{MaxBodySize: 1 << 20}
+	cfg.CORS = CORSConfig{AllowedOrigins: []string{"*"}}
+	cfg.Reload = ReloadConfig{WatchInterval: 5 * time.Second}
+	cfg.RouteDefaults = RouteDefaults{Timeout: 30 * time.Second, MaxBodySize: 2 << 20}
+	cfg.Admin = AdminConfig{Addr: ":9090"}
+	cfg.Audit = AuditConfig{
+		Enabled:      true,
+		Store:        AuditStoreMemory,
+		MaxEntries:   10000,
+		RedactFields: []string{"password", "token", "secret", "api_key"},
+	}
+	cfg.Auth = AuthConfig{
+		Revocation: RevocationConfig{Store: RevocationStoreMemory},
+		JWKS:       JWKSConfig{RefreshInterval: 5 * time.Minute, StartupTimeout: 30 * time.Second},
+		APIKeys:    APIKeyConfig{Enabled: true, CacheTTL: 30 * time.Second},
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	cfg.Auth.AdminToken = os.Getenv("GATEWAY_ADMIN_TOKEN")
+	cfg.Auth.Revocation.DSN = os.Getenv("REVOCATION_DB_DSN")
+	cfg.Audit.DSN = os.Getenv("AUDIT_DB_DSN")
+
+	// 内置上游：配置文件中的 url 或 instances 优先，热加载修改地址总能生效；
+	// 配置文件没有地址时使用环境变量，都没有时使用默认地址
+	if cfg.Upstreams == nil {
+		cfg.Upstreams = make(map[string]UpstreamConfig)
 	}
+	defaults := map[string]string{
+		"user":         cfg.UserServiceURL,
+		"experiment":   cfg.ExperimentServiceURL,
+		"notification": cfg.NotificationServiceURL,
+		"submission":   cfg.SubmissionServiceURL,
+	}
+	for name, env := range builtinUpstreams {
+		up := cfg.Upstreams[name]
+		if up.URL == "" && len(up.Instances) == 0 {
+			up.URL = defaults[name]
+		} else if v := os.Getenv(env); v != "" && (len(up.Instances) > 0 || v != up.URL) {
+			logger.Log.WithFields(logrus.Fields{"upstream": name, "env": env, "file": path}).
+				Warn("Upstream address in config file overrides environment variable")
+		}
+		cfg.Upstreams[name] = up
+	}
+	for name, up := range cfg.Upstreams {
+		up.Transport = up.Transport.Merge(cfg.Transport)
+		up.CircuitBreaker = up.CircuitBreaker.Merge(cfg.CircuitBreaker)
+		up.Ejection = up.Ejection.Merge(cfg.Ejection)
+		if up.HealthPath == "" {
+			up.HealthPath = "/health"
+		}
+		if up.Discovery == "" {
+			up.Discovery = DiscoveryStatic
+		}
+		if up.DNSRefresh == 0 {
+			up.DNSRefresh = 30 * time.Second
+		}
+		if up.Balancer == "" {
+			up.Balancer = BalancerRoundRobin
+		}
+		cfg.Upstreams[name] = up
+	}
+	if cfg.Auth.JWKS.URL == "" {
+		cfg.Auth.JWKS.URL = strings.TrimSuffix(cfg.Upstreams["user"].Targets()[0], "/") + "/.well-known/jwks.json"
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate 校验路由表
+func (c *ServiceConfig) Validate() error {
+	for name, up := range c.Upstreams {
+		if err := up.validate(); err != nil {
+			return fmt.Errorf("upstream %q: %w", name, err)
+		}
+	}
+	if c.HealthCheck.Enabled && (c.HealthCheck.Interval <= 0 || c.HealthCheck.Timeout <= 0) {
+		return fmt.Errorf("health check: interval and timeout must be positive")
+	}
+	if c.Streaming.FlushInterval < 0 || c.Streaming.IdleTimeout <= 0 {
+		return fmt.Errorf("streaming: flush_interval must not be negative and idle_timeout must be positive")
+	}
+	if c.Reload.WatchInterval < 0 {
+		return fmt.Errorf("reload: watch_interval must not be negative")
+	}
+	for _, p := range c.TrustedProxies {
+		if net.ParseIP(p) == nil {
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				return fmt.Errorf("trusted_proxies: %q is not an IP or CIDR", p)
+			}
+		}
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "" {
+			return fmt.Errorf("cors: allowed_origins must not contain empty entries")
+		}
+	}
+	if len(c.InternalIdentitySecret) < identity.MinSecretLength {
+		return fmt.Errorf("INTERNAL_IDENTITY_SECRET must be at least %d bytes", identity.MinSecretLength)
+	}
+	if len(c.ServiceToken) < identity.MinSecretLength {
+		return fmt.Errorf("%s must be at least %d bytes", identity.ServiceTokenEnv(identity.GatewayName), identity.MinSecretLength)
+	}
+	if c.Auth.JWKS.RefreshInterval <= 0 || c.Auth.JWKS.StartupTimeout <= 0 {
+		return fmt.Errorf("auth: jwks refresh_interval and startup_timeout must be positive")
+	}
+	switch c.Auth.Revocation.Store {
+	case RevocationStoreMemory:
+	case RevocationStoreMySQL:
+		if c.Auth.Revocation.DSN == "" {
+			return fmt.Errorf("auth: revocation store mysql requires REVOCATION_DB_DSN")
+		}
+	default:
+		return fmt.Errorf("auth: unknown revocation store %q", c.Auth.Revocation.Store)
+	}
+	if c.Audit.Enabled {
+		switch c.Audit.Store {
+		case AuditStoreMemory:
+			if c.Audit.MaxEntries <= 0 {
+				return fmt.Errorf("audit: max_entries must be positive")
+			}
+		case AuditStoreMySQL:
+			if c.Audit.DSN == "" {
+				return fmt.Errorf("audit: store mysql requires AUDIT_DB_DSN")
+			}
+		default:
+			return fmt.Errorf("audit: unknown store %q", c.Audit.Store)
+		}
+	}
+	for class, rule := range c.RateLimit.Classes {
+		if rule.Requests <= 0 || rule.Per <= 0 {
+			return fmt.Errorf("rate limit class %q: requests and per must be positive", class)
+		}
+		if rule.Burst == 0 {
+			rule.Burst = rule.Requests
+			c.RateLimit.Classes[class] = rule
+		}
+	}
+	if c.RateLimit.DefaultClass != "" {
+		if _, ok := c.RateLimit.Classes[c.RateLimit.DefaultClass]; !ok {
+			return fmt.Errorf("rate limit: unknown default class %q", c.RateLimit.DefaultClass)
+		}
+	}
+	if c.ETag.Enabled && c.ETag.MaxBodySize <= 0 {
+		return fmt.Errorf("etag: max_body_size must be positive")
+	}
+	if c.OpenAPI.Enabled && (c.OpenAPI.CacheTTL <= 0 || c.OpenAPI.Timeout <= 0) {
+		return fmt.Errorf("openapi: cache_ttl and timeout must be positive")
+	}
+	if c.Validation.Enabled {
+		if !c.OpenAPI.Enabled {
+			return fmt.Errorf("validation: requires openapi to be enabled")
+		}
+		if c.Validation.MaxBodySize <= 0 {
+			return fmt.Errorf("validation: max_body_size must be positive")
+		}
+	}
+	if c.RouteDefaults.Timeout <= 0 || c.RouteDefaults.MaxBodySize <= 0 {
+		return fmt.Errorf("route_defaults: timeout and max_body_size must be positive")
+	}
+	for i := range c.Routes {
+		r := &c.Routes[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("route-%d", i)
+		}
+		if r.Timeout < 0 {
+			return fmt.Errorf("route %q: timeout must not be negative", r.Name)
+		}
+		if r.Timeout == 0 {
+			r.Timeout = c.RouteDefaults.Timeout
+		}
+		if r.MaxBodySize == 0 {
+			r.MaxBodySize = c.RouteDefaults.MaxBodySize
+		}
+		if r.Match == "" {
+			r.Match = MatchPrefix
+		}
+		if r.Path == "" {
+			return fmt.Errorf("route %q: path is required", r.Name)
+		}
+		switch r.Match {
+		case MatchExact, MatchPrefix:
+			if !strings.HasPrefix(r.Path, "/") {
+				return fmt.Errorf("route %q: path must start with /", r.Name)
+			}
+			if IsInternalPath(r.Path) {
+				return fmt.Errorf("route %q: %s is internal and cannot be exposed", r.Name, InternalPathPrefix)
+			}
+		case MatchRegex:
+			if _, err := regexp.Compile(r.Path); err != nil {
+				return fmt.Errorf("route %q: invalid regex: %w", r.Name, err)
+			}
+		default:
+			return fmt.Errorf("route %q: unknown match type %q", r.Name, r.Match)
+		}
+		if IsInternalPath(r.Rewrite) {
+			return fmt.Errorf("route %q: cannot rewrite to %s", r.Name, InternalPathPrefix)
+		}
+		if _, ok := c.Upstreams[r.Upstream]; !ok {
+			return fmt.Errorf("route %q: unknown upstream %q", r.Name, r.Upstream)
+		}
+		if r.RateLimit != "" {
+			if _, ok := c.RateLimit.Classes[r.RateLimit]; !ok {
+				return fmt.Errorf("route %q: unknown rate limit class %q", r.Name, r.RateLimit)
+			}
+		}
+		for j, m := range r.Methods {
+			r.Methods[j] = strings.ToUpper(m)
+		}
+		if err := r.validateRollout(c.Upstreams); err != nil {
+			return fmt.Errorf("route %q: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateRollout 校验金丝雀和流量复制配置，补全默认值
+func (r *RouteConfig) validateRollout(upstreams map[string]UpstreamConfig) error {
+	if cn := r.Canary; cn != nil {
+		if _, ok := upstreams[cn.Upstream]; !ok {
+			return fmt.Errorf("canary: unknown upstream %q", cn.Upstream)
+		}
+		if cn.Upstream == r.Upstream {
+			return fmt.Errorf("canary: upstream must differ from the route upstream")
+		}
+		if cn.Weight < 0 || cn.Weight > 100 {
+			return fmt.Errorf("canary: weight must be within 0-100")
+		}
+	}
+	if m := r.Mirror; m != nil {
+		if _, ok := upstreams[m.Upstream]; !ok {
+			return fmt.Errorf("mirror: unknown upstream %q", m.Upstream)
+		}
+		if m.Upstream == r.Upstream || (r.Canary != nil && m.Upstream == r.Canary.Upstream) {
+			return fmt.Errorf("mirror: upstream must differ from the route and canary upstreams")
+		}
+		if m.Percent == 0 {
+			m.Percent = 100
+		}
+		if len(m.Methods) == 0 {
+			m.Methods = []string{http.MethodGet, http.MethodHead}
+		}
+		for i, method := range m.Methods {
+			m.Methods[i] = strings.ToUpper(method)
+		}
+		if m.Timeout == 0 {
+			m.Timeout = 10 * time.Second
+		}
+		if m.MaxBodySize == 0 {
+			m.MaxBodySize = 1 << 20
+		}
+		if m.Percent < 0 || m.Percent > 100 || m.Timeout < 0 || m.MaxBodySize < 0 {
+			return fmt.Errorf("mirror: percent must be within 1-100, timeout and max_body_size must be positive")
+		}
+	}
+	return nil
+}
+
+// validate 校验上游的实例地址、发现方式和负载均衡策略
+func (u UpstreamConfig) validate() error {
+	if u.URL == "" && len(u.Instances) == 0 {
+		return fmt.Errorf("url or instances is required")
+	}
+	for _, target := range u.Targets() {
+		parsed, err := url.Parse(target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid url %q", target)
+		}
+		// 请求转发到哪个实例只替换主机，各实例的路径必须一致
+		if len(u.Instances) > 0 && parsed.Path != "" && parsed.Path != "/" {
+			return fmt.Errorf("instance %q: path is not allowed", target)
+		}
+	}
+	switch u.Discovery {
+	case DiscoveryStatic:
+	case DiscoveryDNS:
+		if len(u.Instances) > 0 {
+			return fmt.Errorf("discovery dns resolves url, instances must be empty")
+		}
+		if u.DNSRefresh <= 0 {
+			return fmt.Errorf("dns_refresh must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown discovery %q", u.Discovery)
+	}
+	switch u.Balancer {
+	case BalancerRoundRobin, BalancerLeastConn, BalancerUserHash:
+	default:
+		return fmt.Errorf("unknown balancer %q", u.Balancer)
+	}
+	if u.Ejection.ConsecutiveFailures <= 0 || u.Ejection.Duration <= 0 || u.Ejection.MaxPercent < 0 || u.Ejection.MaxPercent > 100 {
+		return fmt.Errorf("ejection: consecutive_failures and duration must be positive, max_percent must be within 0-100")
+	}
+	return nil
+}
+
+// InternalPathPrefix 服务间调用的路由前缀，网关不转发
+const InternalPathPrefix = "/internal"
+
+// IsInternalPath 判断路径是否属于服务间调用的路由
+func IsInternalPath(path string) bool {
+	return path == InternalPathPrefix || strings.HasPrefix(path, InternalPathPrefix+"/")
 }
 
 func getEnv(key, defaultValue string) string {
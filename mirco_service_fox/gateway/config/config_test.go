@@ -0,0 +1,66 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"shared/logger"
+	"strings"
+	"testing"
+)
+
+func TestParseBuiltinUpstreams(t *testing.T) {
+	t.Setenv("INTERNAL_IDENTITY_SECRET", "0123456789abcdef0123456789abcdef")
+	t.Setenv("SERVICE_TOKEN_GATEWAY", "0123456789abcdef0123456789abcdef")
+	var logs bytes.Buffer
+	out := logger.Log.Out
+	logger.Log.SetOutput(&logs)
+	t.Cleanup(func() { logger.Log.SetOutput(out) })
+
+	tests := []struct {
+		name          string
+		env           string // USER_SERVICE_URL
+		file          string
+		wantURL       string
+		wantInstances []string
+		wantWarn      bool
+	}{
+		{"default", "", "upstreams: {}", "http://localhost:8081", nil, false},
+		{"env", "http://user-service:8081", "upstreams: {}", "http://user-service:8081", nil, false},
+		{"env with options only in file", "http://user-service:8081", "upstreams:\n  user:\n    balancer: least_conn", "http://user-service:8081", nil, false},
+		// 配置文件中的地址优先，热加载修改地址才能生效
+		{"file overrides env", "http://user-service:8081", "upstreams:\n  user:\n    url: http://user-canary:8081", "http://user-canary:8081", nil, true},
+		{"file same as env", "http://user-service:8081", "upstreams:\n  user:\n    url: http://user-service:8081", "http://user-service:8081", nil, false},
+		{"file without env", "", "upstreams:\n  user:\n    url: http://user-canary:8081", "http://user-canary:8081", nil, false},
+		{"instances", "", "upstreams:\n  user:\n    instances: [http://user-1:8081, http://user-2:8081]", "", []string{"http://user-1:8081", "http://user-2:8081"}, false},
+		// 设置了环境变量时 instances 同样保留
+		{"instances with env", "http://user-service:8081", "upstreams:\n  user:\n    instances: [http://user-1:8081, http://user-2:8081]", "", []string{"http://user-1:8081", "http://user-2:8081"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range builtinUpstreams {
+				t.Setenv(env, "")
+			}
+			t.Setenv("USER_SERVICE_URL", tt.env)
+			logs.Reset()
+			cfg, err := parse("gateway.yaml", []byte(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.Upstreams["user"].URL; got != tt.wantURL {
+				t.Errorf("user url = %q, want %q", got, tt.wantURL)
+			}
+			if got := cfg.Upstreams["user"].Instances; !reflect.DeepEqual(got, tt.wantInstances) {
+				t.Errorf("user instances = %v, want %v", got, tt.wantInstances)
+			}
+			if got := cfg.Upstreams["experiment"].URL; got != "http://localhost:8082" {
+				t.Errorf("experiment url = %q, want the default", got)
+			}
+			if warned := strings.Contains(logs.String(), "overrides environment variable"); warned != tt.wantWarn {
+				t.Errorf("warned = %v, want %v", warned, tt.wantWarn)
+			}
+			if want := cfg.Upstreams["user"].Targets()[0] + "/.well-known/jwks.json"; cfg.Auth.JWKS.URL != want {
+				t.Errorf("jwks url = %q, want %q", cfg.Auth.JWKS.URL, want)
+			}
+		})
+	}
+}
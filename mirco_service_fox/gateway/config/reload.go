@@ -0,0 +1,189 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"gateway/metrics"
+	"os"
+	"os/signal"
+	"reflect"
+	"shared/logger"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 触发重新加载的来源
+const (
+	ReloadTriggerFile   = "file"
+	ReloadTriggerSignal = "signal"
+)
+
+// Reloader 持有当前生效的配置。配置文件内容变化或收到 SIGHUP 时重新读取并校验，
+// 再依次交给 OnReload 注册的回调构建路由表、连接池等，全部成功后才替换当前配置；
+// 任何一步失败都回滚已经应用的回调，继续使用原配置。
+//
+// auth 段和环境变量（服务密钥、内置上游地址）只在启动时读取，修改后需要重启。
+type Reloader struct {
+	current atomic.Pointer[ServiceConfig]
+
+	mu       sync.Mutex // 同一时间只有一次重新加载
+	hooks    []func(*ServiceConfig) error
+	lastHash [sha256.Size]byte
+}
+
+// NewReloader 以启动时加载的配置作为当前配置
+func NewReloader(cfg *ServiceConfig) *Reloader {
+	r := &Reloader{}
+	r.current.Store(cfg)
+	if data, err := os.ReadFile(cfg.File); err == nil {
+		r.lastHash = sha256.Sum256(data)
+	}
+	metrics.ConfigLastReloadSuccess.SetToCurrentTime()
+	return r
+}
+
+// Current 返回当前生效的配置，调用方不能修改
+func (r *Reloader) Current() *ServiceConfig {
+	return r.current.Load()
+}
+
+// OnReload 注册配置生效前的回调。回调需要自己保证原子性：返回错误时不能改变任何状态。
+func (r *Reloader) OnReload(fn func(*ServiceConfig) error) {
+	r.mu.Lock()
+	r.hooks = append(r.hooks, fn)
+	r.mu.Unlock()
+}
+
+// Reload 重新读取配置文件，校验并应用
+func (r *Reloader) Reload(trigger string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := time.Now()
+	old := r.Current()
+	log := logger.Log.WithFields(logrus.Fields{"trigger": trigger, "file": old.File})
+
+	next, err := r.load(old.File)
+	applied := 0
+	if err == nil {
+		for _, fn := range r.hooks {
+			if err = fn(next); err != nil {
+				break
+			}
+			applied++
+		}
+	}
+	if err != nil {
+		// 回滚已经应用了新配置的回调
+		for _, fn := range r.hooks[:applied] {
+			if rbErr := fn(old); rbErr != nil {
+				log.WithError(rbErr).Error("Failed to roll back config")
+			}
+		}
+		metrics.ConfigReloads.WithLabelValues(trigger, "failure").Inc()
+		log.WithError(err).Error("Config reload failed, keeping previous config")
+		return err
+	}
+
+	r.current.Store(next)
+	metrics.ConfigReloads.WithLabelValues(trigger, "success").Inc()
+	metrics.ConfigLastReloadSuccess.SetToCurrentTime()
+	if !reflect.DeepEqual(old.Auth, next.Auth) {
+		log.Warn("Auth config changed, it takes effect after restart")
+	}
+	if !slices.Equal(old.TrustedProxies, next.TrustedProxies) {
+		log.Warn("Trusted proxies changed, it takes effect after restart")
+	}
+	if old.Admin != next.Admin {
+		log.Warn("Admin config changed, it takes effect after restart")
+	}
+	if !reflect.DeepEqual(old.Audit, next.Audit) {
+		log.Warn("Audit config changed, it takes effect after restart")
+	}
+	log.WithFields(logrus.Fields{
+		"routes":            len(next.Routes),
+		"upstreams_changed": changedUpstreams(old, next),
+		"duration_ms":       time.Since(start).Milliseconds(),
+	}).Info("Config reloaded")
+	return nil
+}
+
+// load 读取并校验配置文件，同时记下内容摘要，内容不变时不会因为文件检查再次加载
+func (r *Reloader) load(path string) (*ServiceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file %s: %w", path, err)
+	}
+	r.lastHash = sha256.Sum256(data)
+	return parse(path, data)
+}
+
+// fileChanged 判断配置文件内容是否与上次加载时不同。
+// 按内容比较，k8s ConfigMap 通过替换符号链接更新文件时也能发现
+func (r *Reloader) fileChanged() bool {
+	data, err := os.ReadFile(r.Current().File)
+	if err != nil {
+		logger.Log.WithError(err).Warn("Failed to read config file")
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return sha256.Sum256(data) != r.lastHash
+}
+
+// Watch 按 reload.watch_interval 检查配置文件，内容变化时重新加载；收到 SIGHUP 时立即重新加载。
+// 阻塞直到 ctx 结束
+func (r *Reloader) Watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		// 检查间隔也可以热加载，每轮按当前配置重新计时
+		var timer *time.Timer
+		var tick <-chan time.Time
+		if interval := r.Current().Reload.WatchInterval; interval > 0 {
+			timer = time.NewTimer(interval)
+			tick = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-hup:
+			_ = r.Reload(ReloadTriggerSignal)
+		case <-tick:
+			if r.fileChanged() {
+				_ = r.Reload(ReloadTriggerFile)
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// changedUpstreams 返回地址有变化、新增或删除的上游名称
+func changedUpstreams(old, next *ServiceConfig) []string {
+	names := []string{}
+	for name, up := range next.Upstreams {
+		if prev, ok := old.Upstreams[name]; !ok || !reflect.DeepEqual(prev.Targets(), up.Targets()) || prev.Discovery != up.Discovery {
+			names = append(names, name)
+		}
+	}
+	for name := range old.Upstreams {
+		if _, ok := next.Upstreams[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
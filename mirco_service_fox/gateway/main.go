@@ -1,45 +1,119 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"gateway/config"
+	"gateway/metrics"
 	"gateway/middleware"
+	"gateway/proxy"
 	"gateway/routes"
-	"log"
-	"time"
+	"net/http"
+	"shared/logger"
+	"shared/server"
+	"shared/tracing"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	logger.Init("gateway")
 	cfg := config.LoadConfig()
+	// 初始化链路追踪
+	shutdownTracing, err := tracing.Init(context.Background(), "gateway")
+	if err != nil {
+		logger.Log.WithError(err).Fatal("Failed to init tracing")
+	}
+	defer shutdownTracing(context.Background())
+
 	// 初始化路由
-	router := gin.Default()
-
-	// 添加日志中间件
-	router.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		return fmt.Sprintf("[GATEWAY] %s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
-			param.ClientIP,
-			param.TimeStamp.Format(time.RFC1123),
-			param.Method,
-			param.Path,
-			param.Request.Proto,
-			param.StatusCode,
-			param.Latency,
-			param.Request.UserAgent(),
-			param.ErrorMessage,
-		)
-	}))
-	// 添加认证中间件
-	router.Use(middleware.CORSMiddleware())
+	router := gin.New()
+	// 客户端 IP 用于按 IP 限流、金丝雀分桶和审计，只信任配置的代理转发的 X-Forwarded-For
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to set trusted proxies")
+	}
+	router.Use(gin.Recovery())
+	router.Use(tracing.Middleware("gateway"))
+
+	// 路由表和上游连接池，配置文件变化或收到 SIGHUP 时重新加载
+	reloader := config.NewReloader(cfg)
+	rt, err := routes.NewRuntime(cfg)
+	if err != nil {
+		logger.Log.WithError(err).Fatal("Failed to build route table")
+	}
+	reloader.OnReload(rt.Apply)
+	go reloader.Watch(context.Background())
+
+	// 已注销令牌的存储
+	revocations, err := middleware.NewRevocationStore(cfg.Auth.Revocation)
+	if err != nil {
+		logger.Log.WithError(err).Fatal("Failed to create revocation store")
+	}
+	// 令牌校验公钥，取不到时不能启动
+	keys, err := middleware.NewJWKSCache(context.Background(), cfg.Auth.JWKS)
+	if err != nil {
+		logger.Log.WithError(err).Fatal("Failed to load JWKS, no key to verify tokens")
+	}
+
+	// 请求ID和访问日志
 	router.Use(middleware.RequestIDMiddleware())
-	router.Use(middleware.AuthMiddleware())
+	router.Use(middleware.AccessLogMiddleware())
+
+	// 添加指标中间件，按路由统计最近的错误数供管理接口查看
+	router.Use(metrics.Middleware())
+	errorCounter := middleware.NewErrorCounter()
+	router.Use(middleware.ErrorCountMiddleware(errorCounter))
+	// 添加认证中间件
+	router.Use(middleware.CORSMiddleware(reloader))
+	// 响应压缩和条件请求，ETag 按压缩前的内容计算
+	router.Use(middleware.CompressionMiddleware(reloader))
+	router.Use(middleware.ETagMiddleware(reloader))
+	router.Use(middleware.AuthMiddleware(keys, revocations, middleware.NewAPIKeyVerifier(reloader)))
+	// 审计中间件，记录教师的写操作
+	audit, err := middleware.NewAuditStore(cfg.Audit)
+	if err != nil {
+		logger.Log.WithError(err).Fatal("Failed to create audit store")
+	}
+	if audit != nil {
+		router.Use(middleware.AuditMiddleware(audit, cfg.Audit.RedactFields))
+	}
+	// 添加限流中间件
+	router.Use(middleware.RateLimitMiddleware(reloader, middleware.NewMemoryRateLimitStore(), rt.RateLimitClass))
+	// 按合并后的接口文档校验请求，默认关闭
+	docs := routes.NewAPIDocs(rt)
+	router.Use(middleware.ValidationMiddleware(reloader, docs.Validator))
 
 	// 初始化路由
-	routes.SetupRoutes(router, cfg)
-	// 启动服务
-	log.Println("API Gateway starting on :8080")
-	if err := router.Run(":8080"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	authHandler := routes.NewAuthHandler(reloader, revocations)
+	routes.SetupRoutes(router, rt, authHandler, docs)
+	// 管理接口单独监听，没有配置管理令牌时不启动
+	var cleanups []func() error
+	if token := cfg.Auth.AdminToken; token != "" {
+		adminRouter := routes.NewAdminRouter(rt, errorCounter, audit, authHandler, token)
+		if err := adminRouter.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+			logger.Log.WithError(err).Fatal("Failed to set trusted proxies")
+		}
+		adminSrv := &http.Server{Addr: cfg.Admin.Addr, Handler: adminRouter}
+		go func() {
+			if err := adminSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Log.WithError(err).Fatal("Failed to start admin server")
+			}
+		}()
+		cleanups = append(cleanups, adminSrv.Close)
+		logger.Log.Info("Admin API listening on " + cfg.Admin.Addr)
+	} else {
+		logger.Log.Info("GATEWAY_ADMIN_TOKEN not set, admin API disabled")
+	}
+
+	// 启动服务。收到 SIGTERM 后先断开长连接让客户端重连到其它实例，
+	// 等正在处理的请求结束，再关闭上游连接池、注销记录和审计记录存储
+	logger.Log.Info("API Gateway starting on :8080")
+	srv := &http.Server{Addr: ":8080", Handler: router}
+	srv.RegisterOnShutdown(proxy.CloseStreams)
+	closeRevocations := func() error { return middleware.CloseRevocationStore(revocations) }
+	closeAudit := func() error { return middleware.CloseAuditStore(audit) }
+	cleanups = append(cleanups, rt.Close, closeRevocations, closeAudit)
+	if err := server.Run(srv, server.OptionsFromEnv(), cleanups...); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to start server")
 	}
 }
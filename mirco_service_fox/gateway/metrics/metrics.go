@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_requests_total",
+		Help: "Total number of requests handled by the gateway.",
+	}, []string{"route", "method", "status", "upstream"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_request_duration_seconds",
+		Help:    "Latency of requests handled by the gateway.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status", "upstream"})
+
+	// RateLimited 被限流拒绝的请求数
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_rate_limited_total",
+		Help: "Requests rejected by the rate limiter.",
+	}, []string{"class"})
+
+	// StreamConnections 当前打开的长连接数，kind 为 sse 或 websocket
+	StreamConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_stream_connections",
+		Help: "Open SSE and WebSocket connections proxied by the gateway.",
+	}, []string{"upstream", "kind"})
+
+	// StreamIdleClosed 因空闲超时被网关断开的长连接数
+	StreamIdleClosed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_stream_idle_closed_total",
+		Help: "Streaming connections closed by the gateway after the idle timeout.",
+	}, []string{"upstream", "kind"})
+
+	// InstanceEjections 因连续失败被摘除的上游实例次数
+	InstanceEjections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_instance_ejections_total",
+		Help: "Upstream instances ejected after consecutive failures.",
+	}, []string{"upstream"})
+
+	// AvailableInstances 上游当前可用（健康且未被摘除）的实例数
+	AvailableInstances = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_upstream_available_instances",
+		Help: "Upstream instances that are healthy and not ejected.",
+	}, []string{"upstream"})
+
+	// ConfigReloads 配置重新加载次数，trigger 为 file 或 signal，result 为 success 或 failure
+	ConfigReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_config_reloads_total",
+		Help: "Gateway config reload attempts.",
+	}, []string{"trigger", "result"})
+
+	// ConfigLastReloadSuccess 最近一次成功加载配置的时间
+	ConfigLastReloadSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_config_last_reload_success_timestamp_seconds",
+		Help: "Unix time of the last successful config load.",
+	})
+
+	// CircuitRejected 熔断期间直接拒绝的请求数
+	CircuitRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_circuit_rejected_total",
+		Help: "Requests rejected because the upstream circuit breaker is open.",
+	}, []string{"upstream"})
+
+	// ValidationFailures 不符合 OpenAPI 文档的请求数，report_only 时也计数
+	ValidationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_request_validation_failures_total",
+		Help: "Requests that do not match the merged OpenAPI document.",
+	}, []string{"route"})
+
+	// MirrorRequests 复制到影子上游的请求数，result 为 match、mismatch（与正式响应不同）、
+	// sent（不比较）或 skipped（请求体过大未复制）
+	MirrorRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_mirror_requests_total",
+		Help: "Requests mirrored to a shadow upstream, by comparison result.",
+	}, []string{"route", "result"})
+)
+
+// Middleware 记录请求数和耗时。
+// route 标签使用路由表中的路由名称（网关自身的端点使用 gin 路由模板），避免路径参数导致标签过多
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.GetString("route")
+		if route == "" {
+			route = c.FullPath()
+		}
+		if route == "" {
+			route = "unmatched"
+		}
+		labels := prometheus.Labels{
+			"route":    route,
+			"method":   c.Request.Method,
+			"status":   strconv.Itoa(c.Writer.Status()),
+			"upstream": c.GetString("upstream"),
+		}
+		requestsTotal.With(labels).Inc()
+		requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler /metrics 端点
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gateway/config"
+	"net/http"
+	"shared/identity"
+	"shared/logger"
+	"shared/tracing"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader 机器客户端携带 API Key 的请求头
+const APIKeyHeader = "X-API-Key"
+
+// 缓存的 Key 超过这个数量时不再缓存无效 Key，避免随机 Key 撑满内存
+const maxAPIKeyCacheEntries = 10000
+
+// ErrAPIKeyInvalid API Key 不存在、已作废或已过期
+var ErrAPIKeyInvalid = errors.New("api key invalid")
+
+// APIKey 校验通过的 API Key，请求按所属用户的身份转发
+type APIKey struct {
+	KeyID     uint      `json:"key_id"`
+	UserID    uint      `json:"user_id"`
+	Role      string    `json:"role"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HasScope 判断 Key 是否有权限范围 scope
+func (k *APIKey) HasScope(scope string) bool {
+	return scope != "" && slices.Contains(k.Scopes, scope)
+}
+
+// APIKeyFromContext 返回请求使用的 API Key，用令牌登录的请求返回 false
+func APIKeyFromContext(c *gin.Context) (*APIKey, bool) {
+	value, ok := c.Get("apiKey")
+	if !ok {
+		return nil, false
+	}
+	key, ok := value.(*APIKey)
+	return key, ok
+}
+
+type apiKeyEntry struct {
+	key       *APIKey // nil 表示无效
+	expiresAt time.Time
+}
+
+// APIKeyVerifier 调用 user-service 的内部接口校验 API Key。
+// 结果（包括无效）按 Key 的哈希缓存 cache_ttl，user-service 作废的 Key 最多在 cache_ttl 后失效
+type APIKeyVerifier struct {
+	upstreams    *config.Reloader // user-service 的地址跟随配置热加载
+	serviceToken string
+	ttl          time.Duration
+	client       *http.Client
+
+	mu        sync.Mutex
+	cache     map[string]apiKeyEntry
+	lastSweep time.Time
+}
+
+// NewAPIKeyVerifier 按启动配置创建校验器，auth.api_keys.enabled 为 false 时返回 nil
+func NewAPIKeyVerifier(cfg *config.Reloader) *APIKeyVerifier {
+	current := cfg.Current()
+	if !current.Auth.APIKeys.Enabled {
+		return nil
+	}
+	return &APIKeyVerifier{
+		upstreams:    cfg,
+		serviceToken: current.ServiceToken,
+		ttl:          current.Auth.APIKeys.CacheTTL,
+		client:       &http.Client{Timeout: 5 * time.Second, Transport: tracing.Transport(http.DefaultTransport)},
+		cache:        make(map[string]apiKeyEntry),
+		lastSweep:    time.Now(),
+	}
+}
+
+// Verify 校验 API Key，无效时返回 ErrAPIKeyInvalid，user-service 不可用时返回其它错误
+func (v *APIKeyVerifier) Verify(ctx context.Context, key string) (*APIKey, error) {
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	v.mu.Lock()
+	v.maybeSweep(now)
+	entry, ok := v.cache[hash]
+	v.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		if entry.key == nil {
+			return nil, ErrAPIKeyInvalid
+		}
+		return entry.key, nil
+	}
+
+	result, err := v.fetch(ctx, key)
+	if err != nil && !errors.Is(err, ErrAPIKeyInvalid) {
+		return nil, err
+	}
+	entry = apiKeyEntry{key: result, expiresAt: now.Add(v.ttl)}
+	if result != nil && result.ExpiresAt.Before(entry.expiresAt) {
+		entry.expiresAt = result.ExpiresAt
+	}
+	v.mu.Lock()
+	if result != nil || len(v.cache) < maxAPIKeyCacheEntries {
+		v.cache[hash] = entry
+	}
+	v.mu.Unlock()
+	return result, err
+}
+
+func (v *APIKeyVerifier) fetch(ctx context.Context, key string) (*APIKey, error) {
+	userServiceURL := strings.TrimSuffix(v.upstreams.Current().Upstreams["user"].Targets()[0], "/")
+	body, err := json.Marshal(map[string]string{"key": key})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, userServiceURL+"/internal/api-keys/verify", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(logger.RequestIDHeader, logger.RequestID(ctx))
+	req.Header.Set(identity.ServiceNameHeader, identity.GatewayName)
+	req.Header.Set(identity.ServiceTokenHeader, v.serviceToken)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrAPIKeyInvalid
+	default:
+		return nil, fmt.Errorf("user-service returned %d", resp.StatusCode)
+	}
+	var result struct {
+		Data APIKey `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode api key: %w", err)
+	}
+	if result.Data.UserID == 0 || result.Data.Role == "" {
+		return nil, errors.New("user-service returned api key without user")
+	}
+	return &result.Data, nil
+}
+
+// maybeSweep 每个缓存周期清理一次过期的条目，调用方需持有 v.mu
+func (v *APIKeyVerifier) maybeSweep(now time.Time) {
+	if now.Sub(v.lastSweep) < v.ttl {
+		return
+	}
+	for hash, entry := range v.cache {
+		if !now.Before(entry.expiresAt) {
+			delete(v.cache, hash)
+		}
+	}
+	v.lastSweep = now
+}
@@ -0,0 +1,376 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gateway/config"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"shared/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AuditPathPrefix 需要审计的接口前缀，其下除 GET/HEAD/OPTIONS 以外的请求都会记录
+const AuditPathPrefix = "/api/teacher/"
+
+const (
+	// 请求体、响应体最多读取这么多字节用于计算摘要和取资源 ID，更大的请求（如上传文件）只记录类型和大小
+	auditBodyLimit = 64 << 10
+	// 脱敏后的请求体摘要最多保留的字节数
+	auditDigestLimit = 1024
+	// 写审计记录的超时，请求已经处理完，不受客户端断开影响
+	auditWriteTimeout = 5 * time.Second
+)
+
+// AuditEntry 一条审计记录，写入后不再修改
+type AuditEntry struct {
+	ID         uint64    `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"time" gorm:"index"`
+	UserID     uint      `json:"user_id" gorm:"index"`
+	Role       string    `json:"role" gorm:"size:16"`
+	APIKeyID   uint      `json:"api_key_id,omitempty"` // 用 API Key 访问时的 Key ID
+	RequestID  string    `json:"request_id" gorm:"size:64"`
+	Method     string    `json:"method" gorm:"size:10"`
+	Path       string    `json:"path" gorm:"size:512"`
+	Route      string    `json:"route" gorm:"size:64"`
+	Resource   string    `json:"resource" gorm:"size:64;index:idx_audit_resource"`
+	ResourceID string    `json:"resource_id" gorm:"size:64;index:idx_audit_resource"`
+	Status     int       `json:"status"`
+	BodyDigest string    `json:"body_digest" gorm:"type:text"`         // 脱敏后的请求体（截断），不能解析的请求体只有类型和大小
+	BodySHA256 string    `json:"body_sha256,omitempty" gorm:"size:64"` // 原始请求体的 SHA-256，请求体过大时为空
+	ClientIP   string    `json:"client_ip" gorm:"size:64"`
+}
+
+// AuditQuery 查询条件，零值表示不限制；结果按时间倒序
+type AuditQuery struct {
+	UserID     uint
+	Resource   string
+	ResourceID string
+	From       time.Time
+	To         time.Time
+	BeforeID   uint64 // 只返回 ID 小于它的记录，用于翻页
+	Limit      int
+}
+
+// AuditStore 审计记录的存储，只能追加和查询
+type AuditStore interface {
+	Append(ctx context.Context, entry *AuditEntry) error
+	Query(ctx context.Context, q AuditQuery) ([]AuditEntry, error)
+}
+
+// MemoryAuditStore 进程内的审计记录，只在单个网关实例内可查，超过容量后丢弃最早的记录
+type MemoryAuditStore struct {
+	mu      sync.RWMutex
+	entries []AuditEntry // 环形缓冲区
+	start   int
+	nextID  uint64
+}
+
+func NewMemoryAuditStore(capacity int) *MemoryAuditStore {
+	return &MemoryAuditStore{entries: make([]AuditEntry, 0, capacity), nextID: 1}
+}
+
+func (s *MemoryAuditStore) Append(_ context.Context, entry *AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.ID = s.nextID
+	s.nextID++
+	if len(s.entries) < cap(s.entries) {
+		s.entries = append(s.entries, *entry)
+		return nil
+	}
+	s.entries[s.start] = *entry
+	s.start = (s.start + 1) % len(s.entries)
+	return nil
+}
+
+func (s *MemoryAuditStore) Query(_ context.Context, q AuditQuery) ([]AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]AuditEntry, 0)
+	for i := len(s.entries) - 1; i >= 0 && len(result) < q.Limit; i-- {
+		e := s.entries[(s.start+i)%len(s.entries)]
+		if q.matches(&e) {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+func (q *AuditQuery) matches(e *AuditEntry) bool {
+	return (q.UserID == 0 || e.UserID == q.UserID) &&
+		(q.Resource == "" || e.Resource == q.Resource) &&
+		(q.ResourceID == "" || e.ResourceID == q.ResourceID) &&
+		(q.From.IsZero() || !e.CreatedAt.Before(q.From)) &&
+		(q.To.IsZero() || e.CreatedAt.Before(q.To)) &&
+		(q.BeforeID == 0 || e.ID < q.BeforeID)
+}
+
+// NewAuditStore 按配置创建审计记录存储，审计关闭时返回 nil
+func NewAuditStore(cfg config.AuditConfig) (AuditStore, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	switch cfg.Store {
+	case config.AuditStoreMySQL:
+		return NewMySQLAuditStore(cfg.DSN)
+	default:
+		return NewMemoryAuditStore(cfg.MaxEntries), nil
+	}
+}
+
+// CloseAuditStore 释放存储占用的连接，内存存储无需关闭
+func CloseAuditStore(store AuditStore) error {
+	if c, ok := store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// AuditMiddleware 记录 /api/teacher 下的写操作：操作人、请求、目标资源、响应状态和脱敏后的请求体。
+// 需放在 AuthMiddleware 之后；写审计记录失败只输出错误日志，不影响请求
+func AuditMiddleware(store AuditStore, redactFields []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auditable(c.Request) {
+			c.Next()
+			return
+		}
+		now := time.Now()
+		path := c.Request.URL.Path // 转发时路径可能被重写，记录客户端请求的路径
+		digest, sum := captureRequestBody(c.Request, redactFields)
+		resource, resourceID := auditResource(path)
+		// 创建类的请求路径里没有 ID，从响应中取
+		var w *auditResponseWriter
+		if resourceID == "" {
+			w = &auditResponseWriter{ResponseWriter: c.Writer}
+			c.Writer = w
+		}
+
+		c.Next()
+
+		if w != nil {
+			resourceID = w.resourceID(resource)
+		}
+		entry := &AuditEntry{
+			CreatedAt:  now,
+			Role:       c.GetString("userRole"),
+			RequestID:  logger.RequestID(c.Request.Context()),
+			Method:     c.Request.Method,
+			Path:       path,
+			Route:      c.GetString("route"),
+			Resource:   resource,
+			ResourceID: resourceID,
+			Status:     c.Writer.Status(),
+			BodyDigest: digest,
+			BodySHA256: sum,
+			ClientIP:   c.ClientIP(),
+		}
+		if id, err := strconv.ParseUint(c.GetString("userID"), 10, 64); err == nil {
+			entry.UserID = uint(id)
+		}
+		if key, ok := APIKeyFromContext(c); ok {
+			entry.APIKeyID = key.KeyID
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), auditWriteTimeout)
+		defer cancel()
+		if err := store.Append(ctx, entry); err != nil {
+			// 存储不可用时至少在日志里留下记录
+			logger.FromContext(ctx).WithError(err).WithFields(logrus.Fields{
+				"user_id":     entry.UserID,
+				"method":      entry.Method,
+				"path":        entry.Path,
+				"resource":    entry.Resource,
+				"resource_id": entry.ResourceID,
+				"status":      entry.Status,
+			}).Error("Failed to write audit entry")
+		}
+	}
+}
+
+func auditable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return strings.HasPrefix(r.URL.Path, AuditPathPrefix)
+}
+
+// auditResource 从路径中取资源类型和 ID：/api/teacher/experiments/<id>/uploadFile 为 experiments、<id>，
+// /api/teacher/experiments/notifications 为 notifications、空
+func auditResource(path string) (resource, id string) {
+	for _, seg := range strings.Split(strings.TrimPrefix(path, AuditPathPrefix), "/") {
+		if seg == "" {
+			continue
+		}
+		if resource != "" && isResourceID(seg) {
+			return resource, seg
+		}
+		resource = seg
+	}
+	return resource, ""
+}
+
+// isResourceID 数字 ID 或 UUID
+func isResourceID(seg string) bool {
+	if _, err := strconv.ParseUint(seg, 10, 64); err == nil {
+		return true
+	}
+	return len(seg) == 36 && strings.Count(seg, "-") == 4
+}
+
+// captureRequestBody 读取请求体计算摘要，读过的部分放回请求中继续转发
+func captureRequestBody(r *http.Request, redactFields []string) (digest, sum string) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return "", ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	buf, _ := io.ReadAll(io.LimitReader(r.Body, auditBodyLimit+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if len(buf) > auditBodyLimit {
+		if r.ContentLength > 0 {
+			return fmt.Sprintf("<%s, %d bytes>", mediaType, r.ContentLength), ""
+		}
+		return fmt.Sprintf("<%s, more than %d bytes>", mediaType, auditBodyLimit), ""
+	}
+	if len(buf) == 0 {
+		return "", ""
+	}
+	hash := sha256.Sum256(buf)
+	return redactBody(mediaType, buf, redactFields), hex.EncodeToString(hash[:])
+}
+
+// redactBody 把 JSON 和表单请求体中的敏感字段替换为 "***" 并截断，其它类型只记录类型和大小
+func redactBody(mediaType string, body []byte, fields []string) string {
+	var out string
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if dec.Decode(&v) != nil {
+			return fmt.Sprintf("<invalid json, %d bytes>", len(body))
+		}
+		b, err := json.Marshal(redactValue(v, fields))
+		if err != nil {
+			return fmt.Sprintf("<invalid json, %d bytes>", len(body))
+		}
+		out = string(b)
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return fmt.Sprintf("<invalid form, %d bytes>", len(body))
+		}
+		for k := range values {
+			if isSensitiveField(k, fields) {
+				values[k] = []string{"***"}
+			}
+		}
+		out = values.Encode()
+	default:
+		return fmt.Sprintf("<%s, %d bytes>", mediaType, len(body))
+	}
+	if len(out) > auditDigestLimit {
+		cut := auditDigestLimit
+		for cut > 0 && !utf8.RuneStart(out[cut]) {
+			cut--
+		}
+		out = out[:cut] + "..."
+	}
+	return out
+}
+
+func redactValue(v any, fields []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if isSensitiveField(k, fields) {
+				v[k] = "***"
+			} else {
+				v[k] = redactValue(child, fields)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactValue(child, fields)
+		}
+	}
+	return v
+}
+
+func isSensitiveField(name string, fields []string) bool {
+	name = strings.ToLower(name)
+	for _, f := range fields {
+		if f != "" && strings.Contains(name, strings.ToLower(f)) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditResponseWriter 保留成功响应的开头部分，用于取新建资源的 ID
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditResponseWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *auditResponseWriter) capture(b []byte) {
+	if w.Status() >= 300 || w.buf.Len() > auditBodyLimit {
+		return
+	}
+	w.buf.Write(b[:min(len(b), auditBodyLimit+1-w.buf.Len())])
+}
+
+// resourceID 从 JSON 响应的顶层或 data 中取 <资源单数>_id 或 id 字段
+func (w *auditResponseWriter) resourceID(resource string) string {
+	if w.buf.Len() == 0 || w.buf.Len() > auditBodyLimit {
+		return ""
+	}
+	var obj map[string]any
+	dec := json.NewDecoder(&w.buf)
+	dec.UseNumber()
+	if dec.Decode(&obj) != nil {
+		return ""
+	}
+	keys := []string{strings.ReplaceAll(strings.TrimSuffix(resource, "s"), "-", "_") + "_id", "id"}
+	for _, scope := range []any{obj["data"], obj} {
+		m, ok := scope.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, key := range keys {
+			switch id := m[key].(type) {
+			case string:
+				return id
+			case json.Number:
+				return id.String()
+			}
+		}
+	}
+	return ""
+}
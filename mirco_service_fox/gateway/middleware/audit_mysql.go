@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// MySQLAuditStore 保存在 MySQL 中的审计记录，多个网关实例共享。
+// 只有插入和查询，清理历史记录由运维在数据库中按时间归档
+type MySQLAuditStore struct {
+	db *gorm.DB
+}
+
+func NewMySQLAuditStore(dsn string) (*MySQLAuditStore, error) {
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Error),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect audit db: %w", err)
+	}
+	if err := db.AutoMigrate(&AuditEntry{}); err != nil {
+		return nil, fmt.Errorf("migrate audit db: %w", err)
+	}
+	return &MySQLAuditStore{db: db}, nil
+}
+
+// Close 关闭数据库连接池
+func (s *MySQLAuditStore) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+func (s *MySQLAuditStore) Append(ctx context.Context, entry *AuditEntry) error {
+	return s.db.WithContext(ctx).Create(entry).Error
+}
+
+func (s *MySQLAuditStore) Query(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	db := s.db.WithContext(ctx)
+	if q.UserID != 0 {
+		db = db.Where("user_id = ?", q.UserID)
+	}
+	if q.Resource != "" {
+		db = db.Where("resource = ?", q.Resource)
+	}
+	if q.ResourceID != "" {
+		db = db.Where("resource_id = ?", q.ResourceID)
+	}
+	if !q.From.IsZero() {
+		db = db.Where("created_at >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		db = db.Where("created_at < ?", q.To)
+	}
+	if q.BeforeID != 0 {
+		db = db.Where("id < ?", q.BeforeID)
+	}
+	entries := make([]AuditEntry, 0)
+	err := db.Order("id DESC").Limit(q.Limit).Find(&entries).Error
+	return entries, err
+}
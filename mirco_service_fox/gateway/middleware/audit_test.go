@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testRedactFields = []string{"password", "token", "secret", "api_key"}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		mediaType string
+		body      string
+		want      string
+	}{
+		{"json top level", "application/json", `{"name":"alice","password":"p@ss"}`, `{"name":"alice","password":"***"}`},
+		{"json field name contains a sensitive word", "application/json", `{"oldPassword":"a","refresh_token":"b","API_KEY":"c"}`, `{"API_KEY":"***","oldPassword":"***","refresh_token":"***"}`},
+		{"json nested objects and arrays", "application/json", `{"users":[{"id":1,"secret":{"k":"v"}}]}`, `{"users":[{"id":1,"secret":"***"}]}`},
+		{"json numbers kept as written", "application/json", `{"score":12345678901234567890}`, `{"score":12345678901234567890}`},
+		{"json suffix media type", "application/merge-patch+json", `{"token":"x"}`, `{"token":"***"}`},
+		{"invalid json", "application/json", `{"password":`, "<invalid json, 12 bytes>"},
+		{"form", "application/x-www-form-urlencoded", "telephone=138&password=p%40ss", "password=%2A%2A%2A&telephone=138"},
+		{"other media type", "multipart/form-data", "--boundary", "<multipart/form-data, 10 bytes>"},
+		{"truncated", "application/json", `{"note":"` + strings.Repeat("a", auditDigestLimit) + `"}`, `{"note":"` + strings.Repeat("a", auditDigestLimit-len(`{"note":"`)) + "..."},
+		{"truncated on a rune boundary", "application/json", `{"note":"` + strings.Repeat("实", auditDigestLimit) + `"}`, `{"note":"` + strings.Repeat("实", (auditDigestLimit-len(`{"note":"`))/3) + "..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactBody(tt.mediaType, []byte(tt.body), testRedactFields); got != tt.want {
+				t.Errorf("redactBody() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		response    string
+		want        *AuditEntry // nil 表示不记录
+	}{
+		{
+			name: "update", method: http.MethodPut, path: "/api/teacher/experiments/12",
+			contentType: "application/json", body: `{"title":"t","secret":"s"}`, response: `{"status":"success"}`,
+			want: &AuditEntry{Resource: "experiments", ResourceID: "12", BodyDigest: `{"secret":"***","title":"t"}`},
+		},
+		{
+			name: "create takes the id from the response", method: http.MethodPost, path: "/api/teacher/experiments",
+			contentType: "application/json", body: `{"title":"t"}`, response: `{"status":"success","data":{"experiment_id":"abc"}}`,
+			want: &AuditEntry{Resource: "experiments", ResourceID: "abc", BodyDigest: `{"title":"t"}`},
+		},
+		{
+			name: "nested resource", method: http.MethodDelete, path: "/api/teacher/experiments/notifications/7",
+			want: &AuditEntry{Resource: "notifications", ResourceID: "7"},
+		},
+		{name: "read", method: http.MethodGet, path: "/api/teacher/experiments/12"},
+		{name: "not a teacher api", method: http.MethodPost, path: "/api/auth/login", contentType: "application/json", body: `{"password":"p"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryAuditStore(10)
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				c.Set("userID", "5")
+				c.Set("userRole", "teacher")
+			}, AuditMiddleware(store, testRedactFields))
+			r.Any("/*path", func(c *gin.Context) {
+				// 上游收到的请求体不应被脱敏
+				if body, _ := io.ReadAll(c.Request.Body); string(body) != tt.body {
+					t.Errorf("upstream got body %s, want %s", body, tt.body)
+				}
+				c.Data(http.StatusOK, "application/json", []byte(tt.response))
+			})
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			entries, _ := store.Query(context.Background(), AuditQuery{Limit: 10})
+			if tt.want == nil {
+				if len(entries) != 0 {
+					t.Errorf("recorded %+v, want nothing", entries)
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("recorded %d entries, want 1", len(entries))
+			}
+			got := entries[0]
+			if got.UserID != 5 || got.Role != "teacher" || got.Method != tt.method || got.Path != tt.path || got.Status != http.StatusOK ||
+				got.Resource != tt.want.Resource || got.ResourceID != tt.want.ResourceID || got.BodyDigest != tt.want.BodyDigest {
+				t.Errorf("recorded %+v, want %+v", got, tt.want)
+			}
+			if (got.BodySHA256 != "") != (tt.body != "") {
+				t.Errorf("body_sha256 = %q for body %q", got.BodySHA256, tt.body)
+			}
+		})
+	}
+}
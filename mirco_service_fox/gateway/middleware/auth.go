@@ -1,98 +1,184 @@
 package middleware
 
 import (
-	"context"
-	"log"
+	"errors"
+	"gateway/proxy"
 	"net/http"
-	"os"
+	"shared/identity"
+	"shared/logger"
 	"strconv"
 	"strings"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
-type contextKey string
+// Claims token的claim结构
+type Claims struct {
+	UserID     uint   `json:"user_id"`
+	Role       string `json:"role"`
+	IssuedAtMs int64  `json:"iat_ms"` // 毫秒精度的签发时间，之前签发的令牌没有
+	jwt.StandardClaims
+}
 
+// LogoutPath 注销接口，需要携带令牌
+const LogoutPath = "/api/auth/logout"
+
+// publicAuthPaths 登录前调用的认证接口；/api/auth 下的其它接口（个人信息、修改资料、注销）需要登录
+var publicAuthPaths = map[string]bool{
+	"/api/auth/login":    true,
+	"/api/auth/register": true,
+	"/api/auth/refresh":  true,
+}
+
+// PublicPath 不需要登录的路径，包括接口文档
+func PublicPath(path string) bool {
+	return path == "/health" || strings.HasPrefix(path, "/health/") || path == "/metrics" ||
+		path == "/openapi.json" || path == "/docs" || publicAuthPaths[path]
+}
+
+// TokenQueryParam、TokenSubprotocolPrefix 长连接握手时携带令牌的查询参数和子协议前缀
 const (
-	userIDKey   contextKey = "userID"
-	userRoleKey contextKey = "userRole"
+	TokenQueryParam        = "access_token"
+	TokenSubprotocolPrefix = "access_token."
 )
 
-// JWT密钥（应与用户服务中的密钥一致）
-func getJWTKey() []byte {
-	key := os.Getenv("JWT_SECRET")
-	if key == "" {
-		return []byte("a_secret_crect") // 默认密钥，生产环境不建议使用默认值
+// extractToken 从 Authorization 头取令牌。浏览器的 WebSocket 和 EventSource 不能设置请求头，
+// 这两种握手请求也可以把令牌放在 access_token 查询参数或 "access_token.<令牌>" 子协议中，
+// 取出后从请求中删除，不转发给上游，也不会出现在日志里。
+func extractToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return h[7:]
 	}
-	return []byte(key)
-}
-
-// Claims token的claim结构
-type Claims struct {
-	UserID uint   `json:"user_id"`
-	Role   string `json:"role"`
-	jwt.StandardClaims
+	kind, ok := proxy.StreamKind(r)
+	if !ok {
+		return ""
+	}
+	if kind == proxy.StreamWebSocket {
+		var token string
+		var rest []string
+		for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+			for _, p := range strings.Split(v, ",") {
+				p = strings.TrimSpace(p)
+				if strings.HasPrefix(p, TokenSubprotocolPrefix) {
+					token = strings.TrimPrefix(p, TokenSubprotocolPrefix)
+				} else if p != "" {
+					rest = append(rest, p)
+				}
+			}
+		}
+		if token != "" {
+			r.Header.Del("Sec-WebSocket-Protocol")
+			if len(rest) > 0 {
+				r.Header.Set("Sec-WebSocket-Protocol", strings.Join(rest, ", "))
+			}
+			return token
+		}
+	}
+	query := r.URL.Query()
+	if token := query.Get(TokenQueryParam); token != "" {
+		query.Del(TokenQueryParam)
+		r.URL.RawQuery = query.Encode()
+		r.RequestURI = r.URL.RequestURI()
+		return token
+	}
+	return ""
 }
 
-// AuthMiddleware 认证中间件
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware 认证中间件，用 user-service 发布的公钥校验令牌签名、有效期以及是否已注销；
+// 没有令牌但带 X-API-Key 的请求交给 apiKeys 校验，apiKeys 为 nil 时不接受 API Key
+func AuthMiddleware(keys *JWKSCache, revocations RevocationStore, apiKeys *APIKeyVerifier) gin.HandlerFunc {
+	parser := &jwt.Parser{ValidMethods: validSigningMethods}
 	return func(c *gin.Context) {
-		if c.Request.URL.Path == "/health" || strings.HasPrefix(c.Request.URL.Path, "/api/auth") {
+		// API Key 只在网关校验，不转发给上游
+		apiKey := c.GetHeader(APIKeyHeader)
+		c.Request.Header.Del(APIKeyHeader)
+		if PublicPath(c.Request.URL.Path) {
 			c.Next()
 			return
 		}
-		// 获取authorization header
-		tokenString := c.GetHeader("Authorization")
-		log.Printf("Authorization header: %s", tokenString) // 添加日志
-		// 验证token格式
-		if tokenString == "" || !strings.HasPrefix(tokenString, "Bearer ") {
+		if apiKey != "" && c.GetHeader("Authorization") == "" {
+			authenticateAPIKey(c, apiKeys, apiKey)
+			return
+		}
+		log := logger.FromContext(c.Request.Context())
+		tokenString := extractToken(c.Request)
+		if tokenString == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "权限不足"})
 			c.Abort()
 			return
 		}
 
-		// 提取token的有效部分
-		tokenString = tokenString[7:]
-
 		// 解析token
 		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return getJWTKey(), nil
-		})
+		token, err := parser.ParseWithClaims(tokenString, claims, keys.Keyfunc(c.Request.Context()))
 		if err != nil {
-			log.Printf("Token parsing error: %v", err) // 添加日志
+			log.WithError(err).Warn("Token parsing error")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "权限不足"})
 			c.Abort()
 			return
 		}
 
 		if !token.Valid {
-			log.Printf("Invalid token: %s", tokenString) // 添加日志
+			log.Warn("Invalid token")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "权限不足"})
 			c.Abort()
 			return
 		}
-		log.Printf("Token validated successfully. UserID: %d, Role: %s", claims.UserID, claims.Role) // 添加日志
-		// 检查路径和角色权限
-		path := c.Request.URL.Path
-		if strings.HasPrefix(path, "/api/teacher") && claims.Role != "teacher" {
-			c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "需要教师权限"})
+
+		// 检查令牌是否已注销
+		revoked, err := IsRevoked(c.Request.Context(), revocations, claims)
+		if err != nil {
+			// 注销记录查不到时拒绝请求，避免已注销的令牌继续可用
+			log.WithError(err).Error("Revocation store error")
+			c.JSON(http.StatusServiceUnavailable, gin.H{"code": 503, "message": "认证服务暂不可用，请稍后再试"})
 			c.Abort()
 			return
 		}
-
-		if strings.HasPrefix(path, "/api/student") && claims.Role != "student" {
-			c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "需要学生权限"})
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "登录已失效，请重新登录"})
 			c.Abort()
 			return
 		}
-		// 将用户信息添加到请求头中
+		log.WithFields(logrus.Fields{"user_id": claims.UserID, "role": claims.Role}).Debug("Token validated successfully")
+		// 角色权限由路由表中每条路由的 role 字段检查
+		// 用户信息放进请求上下文，转发时签成内部身份断言
 		c.Set("userID", string(strconv.FormatUint(uint64(claims.UserID), 10)))
 		c.Set("userRole", claims.Role)
-		ctx := context.WithValue(c.Request.Context(), userIDKey, strconv.FormatUint(uint64(claims.UserID), 10))
-		ctx = context.WithValue(ctx, userRoleKey, claims.Role)
+		c.Set("claims", claims)
+		ctx := identity.WithIdentity(c.Request.Context(), identity.Identity{UserID: claims.UserID, Role: claims.Role})
 		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
+
+// authenticateAPIKey 校验 API Key，按所属用户的身份继续处理请求；
+// 能访问哪些路由由路由表中的 scopes 决定
+func authenticateAPIKey(c *gin.Context, verifier *APIKeyVerifier, rawKey string) {
+	if verifier == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "未启用 API Key 认证"})
+		c.Abort()
+		return
+	}
+	log := logger.FromContext(c.Request.Context())
+	key, err := verifier.Verify(c.Request.Context(), rawKey)
+	if errors.Is(err, ErrAPIKeyInvalid) {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "API Key 无效或已过期"})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("API key verification error")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"code": 503, "message": "认证服务暂不可用，请稍后再试"})
+		c.Abort()
+		return
+	}
+	log.WithFields(logrus.Fields{"user_id": key.UserID, "key_id": key.KeyID}).Debug("API key validated successfully")
+	c.Set("userID", strconv.FormatUint(uint64(key.UserID), 10))
+	c.Set("userRole", key.Role)
+	c.Set("apiKey", key)
+	ctx := identity.WithIdentity(c.Request.Context(), identity.Identity{UserID: key.UserID, Role: key.Role})
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtractToken(t *testing.T) {
+	websocket := map[string]string{"Connection": "Upgrade", "Upgrade": "websocket"}
+	sse := map[string]string{"Accept": "text/event-stream"}
+	with := func(base map[string]string, k, v string) map[string]string {
+		h := map[string]string{k: v}
+		for name, value := range base {
+			h[name] = value
+		}
+		return h
+	}
+	tests := []struct {
+		name         string
+		target       string
+		headers      map[string]string
+		want         string
+		wantURI      string // 取出令牌后转发给上游的 RequestURI
+		wantProtocol string // 取出令牌后剩下的子协议
+	}{
+		{"bearer header", "/api/x?access_token=q", map[string]string{"Authorization": "Bearer h"}, "h", "/api/x?access_token=q", ""},
+		{"query on plain request is ignored", "/api/x?access_token=q", nil, "", "/api/x?access_token=q", ""},
+		{"query on SSE", "/api/events?access_token=q&since=1", sse, "q", "/api/events?since=1", ""},
+		{"query on WebSocket", "/ws?access_token=q", websocket, "q", "/ws", ""},
+		{"subprotocol", "/ws", with(websocket, "Sec-WebSocket-Protocol", "access_token.s"), "s", "/ws", ""},
+		{"subprotocol keeps the others", "/ws", with(websocket, "Sec-WebSocket-Protocol", "chat, access_token.s, json"), "s", "/ws", "chat, json"},
+		// 子协议和查询参数都有时用子协议，查询参数保留
+		{"subprotocol wins over query", "/ws?access_token=q", with(websocket, "Sec-WebSocket-Protocol", "access_token.s"), "s", "/ws?access_token=q", ""},
+		{"subprotocol on SSE is ignored", "/api/events", with(sse, "Sec-WebSocket-Protocol", "access_token.s"), "", "/api/events", "access_token.s"},
+		{"empty query value", "/ws?access_token=", websocket, "", "/ws?access_token=", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := extractToken(r); got != tt.want {
+				t.Errorf("extractToken() = %q, want %q", got, tt.want)
+			}
+			if r.RequestURI != tt.wantURI || r.URL.RequestURI() != tt.wantURI {
+				t.Errorf("RequestURI = %q, URL = %q, want %q", r.RequestURI, r.URL.RequestURI(), tt.wantURI)
+			}
+			if got := r.Header.Get("Sec-WebSocket-Protocol"); got != tt.wantProtocol {
+				t.Errorf("Sec-WebSocket-Protocol = %q, want %q", got, tt.wantProtocol)
+			}
+		})
+	}
+}
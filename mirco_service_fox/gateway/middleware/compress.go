@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"gateway/config"
+	"gateway/proxy"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// 支持的压缩方式，客户端同时接受时优先 br
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// br 使用较低的压缩级别，JSON 的压缩率已经足够，CPU 开销比默认级别小很多
+const brotliLevel = 4
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var compressorPools = map[string]*sync.Pool{
+	encodingBrotli: {New: func() any { return brotli.NewWriterLevel(io.Discard, brotliLevel) }},
+	encodingGzip:   {New: func() any { return gzip.NewWriter(io.Discard) }},
+}
+
+// CompressionMiddleware 按 Accept-Encoding 压缩 2xx 响应，配置取自当前的 compression 段，支持热加载。
+// 上游已经压缩过的响应、SSE 和 WebSocket 不处理
+func CompressionMiddleware(reloader *config.Reloader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := reloader.Current().Compression
+		if !cfg.Enabled || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		if _, ok := proxy.StreamKind(c.Request); ok {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, cfg: cfg}
+		c.Writer = w
+		defer w.finish()
+		c.Next()
+	}
+}
+
+// negotiateEncoding 按 q 值选择压缩方式，都不接受时返回空字符串
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if name == "*" {
+			name = encodingGzip
+		}
+		if _, ok := compressorPools[name]; !ok || q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == encodingBrotli) {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter 先缓存响应开头的 min_size 字节，够大且类型匹配才压缩，否则原样写出
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	cfg      config.CompressionConfig
+	buf      []byte
+	decided  bool
+	enc      compressor // nil 表示不压缩
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if !w.compressible() {
+			w.decide(false)
+		} else {
+			w.buf = append(w.buf, b...)
+			if len(w.buf) < int(w.cfg.MinSize) {
+				return len(b), nil
+			}
+			if err := w.decide(true); err != nil {
+				return 0, err
+			}
+			return len(b), nil
+		}
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 还在缓存时不刷新，JSON 响应不需要边收边发
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		return
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// compressible 响应状态、类型和上游的 Content-Encoding、Content-Length 是否允许压缩
+func (w *compressWriter) compressible() bool {
+	status := w.Status()
+	if status < 200 || status >= 300 || status == http.StatusNoContent || status == http.StatusPartialContent {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < int(w.cfg.MinSize) {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	for _, t := range w.cfg.ContentTypes {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide 确定是否压缩并写出缓存的内容
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		h := w.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		h.Add("Vary", "Accept-Encoding")
+		// 压缩后的内容与原始内容字节不同，强 ETag 改为弱 ETag
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.enc = compressorPools[w.encoding].Get().(compressor)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// finish 写出不足 min_size 的响应，或结束压缩流
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if len(w.buf) > 0 && w.compressible() {
+			// 响应不足 min_size，原样写出
+			w.Header().Add("Vary", "Accept-Encoding")
+		}
+		w.decide(false)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(io.Discard)
+		compressorPools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
@@ -1,14 +1,25 @@
 package middleware
 
-import "github.com/gin-gonic/gin"
+import (
+	"gateway/config"
+	"strings"
 
-// CORSMiddleware 处理跨域请求
-func CORSMiddleware() gin.HandlerFunc {
+	"github.com/gin-gonic/gin"
+)
+
+// CORSMiddleware 处理跨域请求，允许的来源取自当前配置的 cors.allowed_origins，支持热加载
+func CORSMiddleware(reloader *config.Reloader) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		origins := reloader.Current().CORS.AllowedOrigins
+		if origin := allowedOrigin(origins, c.GetHeader("Origin")); origin != "" {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID, If-None-Match")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+			if origin != "*" {
+				c.Writer.Header().Add("Vary", "Origin")
+			}
+		}
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -18,3 +29,16 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// allowedOrigin 返回应写入 Access-Control-Allow-Origin 的值，来源不在允许列表中时返回空字符串
+func allowedOrigin(allowed []string, origin string) string {
+	for _, o := range allowed {
+		if o == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 错误计数保留的分钟数
+const errorWindowMinutes = 15
+
+// StatusCounts 一段时间内的错误响应数
+type StatusCounts struct {
+	ClientErrors int64 `json:"4xx"`
+	ServerErrors int64 `json:"5xx"`
+}
+
+func (s *StatusCounts) add(o StatusCounts) {
+	s.ClientErrors += o.ClientErrors
+	s.ServerErrors += o.ServerErrors
+}
+
+// ErrorCount 一个路由最近的错误响应数
+type ErrorCount struct {
+	Route    string       `json:"route"`
+	Upstream string       `json:"upstream,omitempty"`
+	Last1m   StatusCounts `json:"last_1m"`
+	Last5m   StatusCounts `json:"last_5m"`
+	Last15m  StatusCounts `json:"last_15m"`
+}
+
+type errorKey struct {
+	route    string
+	upstream string
+}
+
+type errorBucket struct {
+	minute int64
+	counts map[errorKey]StatusCounts
+}
+
+// ErrorCounter 按路由统计最近 15 分钟的 4xx、5xx 响应数，每分钟一个桶，供管理接口查看
+type ErrorCounter struct {
+	mu      sync.Mutex
+	buckets [errorWindowMinutes]errorBucket
+	now     func() time.Time
+}
+
+func NewErrorCounter() *ErrorCounter {
+	return &ErrorCounter{now: time.Now}
+}
+
+// Record 记录一次响应，2xx、3xx 不计数
+func (c *ErrorCounter) Record(route, upstream string, status int) {
+	if status < 400 {
+		return
+	}
+	minute := c.now().Unix() / 60
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := &c.buckets[minute%errorWindowMinutes]
+	if b.minute != minute || b.counts == nil {
+		b.minute = minute
+		b.counts = make(map[errorKey]StatusCounts)
+	}
+	key := errorKey{route: route, upstream: upstream}
+	counts := b.counts[key]
+	if status >= 500 {
+		counts.ServerErrors++
+	} else {
+		counts.ClientErrors++
+	}
+	b.counts[key] = counts
+}
+
+// Snapshot 返回每个路由最近 1、5、15 分钟的错误数，按路由名排序
+func (c *ErrorCounter) Snapshot() []ErrorCount {
+	minute := c.now().Unix() / 60
+	result := make(map[errorKey]*ErrorCount)
+	c.mu.Lock()
+	for i := range c.buckets {
+		b := &c.buckets[i]
+		age := minute - b.minute
+		if b.counts == nil || age < 0 || age >= errorWindowMinutes {
+			continue
+		}
+		for key, counts := range b.counts {
+			ec, ok := result[key]
+			if !ok {
+				ec = &ErrorCount{Route: key.route, Upstream: key.upstream}
+				result[key] = ec
+			}
+			ec.Last15m.add(counts)
+			if age < 5 {
+				ec.Last5m.add(counts)
+			}
+			if age < 1 {
+				ec.Last1m.add(counts)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	list := make([]ErrorCount, 0, len(result))
+	for _, ec := range result {
+		list = append(list, *ec)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Route != list[j].Route {
+			return list[i].Route < list[j].Route
+		}
+		return list[i].Upstream < list[j].Upstream
+	})
+	return list
+}
+
+// ErrorCountMiddleware 请求结束后按路由记录错误响应，路由名的取法与请求指标一致
+func ErrorCountMiddleware(counter *ErrorCounter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		route := c.GetString("route")
+		if route == "" {
+			route = c.FullPath()
+		}
+		if route == "" {
+			route = "unmatched"
+		}
+		counter.Record(route, c.GetString("upstream"), c.Writer.Status())
+	}
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"gateway/config"
+	"gateway/proxy"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETagMiddleware 给 GET 请求的 200 响应加 ETag，If-None-Match 匹配时返回不带响应体的 304。
+// 上游返回了 ETag 时直接使用；否则缓存响应体计算，超过 etag.max_body_size 的响应不处理。
+// 需放在 CompressionMiddleware 之后，ETag 按压缩前的内容计算
+func ETagMiddleware(reloader *config.Reloader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := reloader.Current().ETag
+		if !cfg.Enabled || c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		if _, ok := proxy.StreamKind(c.Request); ok {
+			c.Next()
+			return
+		}
+		w := &etagWriter{ResponseWriter: c.Writer, ifNoneMatch: c.GetHeader("If-None-Match"), limit: int(cfg.MaxBodySize)}
+		c.Writer = w
+		defer w.finish()
+		c.Next()
+	}
+}
+
+type etagMode int
+
+const (
+	etagUndecided   etagMode = iota
+	etagPassthrough          // 不处理，原样写出
+	etagBuffering            // 缓存响应体，结束时计算 ETag
+	etagNotModified          // 上游的 ETag 已匹配，丢弃响应体
+)
+
+type etagWriter struct {
+	gin.ResponseWriter
+	ifNoneMatch string
+	limit       int
+	mode        etagMode
+	buf         bytes.Buffer
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if w.mode == etagUndecided {
+		w.decide()
+	}
+	switch w.mode {
+	case etagNotModified:
+		return len(b), nil
+	case etagBuffering:
+		if w.buf.Len()+len(b) <= w.limit {
+			return w.buf.Write(b)
+		}
+		// 响应太大，放弃计算，已缓存的部分先写出
+		w.mode = etagPassthrough
+		if _, err := w.ResponseWriter.Write(w.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		w.buf.Reset()
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *etagWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 缓存期间不刷新
+func (w *etagWriter) Flush() {
+	if w.mode == etagPassthrough {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// decide 按响应头决定处理方式，在第一次写响应体或请求结束时调用
+func (w *etagWriter) decide() {
+	h := w.Header()
+	switch {
+	case w.Status() != http.StatusOK:
+		w.mode = etagPassthrough
+	case h.Get("ETag") != "":
+		// 使用上游的 ETag
+		if etagMatches(w.ifNoneMatch, h.Get("ETag")) {
+			w.notModified()
+			w.mode = etagNotModified
+		} else {
+			w.mode = etagPassthrough
+		}
+	default:
+		if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n > w.limit {
+			w.mode = etagPassthrough
+		} else {
+			w.mode = etagBuffering
+		}
+	}
+}
+
+// notModified 把响应改为 304，保留 ETag、Cache-Control、Vary 等响应头
+func (w *etagWriter) notModified() {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Del("Content-Type")
+	w.ResponseWriter.WriteHeader(http.StatusNotModified)
+}
+
+func (w *etagWriter) finish() {
+	if w.mode == etagUndecided {
+		w.decide()
+	}
+	if w.mode != etagBuffering {
+		return
+	}
+	sum := sha256.Sum256(w.buf.Bytes())
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	h := w.Header()
+	h.Set("ETag", etag)
+	// 响应按用户不同，只允许浏览器缓存，每次使用前用 If-None-Match 验证
+	if h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", "private, no-cache")
+	}
+	if etagMatches(w.ifNoneMatch, etag) {
+		w.notModified()
+		return
+	}
+	w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+// etagMatches If-None-Match 按弱比较匹配，压缩时强 ETag 会被改为弱 ETag
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
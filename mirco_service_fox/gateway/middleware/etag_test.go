@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"gateway/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// testETag 网关按响应体计算的 ETag
+func testETag(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+func decodeBody(t *testing.T, encoding string, body io.Reader) string {
+	t.Helper()
+	var r io.Reader = body
+	switch encoding {
+	case "gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	case "br":
+		r = brotli.NewReader(body)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCompressionAndETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reloader := config.NewReloader(&config.ServiceConfig{
+		Compression: config.CompressionConfig{Enabled: true, MinSize: 100, ContentTypes: []string{"application/json"}},
+		ETag:        config.ETagConfig{Enabled: true, MaxBodySize: 1024},
+	})
+	large := `{"data":"` + strings.Repeat("a", 200) + `"}`
+	small := `{"data":"a"}`
+	huge := `{"data":"` + strings.Repeat("a", 2000) + `"}`
+
+	tests := []struct {
+		name           string
+		body           string
+		upstreamETag   string
+		acceptEncoding string
+		ifNoneMatch    string
+		status         int
+		etag           string // 为空表示不带 ETag
+		encoding       string
+	}{
+		{name: "plain", body: large, status: http.StatusOK, etag: testETag(large)},
+		{name: "gzip turns the etag weak", body: large, acceptEncoding: "gzip", status: http.StatusOK, etag: "W/" + testETag(large), encoding: "gzip"},
+		{name: "br turns the etag weak", body: large, acceptEncoding: "br", status: http.StatusOK, etag: "W/" + testETag(large), encoding: "br"},
+		{name: "weak etag revalidates a compressed response", body: large, acceptEncoding: "gzip", ifNoneMatch: "W/" + testETag(large), status: http.StatusNotModified, etag: testETag(large)},
+		{name: "strong etag revalidates a compressed response", body: large, acceptEncoding: "gzip", ifNoneMatch: testETag(large), status: http.StatusNotModified, etag: testETag(large)},
+		{name: "weak etag revalidates a plain response", body: large, ifNoneMatch: "W/" + testETag(large), status: http.StatusNotModified, etag: testETag(large)},
+		{name: "one of several etags", body: large, ifNoneMatch: `"other", ` + testETag(large), status: http.StatusNotModified, etag: testETag(large)},
+		{name: "stale etag", body: large, acceptEncoding: "gzip", ifNoneMatch: `"other"`, status: http.StatusOK, etag: "W/" + testETag(large), encoding: "gzip"},
+		{name: "too small to compress", body: small, acceptEncoding: "gzip", status: http.StatusOK, etag: testETag(small)},
+		{name: "too large for an etag", body: huge, acceptEncoding: "gzip", status: http.StatusOK, encoding: "gzip"},
+		{name: "upstream etag", body: large, upstreamETag: `"v1"`, acceptEncoding: "gzip", status: http.StatusOK, etag: `W/"v1"`, encoding: "gzip"},
+		{name: "upstream etag matched", body: large, upstreamETag: `"v1"`, acceptEncoding: "gzip", ifNoneMatch: `W/"v1"`, status: http.StatusNotModified, etag: `"v1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(CompressionMiddleware(reloader), ETagMiddleware(reloader))
+			r.GET("/", func(c *gin.Context) {
+				if tt.upstreamETag != "" {
+					c.Header("ETag", tt.upstreamETag)
+				}
+				c.Data(http.StatusOK, "application/json", []byte(tt.body))
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("ETag"); got != tt.etag {
+				t.Errorf("ETag = %s, want %s", got, tt.etag)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			if tt.status == http.StatusNotModified {
+				if rec.Body.Len() != 0 {
+					t.Errorf("304 with a %d byte body", rec.Body.Len())
+				}
+				return
+			}
+			if got := decodeBody(t, tt.encoding, rec.Body); got != tt.body {
+				t.Errorf("body = %.40s..., want %.40s...", got, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gateway/config"
+	"math/big"
+	"net/http"
+	"shared/logger"
+	"shared/tracing"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/sirupsen/logrus"
+)
+
+// 令牌只接受这些非对称签名算法，HS256 和 none 一律拒绝
+var validSigningMethods = []string{"RS256", "ES256"}
+
+// 未知 kid 触发刷新的最小间隔，避免伪造 kid 的请求打满 user-service
+const jwksMinRefetchInterval = 30 * time.Second
+
+// jwk JWKS 中的一个公钥
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type verifyKey struct {
+	alg string
+	key interface{}
+}
+
+// JWKSCache 缓存 user-service 发布的令牌校验公钥，定期刷新；
+// 遇到未知 kid（密钥刚轮换）时立即刷新一次。刷新失败时继续使用已缓存的公钥。
+type JWKSCache struct {
+	url    string
+	client *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]verifyKey
+	lastFetch time.Time
+
+	fetchMu sync.Mutex // 同一时间只有一个刷新请求
+}
+
+// NewJWKSCache 拉取公钥并启动定期刷新。startup_timeout 内一直取不到可用公钥时返回错误，
+// 网关不能在没有公钥的情况下启动。
+func NewJWKSCache(ctx context.Context, cfg config.JWKSConfig) (*JWKSCache, error) {
+	c := &JWKSCache{
+		url:    cfg.URL,
+		client: &http.Client{Timeout: 5 * time.Second, Transport: tracing.Transport(http.DefaultTransport)},
+		keys:   make(map[string]verifyKey),
+	}
+	deadline := time.Now().Add(cfg.StartupTimeout)
+	for {
+		err := c.refresh(ctx)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("fetch jwks from %s: %w", c.url, err)
+		}
+		logger.Log.WithError(err).WithField("url", c.url).Warn("JWKS not available yet, retrying")
+		time.Sleep(2 * time.Second)
+	}
+	go c.refreshLoop(cfg.RefreshInterval)
+	return c, nil
+}
+
+func (c *JWKSCache) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := c.refresh(context.Background()); err != nil {
+			logger.Log.WithError(err).WithField("url", c.url).Error("Failed to refresh JWKS, keeping cached keys")
+		}
+	}
+}
+
+// refresh 拉取 JWKS 并整体替换缓存；没有任何可用公钥时视为失败
+func (c *JWKSCache) refresh(ctx context.Context) error {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+	return c.fetch(ctx)
+}
+
+// refreshStale 距上次拉取超过 jwksMinRefetchInterval 时才刷新。拿到 fetchMu 后重新检查，
+// 同时遇到未知 kid 的请求排队等第一个请求刷新完，不会各自再拉取一次
+func (c *JWKSCache) refreshStale(ctx context.Context) error {
+	if !c.stale() {
+		return nil
+	}
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+	if !c.stale() {
+		return nil
+	}
+	return c.fetch(ctx)
+}
+
+func (c *JWKSCache) stale() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Since(c.lastFetch) >= jwksMinRefetchInterval
+}
+
+// fetch 需要持有 fetchMu
+func (c *JWKSCache) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode jwks: %w", err)
+	}
+
+	keys := make(map[string]verifyKey, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.verifyKey()
+		if err != nil {
+			logger.Log.WithError(err).WithField("kid", k.Kid).Warn("Skipping unusable JWKS key")
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("jwks contains no usable keys")
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.lastFetch = time.Now()
+	c.mu.Unlock()
+	kids := make([]string, 0, len(keys))
+	for kid := range keys {
+		kids = append(kids, kid)
+	}
+	logger.Log.WithFields(logrus.Fields{"url": c.url, "kids": kids}).Debug("JWKS refreshed")
+	return nil
+}
+
+func (c *JWKSCache) lookup(kid string) (verifyKey, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	k, ok := c.keys[kid]
+	return k, ok
+}
+
+// Keyfunc 返回供 jwt.Parse 使用的函数，按令牌头中的 kid 返回校验公钥；
+// 未知 kid 触发的刷新使用 ctx，客户端断开时不再等待 user-service
+func (c *JWKSCache) Keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token has no kid")
+		}
+		key, ok := c.lookup(kid)
+		if !ok {
+			if err := c.refreshStale(ctx); err != nil {
+				logger.FromContext(ctx).WithError(err).WithField("url", c.url).Error("Failed to refresh JWKS")
+			}
+			key, ok = c.lookup(kid)
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		if token.Method.Alg() != key.alg {
+			return nil, fmt.Errorf("kid %q expects %s, token uses %s", kid, key.alg, token.Method.Alg())
+		}
+		return key.key, nil
+	}
+}
+
+// verifyKey 把 JWK 转成 jwt-go 使用的公钥
+func (k jwk) verifyKey() (verifyKey, error) {
+	if k.Kid == "" {
+		return verifyKey{}, errors.New("missing kid")
+	}
+	if k.Use != "" && k.Use != "sig" {
+		return verifyKey{}, fmt.Errorf("unsupported use %q", k.Use)
+	}
+	switch k.Kty {
+	case "RSA":
+		if k.Alg != "" && k.Alg != "RS256" {
+			return verifyKey{}, fmt.Errorf("unsupported alg %q", k.Alg)
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return verifyKey{}, fmt.Errorf("decode n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return verifyKey{}, fmt.Errorf("decode e: %w", err)
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < 2048 || pub.E < 3 {
+			return verifyKey{}, errors.New("RSA key too weak")
+		}
+		return verifyKey{alg: "RS256", key: pub}, nil
+	case "EC":
+		if k.Crv != "P-256" || (k.Alg != "" && k.Alg != "ES256") {
+			return verifyKey{}, fmt.Errorf("unsupported curve %q / alg %q", k.Crv, k.Alg)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != 32 {
+			return verifyKey{}, errors.New("invalid x coordinate")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil || len(y) != 32 {
+			return verifyKey{}, errors.New("invalid y coordinate")
+		}
+		// 确认点在曲线上
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return verifyKey{}, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return verifyKey{alg: "ES256", key: pub}, nil
+	default:
+		return verifyKey{}, fmt.Errorf("unsupported kty %q", k.Kty)
+	}
+}
@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"shared/logger"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jwk {
+	return jwk{
+		Kty: "EC", Kid: kid, Use: "sig", Alg: "ES256", Crv: "P-256",
+		X: base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y: base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) jwk {
+	return jwk{
+		Kty: "RSA", Kid: kid, Use: "sig", Alg: "RS256",
+		N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// jwksServer 发布可替换的 JWKS，记录被拉取的次数；delay 模拟较慢的 user-service
+type jwksServer struct {
+	mu      sync.Mutex
+	keys    []jwk
+	fetches int
+	delay   time.Duration
+}
+
+func (s *jwksServer) set(keys ...jwk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+	time.Sleep(s.delay)
+	json.NewEncoder(w).Encode(map[string][]jwk{"keys": s.keys})
+}
+
+func newTestJWKSCache(t *testing.T, keys ...jwk) (*JWKSCache, *jwksServer) {
+	t.Helper()
+	out := logger.Log.Out
+	logger.Log.SetOutput(io.Discard)
+	t.Cleanup(func() { logger.Log.SetOutput(out) })
+
+	src := &jwksServer{}
+	src.set(keys...)
+	srv := httptest.NewServer(src)
+	t.Cleanup(srv.Close)
+	c := &JWKSCache{url: srv.URL, client: srv.Client(), keys: make(map[string]verifyKey)}
+	if err := c.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return c, src
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, jwt.MapClaims{"user_id": 1, "exp": time.Now().Add(time.Minute).Unix()})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWKSKeyfuncRotation(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c, src := newTestJWKSCache(t, ecJWK("old", oldKey))
+	parser := &jwt.Parser{ValidMethods: validSigningMethods}
+
+	// 每一步先按 publish 更新 JWKS，把上次拉取时间往前调 fetchedAgo，再校验用 kid 对应私钥签名的令牌
+	steps := []struct {
+		name        string
+		publish     []jwk
+		fetchedAgo  time.Duration
+		kid         string
+		key         *ecdsa.PrivateKey
+		ok          bool
+		wantFetches int
+	}{
+		{"cached key", nil, 0, "old", oldKey, true, 1},
+		{"rotated key within refetch interval", []jwk{ecJWK("old", oldKey), ecJWK("new", newKey)}, time.Second, "new", newKey, false, 1},
+		{"rotated key after refetch interval", nil, jwksMinRefetchInterval, "new", newKey, true, 2},
+		{"old key still published", nil, 0, "old", oldKey, true, 2},
+		{"old key removed, not refetched yet", []jwk{ecJWK("new", newKey)}, time.Second, "old", oldKey, true, 2},
+		{"unknown kid triggers refetch", nil, jwksMinRefetchInterval, "unknown", oldKey, false, 3},
+		{"old key gone from cache", nil, time.Second, "old", oldKey, false, 3},
+	}
+	for _, s := range steps {
+		if s.publish != nil {
+			src.set(s.publish...)
+		}
+		c.mu.Lock()
+		c.lastFetch = time.Now().Add(-s.fetchedAgo)
+		c.mu.Unlock()
+		_, err := parser.Parse(signToken(t, jwt.SigningMethodES256, s.kid, s.key), c.Keyfunc(context.Background()))
+		if (err == nil) != s.ok {
+			t.Errorf("%s: Parse() error = %v, want ok = %v", s.name, err, s.ok)
+		}
+		if got := src.fetchCount(); got != s.wantFetches {
+			t.Errorf("%s: JWKS fetched %d times, want %d", s.name, got, s.wantFetches)
+		}
+	}
+}
+
+func TestJWKSKeyfuncConcurrentRefetch(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		ok          bool
+		wantFetches int
+	}{
+		// 同时到达的未知 kid 请求只拉取一次 JWKS
+		{"concurrent unknown kid", context.Background(), true, 2},
+		// 客户端已断开时不再请求 user-service
+		{"cancelled request", cancelled, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, src := newTestJWKSCache(t, ecJWK("old", oldKey))
+			src.set(ecJWK("old", oldKey), ecJWK("new", newKey))
+			src.mu.Lock()
+			src.delay = 50 * time.Millisecond
+			src.mu.Unlock()
+			c.lastFetch = time.Now().Add(-jwksMinRefetchInterval)
+			parser := &jwt.Parser{ValidMethods: validSigningMethods}
+			token := signToken(t, jwt.SigningMethodES256, "new", newKey)
+
+			const n = 20
+			errs := make(chan error, n)
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := parser.Parse(token, c.Keyfunc(tt.ctx))
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if (err == nil) != tt.ok {
+					t.Errorf("Parse() error = %v, want ok = %v", err, tt.ok)
+				}
+			}
+			if got := src.fetchCount(); got != tt.wantFetches {
+				t.Errorf("JWKS fetched %d times, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
+
+func TestJWKSKeyfuncAlgPinning(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := newTestJWKSCache(t, ecJWK("ec", ecKey), rsaJWK("rsa", rsaKey))
+	// 避免未知 kid 触发刷新
+	c.lastFetch = time.Now()
+	parser := &jwt.Parser{ValidMethods: validSigningMethods}
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		kid    string
+		key    interface{}
+		ok     bool
+	}{
+		{"ES256 with EC key", jwt.SigningMethodES256, "ec", ecKey, true},
+		{"RS256 with RSA key", jwt.SigningMethodRS256, "rsa", rsaKey, true},
+		{"RS256 claiming the EC kid", jwt.SigningMethodRS256, "ec", rsaKey, false},
+		{"ES256 claiming the RSA kid", jwt.SigningMethodES256, "rsa", ecKey, false},
+		{"HS256 keyed with a public key", jwt.SigningMethodHS256, "rsa", rsaKey.N.Bytes(), false},
+		{"none", jwt.SigningMethodNone, "ec", jwt.UnsafeAllowNoneSignatureType, false},
+		{"missing kid", jwt.SigningMethodES256, "", ecKey, false},
+		{"unknown kid", jwt.SigningMethodES256, "other", ecKey, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.Parse(signToken(t, tt.method, tt.kid, tt.key), c.Keyfunc(context.Background()))
+			if (err == nil) != tt.ok {
+				t.Errorf("Parse() error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestJWKVerifyKey(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := ecJWK("ec", ecKey)
+	with := func(k jwk, change func(*jwk)) jwk {
+		change(&k)
+		return k
+	}
+	tests := []struct {
+		name string
+		key  jwk
+		alg  string // 为空表示应被拒绝
+	}{
+		{"EC P-256", valid, "ES256"},
+		{"alg omitted", with(valid, func(k *jwk) { k.Alg = "" }), "ES256"},
+		{"missing kid", with(valid, func(k *jwk) { k.Kid = "" }), ""},
+		{"encryption key", with(valid, func(k *jwk) { k.Use = "enc" }), ""},
+		{"alg mismatch", with(valid, func(k *jwk) { k.Alg = "ES384" }), ""},
+		{"point not on curve", with(valid, func(k *jwk) { k.Y = k.X }), ""},
+		{"P-384", jwk{Kty: "EC", Kid: "p384", Crv: "P-384",
+			X: base64.RawURLEncoding.EncodeToString(p384Key.X.Bytes()),
+			Y: base64.RawURLEncoding.EncodeToString(p384Key.Y.Bytes())}, ""},
+		{"RSA 1024", rsaJWK("weak", weakKey), ""},
+		{"symmetric key", jwk{Kty: "oct", Kid: "hs"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.key.verifyKey()
+			if tt.alg == "" {
+				if err == nil {
+					t.Errorf("verifyKey() accepted the key as %s", got.alg)
+				}
+				return
+			}
+			if err != nil || got.alg != tt.alg {
+				t.Errorf("verifyKey() = %q, %v, want %s", got.alg, err, tt.alg)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"shared/logger"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AccessLogMiddleware 每个请求结束后输出一条 JSON 访问日志，需放在 RequestIDMiddleware 之后
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		path := c.Request.URL.Path
+		status := c.Writer.Status()
+		entry := logger.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       path,
+			"status":     status,
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+			"user_agent": c.Request.UserAgent(),
+		})
+		if route := c.GetString("route"); route != "" {
+			entry = entry.WithFields(logrus.Fields{"route": route, "upstream": c.GetString("upstream")})
+		}
+		if userID := c.GetString("userID"); userID != "" {
+			entry = entry.WithField("user_id", userID)
+		}
+		if len(c.Errors) > 0 {
+			entry = entry.WithField("error", c.Errors.String())
+		}
+
+		switch {
+		case status >= 500:
+			entry.Error("request completed")
+		case status >= 400:
+			entry.Warn("request completed")
+		case path == "/health" || strings.HasPrefix(path, "/health/") || path == "/metrics":
+			// 探针请求很频繁，只在 debug 级别输出
+			entry.Debug("request completed")
+		default:
+			entry.Info("request completed")
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"gateway/config"
+	"gateway/metrics"
+	"math"
+	"net/http"
+	"shared/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitResult 一次取令牌的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int           // 桶容量
+	Remaining  int           // 剩余令牌数
+	RetryAfter time.Duration // 被拒绝时距离下一个令牌的时间
+	Reset      time.Duration // 令牌桶补满需要的时间
+}
+
+// RateLimitStore 限流状态存储，目前只有内存实现，多实例部署时可以换成共享存储
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, rule config.RateLimitRule) (RateLimitResult, error)
+}
+
+// RouteClassifier 根据请求返回所属的限流类别
+type RouteClassifier func(method, path string) string
+
+// RateLimitMiddleware 限流中间件，需放在 AuthMiddleware 之后。
+// 已登录请求按用户 ID 限流，/api/auth 下的请求按客户端 IP 限流；限流规则取自当前配置，支持热加载。
+func RateLimitMiddleware(reloader *config.Reloader, store RateLimitStore, classify RouteClassifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := reloader.Current().RateLimit
+		path := c.Request.URL.Path
+		if !cfg.Enabled || path == "/health" || strings.HasPrefix(path, "/health/") || path == "/metrics" {
+			c.Next()
+			return
+		}
+		class, rule, ok := cfg.Rule(classify(c.Request.Method, path))
+		if !ok {
+			c.Next()
+			return
+		}
+
+		var key string
+		if userID := c.GetString("userID"); userID != "" && !strings.HasPrefix(path, "/api/auth") {
+			key = class + ":user:" + userID
+		} else {
+			key = class + ":ip:" + c.ClientIP()
+		}
+
+		result, err := store.Take(c.Request.Context(), key, rule)
+		if err != nil {
+			// 存储不可用时放行，避免限流组件故障导致整个网关不可用
+			logger.FromContext(c.Request.Context()).WithError(err).Error("Rate limit store error")
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			metrics.RateLimited.WithLabelValues(class).Inc()
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.JSON(http.StatusTooManyRequests, gin.H{"code": 429, "message": "请求过于频繁，请稍后再试"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // 不再取令牌时桶补满的时间，之后与新建的桶没有区别
+}
+
+// MemoryRateLimitStore 进程内的令牌桶存储
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// 清理已补满的令牌桶的间隔
+const bucketSweepInterval = 10 * time.Minute
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, rule config.RateLimitRule) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	capacity := float64(rule.Burst)
+	rate := float64(rule.Requests) / rule.Per.Seconds() // 每秒补充的令牌数
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	} else {
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+		b.last = now
+	}
+
+	result := RateLimitResult{Limit: rule.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = time.Duration((capacity - b.tokens) / rate * float64(time.Second))
+	b.full = now.Add(result.Reset)
+
+	if now.Sub(s.lastSweep) > bucketSweepInterval {
+		s.sweep(now)
+	}
+	return result, nil
+}
+
+// sweep 删除已经补满的令牌桶，补满之前删除会让客户端多拿到令牌
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
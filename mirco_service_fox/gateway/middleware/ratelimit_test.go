@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"gateway/config"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStoreRefill(t *testing.T) {
+	// 每秒补充 2 个令牌，桶容量 3
+	rule := config.RateLimitRule{Requests: 2, Per: time.Second, Burst: 3}
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name       string
+		elapsed    time.Duration // 距离第一个请求的时间
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}{
+		{"first request", 0, true, 2, 0, 500 * time.Millisecond},
+		{"second request", 0, true, 1, 0, time.Second},
+		{"burst used up", 0, true, 0, 0, 1500 * time.Millisecond},
+		{"empty bucket", 0, false, 0, 500 * time.Millisecond, 1500 * time.Millisecond},
+		{"half a token refilled", 250 * time.Millisecond, false, 0, 250 * time.Millisecond, 1250 * time.Millisecond},
+		{"one token refilled", 500 * time.Millisecond, true, 0, 0, 1500 * time.Millisecond},
+		{"refill capped at burst", 10 * time.Second, true, 2, 0, 500 * time.Millisecond},
+	}
+	store := NewMemoryRateLimitStore()
+	for _, tt := range tests {
+		store.now = func() time.Time { return start.Add(tt.elapsed) }
+		got, err := store.Take(context.Background(), "user:1", rule)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		want := RateLimitResult{Allowed: tt.allowed, Limit: 3, Remaining: tt.remaining, RetryAfter: tt.retryAfter, Reset: tt.reset}
+		if got != want {
+			t.Errorf("%s: Take() = %+v, want %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreKeys(t *testing.T) {
+	rule := config.RateLimitRule{Requests: 1, Per: time.Minute, Burst: 1}
+	store := NewMemoryRateLimitStore()
+	tests := []struct {
+		key     string
+		allowed bool
+	}{
+		{"default:user:1", true},
+		{"default:user:1", false},
+		{"default:user:2", true},
+		{"default:ip:10.0.0.1", true},
+		{"default:ip:10.0.0.1", false},
+	}
+	for i, tt := range tests {
+		got, err := store.Take(context.Background(), tt.key, rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Allowed != tt.allowed {
+			t.Errorf("request %d (%s): allowed = %v, want %v", i, tt.key, got.Allowed, tt.allowed)
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      config.RateLimitRule
+		idle      time.Duration
+		wantSwept bool
+	}{
+		{"refilled", config.RateLimitRule{Requests: 1, Per: time.Minute, Burst: 1}, bucketSweepInterval + time.Second, true},
+		// 每天 10 次的规则补满需要一天，只空闲了 sweep 间隔时不能删除
+		{"still refilling", config.RateLimitRule{Requests: 10, Per: 24 * time.Hour, Burst: 10}, bucketSweepInterval + time.Second, false},
+		{"long rule refilled", config.RateLimitRule{Requests: 10, Per: 24 * time.Hour, Burst: 10}, 3 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			store := NewMemoryRateLimitStore()
+			store.now = func() time.Time { return now }
+			store.lastSweep = now
+			store.Take(context.Background(), "idle", tt.rule)
+
+			now = now.Add(tt.idle)
+			store.Take(context.Background(), "active", tt.rule)
+			if _, ok := store.buckets["idle"]; ok == tt.wantSwept {
+				t.Errorf("idle bucket present = %v, want swept = %v", ok, tt.wantSwept)
+			}
+			if _, ok := store.buckets["active"]; !ok {
+				t.Error("active bucket was swept")
+			}
+		})
+	}
+}
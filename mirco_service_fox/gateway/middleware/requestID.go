@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"shared/logger"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -9,19 +11,37 @@ import (
 func RequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 尝试从请求头获取请求ID
-		requestID := c.GetHeader("X-Request-ID")
+		requestID := c.GetHeader(logger.RequestIDHeader)
 
-		// 如果没有，生成一个新的
-		if requestID == "" {
+		// 如果没有或者不合法，生成一个新的
+		if !validRequestID(requestID) {
 			requestID = uuid.New().String()
 		}
 
-		// 设置到响应头
-		c.Writer.Header().Set("X-Request-ID", requestID)
+		// 设置到响应头，并转发给上游服务
+		c.Writer.Header().Set(logger.RequestIDHeader, requestID)
+		c.Request.Header.Set(logger.RequestIDHeader, requestID)
 
 		// 设置到请求上下文，方便后续使用
 		c.Set("RequestID", requestID)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
 
+		// 错误响应体中带上请求ID
+		finish := logger.WrapErrorBody(c, requestID)
 		c.Next()
+		finish()
+	}
+}
+
+// validRequestID 客户端传入的请求ID会原样写进日志和响应，只接受长度有限的可见字符
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
 	}
+	return true
 }
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"gateway/config"
+	"io"
+	"sync"
+	"time"
+)
+
+// RevocationStore 已注销令牌的存储。
+// 单个令牌按 jti 注销；注销用户全部令牌时记录一个时间点，此前签发的令牌都失效。
+// 记录在令牌过期后就没有意义，实现可以在 expiresAt 之后删除。
+type RevocationStore interface {
+	// RevokeToken 注销单个令牌
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	// IsTokenRevoked 判断令牌是否已注销
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeUser 注销用户在 before 之前（含）签发的全部令牌，before 取自 user-service 的时钟
+	RevokeUser(ctx context.Context, userID uint, before, expiresAt time.Time) error
+	// UserRevokedBefore 返回用户令牌的注销时间点，没有记录时返回零值
+	UserRevokedBefore(ctx context.Context, userID uint) (time.Time, error)
+}
+
+type revokedUser struct {
+	before    time.Time
+	expiresAt time.Time
+}
+
+// MemoryRevocationStore 进程内的注销记录，只在单个网关实例内生效
+type MemoryRevocationStore struct {
+	mu        sync.Mutex
+	tokens    map[string]time.Time // jti -> 令牌过期时间
+	users     map[uint]revokedUser
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// 清理过期注销记录的间隔
+const revocationSweepInterval = 10 * time.Minute
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens:    make(map[string]time.Time),
+		users:     make(map[uint]revokedUser),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryRevocationStore) RevokeToken(_ context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[jti] = expiresAt
+	s.maybeSweep()
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiresAt, ok := s.tokens[jti]
+	return ok && s.now().Before(expiresAt), nil
+}
+
+func (s *MemoryRevocationStore) RevokeUser(_ context.Context, userID uint, before, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userID] = revokedUser{before: before, expiresAt: expiresAt}
+	s.maybeSweep()
+	return nil
+}
+
+func (s *MemoryRevocationStore) UserRevokedBefore(_ context.Context, userID uint) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok || !s.now().Before(u.expiresAt) {
+		return time.Time{}, nil
+	}
+	return u.before, nil
+}
+
+// maybeSweep 定期删除已过期的注销记录，调用方需持有锁
+func (s *MemoryRevocationStore) maybeSweep() {
+	now := s.now()
+	if now.Sub(s.lastSweep) < revocationSweepInterval {
+		return
+	}
+	for jti, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, jti)
+		}
+	}
+	for id, u := range s.users {
+		if !now.Before(u.expiresAt) {
+			delete(s.users, id)
+		}
+	}
+	s.lastSweep = now
+}
+
+// NewRevocationStore 按配置创建注销记录存储
+func NewRevocationStore(cfg config.RevocationConfig) (RevocationStore, error) {
+	switch cfg.Store {
+	case config.RevocationStoreMySQL:
+		return NewMySQLRevocationStore(cfg.DSN)
+	default:
+		return NewMemoryRevocationStore(), nil
+	}
+}
+
+// CloseRevocationStore 释放存储占用的连接，内存存储无需关闭
+func CloseRevocationStore(store RevocationStore) error {
+	if c, ok := store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// IsRevoked 判断令牌是否已被单独注销，或者签发时间不晚于用户的注销时间点。
+// 签发时间按毫秒比较，注销后同一秒内重新登录得到的令牌不受影响；没有 iat_ms 的旧令牌按秒比较。
+// 没有 jti 的旧令牌只能通过注销用户全部令牌失效。
+func IsRevoked(ctx context.Context, store RevocationStore, claims *Claims) (bool, error) {
+	if claims.Id != "" {
+		revoked, err := store.IsTokenRevoked(ctx, claims.Id)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+	before, err := store.UserRevokedBefore(ctx, claims.UserID)
+	if err != nil {
+		return false, err
+	}
+	if before.IsZero() {
+		return false, nil
+	}
+	if claims.IssuedAtMs > 0 {
+		return claims.IssuedAtMs <= before.UnixMilli(), nil
+	}
+	return claims.IssuedAt <= before.Unix(), nil
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shared/logger"
+	"sync"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// RevokedToken 已注销的令牌
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// RevokedUser 注销用户全部令牌的记录
+type RevokedUser struct {
+	UserID        uint `gorm:"primaryKey;autoIncrement:false"`
+	RevokedBefore time.Time
+	ExpiresAt     time.Time `gorm:"index"`
+}
+
+// MySQLRevocationStore 保存在 MySQL 中的注销记录，多个网关实例共享
+type MySQLRevocationStore struct {
+	db        *gorm.DB
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewMySQLRevocationStore(dsn string) (*MySQLRevocationStore, error) {
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Error),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect revocation db: %w", err)
+	}
+	if err := db.AutoMigrate(&RevokedToken{}, &RevokedUser{}); err != nil {
+		return nil, fmt.Errorf("migrate revocation db: %w", err)
+	}
+	return &MySQLRevocationStore{db: db, lastSweep: time.Now()}, nil
+}
+
+// Close 关闭数据库连接池
+func (s *MySQLRevocationStore) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+func (s *MySQLRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+	s.maybeSweep(ctx)
+	return err
+}
+
+func (s *MySQLRevocationStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&RevokedToken{}).
+		Where("jti = ? AND expires_at > ?", jti, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (s *MySQLRevocationStore) RevokeUser(ctx context.Context, userID uint, before, expiresAt time.Time) error {
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&RevokedUser{UserID: userID, RevokedBefore: before, ExpiresAt: expiresAt}).Error
+	s.maybeSweep(ctx)
+	return err
+}
+
+func (s *MySQLRevocationStore) UserRevokedBefore(ctx context.Context, userID uint) (time.Time, error) {
+	var u RevokedUser
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		First(&u).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return u.RevokedBefore, nil
+}
+
+// maybeSweep 定期删除已过期的注销记录
+func (s *MySQLRevocationStore) maybeSweep(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastSweep) < revocationSweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&RevokedToken{}).Error; err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("Failed to sweep revoked tokens")
+	}
+	if err := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&RevokedUser{}).Error; err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("Failed to sweep revoked users")
+	}
+}
@@ -0,0 +1,8 @@
+package openapi
+
+import _ "embed"
+
+// DocsPage /docs 页面，在浏览器中加载同目录的 openapi.json 后渲染，不依赖外部资源
+//
+//go:embed docs.html
+var DocsPage []byte
@@ -0,0 +1,195 @@
+// Package openapi 网关对外的 OpenAPI 文档。各服务在 /openapi.json 发布自己的文档，
+// 网关按路由表筛选出对外暴露的接口后合并，并补充登录方式、需要的角色和 API Key 权限范围。
+package openapi
+
+import "strings"
+
+// Document OpenAPI 文档，字段与各服务生成的一致
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+	// 取不到文档的服务，这些服务的接口不在文档中
+	XUnavailable []string `json:"x-unavailable-services,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem 小写的请求方法 -> 接口
+type PathItem map[string]*Operation
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// SecurityRequirement 登录方式名称 -> 需要的权限范围，数组中任意一项满足即可
+type SecurityRequirement map[string][]string
+
+type Operation struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationID string               `json:"operationId,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	// 空数组表示不需要登录，合并时总是设置
+	Security []SecurityRequirement `json:"security"`
+
+	XGatewayRoute string `json:"x-gateway-route,omitempty"`
+	XRequiredRole string `json:"x-required-role,omitempty"`
+	XAPIKeyScope  string `json:"x-api-key-scope,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path | query
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Schema JSON Schema 的子集。
+// XOmitEmpty 对应服务中 binding 的 omitempty：值为零值（空字符串、0）时不检查其它约束
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	XOmitEmpty           bool               `json:"x-omitempty,omitempty"`
+}
+
+// 网关支持的登录方式
+const (
+	SecurityBearer = "bearerAuth"
+	SecurityAPIKey = "apiKey"
+)
+
+const schemaRefPrefix = "#/components/schemas/"
+
+// New 创建网关的空文档，包含登录方式的说明
+func New(title, version string) *Document {
+	return &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: title, Version: version},
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]*SecurityScheme{
+				SecurityBearer: {
+					Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+					Description: "登录接口返回的访问令牌",
+				},
+				SecurityAPIKey: {
+					Type: "apiKey", In: "header", Name: "X-API-Key",
+					Description: "教师创建的 API Key，只能访问 x-api-key-scope 在 Key 权限范围内的接口",
+				},
+			},
+		},
+	}
+}
+
+// Merge 把服务 service 的文档合并进来。keep 返回 false 的接口不合并，可以在 keep 中补充网关相关的字段。
+// schema 名称和 operationId 加上 "service." 前缀，避免不同服务的重名；只合并保留的接口引用到的 schema
+func (d *Document) Merge(service string, src *Document, keep func(path, method string, op *Operation) bool) {
+	refs := make(map[string]bool)
+	for path, item := range src.Paths {
+		for method, op := range item {
+			if _, exists := d.Paths[path][method]; exists || !keep(path, method, op) {
+				continue
+			}
+			if op.OperationID != "" {
+				op.OperationID = service + "." + op.OperationID
+			}
+			for i, tag := range op.Tags {
+				op.Tags[i] = service + "/" + tag
+			}
+			for _, p := range op.Parameters {
+				p.Schema.rename(service, src.Components.Schemas, refs)
+			}
+			if op.RequestBody != nil {
+				for _, mt := range op.RequestBody.Content {
+					mt.Schema.rename(service, src.Components.Schemas, refs)
+				}
+			}
+			for _, resp := range op.Responses {
+				for _, mt := range resp.Content {
+					mt.Schema.rename(service, src.Components.Schemas, refs)
+				}
+			}
+			if d.Paths[path] == nil {
+				d.Paths[path] = make(PathItem)
+			}
+			d.Paths[path][method] = op
+		}
+	}
+	for name := range refs {
+		if schema, ok := src.Components.Schemas[name]; ok {
+			d.Components.Schemas[service+"."+name] = schema
+		}
+	}
+}
+
+// rename 把 schema 中的 $ref 改为加了服务名前缀的名称，并记录引用到的 schema（包括间接引用的）
+func (s *Schema) rename(service string, schemas map[string]*Schema, refs map[string]bool) {
+	if s == nil {
+		return
+	}
+	if name, ok := strings.CutPrefix(s.Ref, schemaRefPrefix); ok {
+		s.Ref = schemaRefPrefix + service + "." + name
+		if !refs[name] {
+			refs[name] = true
+			schemas[name].rename(service, schemas, refs)
+		}
+		return
+	}
+	for _, p := range s.Properties {
+		p.rename(service, schemas, refs)
+	}
+	s.Items.rename(service, schemas, refs)
+	s.AdditionalProperties.rename(service, schemas, refs)
+}
@@ -0,0 +1,394 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation 请求中不符合文档的一处
+type Violation struct {
+	In      string `json:"in"`              // path | query | body
+	Field   string `json:"field,omitempty"` // 参数名或请求体中的字段，如 questions[0].score
+	Message string `json:"message"`
+}
+
+// Validator 按合并后的文档校验请求。必填和 x-omitempty 的含义与各服务用 gin 绑定时一致：
+// 缺省和 null 的字段只检查是否必填；必填的字段不能是零值（可为 null 的字段只要求不是 null）；
+// x-omitempty 的字段为零值时不检查其它约束。
+// 数组元素总是按文档校验，服务中没有 dive 的字段 gin 不校验元素，这时网关比服务严格
+type Validator struct {
+	doc   *Document
+	paths []pathTemplate
+}
+
+type pathTemplate struct {
+	segments []string
+	item     PathItem
+}
+
+// NewValidator 创建校验器，doc 之后不能再修改
+func NewValidator(doc *Document) *Validator {
+	v := &Validator{doc: doc}
+	for path, item := range doc.Paths {
+		v.paths = append(v.paths, pathTemplate{segments: splitPath(path), item: item})
+	}
+	// 同一位置固定的路径段优先于路径参数，与 gin 的匹配顺序一致
+	sort.Slice(v.paths, func(i, j int) bool {
+		a, b := v.paths[i].segments, v.paths[j].segments
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if pa, pb := isParam(a[k]), isParam(b[k]); pa != pb {
+				return pb
+			}
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return v
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// Find 查找请求对应的接口，返回接口和路径参数，文档中没有时 ok 为 false
+func (v *Validator) Find(method, path string) (op *Operation, params map[string]string, ok bool) {
+	segments := splitPath(path)
+	method = strings.ToLower(method)
+	for _, t := range v.paths {
+		if len(t.segments) != len(segments) {
+			continue
+		}
+		op, ok := t.item[method]
+		if !ok {
+			continue
+		}
+		params = make(map[string]string)
+		for i, s := range t.segments {
+			if isParam(s) {
+				params[s[1:len(s)-1]] = segments[i]
+			} else if s != segments[i] {
+				params = nil
+				break
+			}
+		}
+		if params != nil {
+			return op, params, true
+		}
+	}
+	return nil, nil, false
+}
+
+// AcceptsJSON 接口是否有 JSON 请求体
+func (op *Operation) AcceptsJSON() bool {
+	if op.RequestBody == nil {
+		return false
+	}
+	_, ok := op.RequestBody.Content["application/json"]
+	return ok
+}
+
+// Resolve 返回 $ref 引用的 schema，引用的 schema 不存在时返回 nil
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, schemaRefPrefix)]
+	}
+	return s
+}
+
+// ValidateParams 校验路径参数和查询参数。查询参数与 gin 绑定时一样只取第一个值，数字和布尔参数为空字符串时视为零值
+func (v *Validator) ValidateParams(op *Operation, params map[string]string, query url.Values) []Violation {
+	var out []Violation
+	for _, p := range op.Parameters {
+		var values []string
+		switch p.In {
+		case "path":
+			values = []string{params[p.Name]}
+		case "query":
+			values = query[p.Name]
+		default:
+			continue
+		}
+		s := v.doc.Resolve(p.Schema)
+		if s == nil {
+			continue
+		}
+		if len(values) == 0 {
+			if p.Required {
+				out = append(out, Violation{In: p.In, Field: p.Name, Message: "缺少参数"})
+			}
+			continue
+		}
+		var val any
+		if s.Type == "array" {
+			items := make([]any, len(values))
+			for i, raw := range values {
+				items[i] = paramValue(v.doc.Resolve(s.Items), raw)
+			}
+			val = items
+		} else {
+			val = paramValue(s, values[0])
+		}
+		if p.Required && isEmpty(s, val) {
+			out = append(out, Violation{In: p.In, Field: p.Name, Message: "不能为空"})
+			continue
+		}
+		v.check(s, val, p.In, p.Name, &out)
+	}
+	return out
+}
+
+// paramValue 把参数的字符串值转换为与 JSON 解码结果相同的类型，转换不了的保留字符串，由 check 报告类型错误
+func paramValue(s *Schema, raw string) any {
+	if s == nil {
+		return raw
+	}
+	switch s.Type {
+	case "integer", "number":
+		if raw == "" {
+			raw = "0"
+		}
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return json.Number(raw)
+		}
+	case "boolean":
+		if raw == "" {
+			return false
+		}
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+// ValidateBody 校验 JSON 请求体
+func (v *Validator) ValidateBody(op *Operation, body []byte) []Violation {
+	if !op.AcceptsJSON() {
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			return []Violation{{In: "body", Message: "请求体不能为空"}}
+		}
+		return nil
+	}
+	var val any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&val); err != nil {
+		return []Violation{{In: "body", Message: "请求体不是合法的 JSON"}}
+	}
+	var out []Violation
+	v.check(op.RequestBody.Content["application/json"].Schema, val, "body", "", &out)
+	return out
+}
+
+// check 校验一个值，val 为 JSON 解码的结果（数字为 json.Number）
+func (v *Validator) check(s *Schema, val any, in, field string, out *[]Violation) {
+	s = v.doc.Resolve(s)
+	if s == nil || val == nil {
+		return
+	}
+	if s.XOmitEmpty && isZero(val) {
+		return
+	}
+	fail := func(format string, args ...any) {
+		*out = append(*out, Violation{In: in, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	switch s.Type {
+	case "string":
+		str, ok := val.(string)
+		if !ok {
+			fail("应为字符串")
+			return
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("长度不能少于 %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("长度不能超过 %d", *s.MaxLength)
+		}
+		if msg := checkFormat(s.Format, str); msg != "" {
+			fail("%s", msg)
+		}
+	case "integer", "number":
+		num, ok := val.(json.Number)
+		if !ok || (s.Type == "integer" && strings.ContainsAny(num.String(), ".eE")) {
+			if s.Type == "integer" {
+				fail("应为整数")
+			} else {
+				fail("应为数字")
+			}
+			return
+		}
+		f, err := num.Float64()
+		if err != nil {
+			fail("应为数字")
+			return
+		}
+		if s.Minimum != nil && (f < *s.Minimum || s.ExclusiveMinimum && f == *s.Minimum) {
+			if s.ExclusiveMinimum {
+				fail("应大于 %v", *s.Minimum)
+			} else {
+				fail("不能小于 %v", *s.Minimum)
+			}
+		}
+		if s.Maximum != nil && (f > *s.Maximum || s.ExclusiveMaximum && f == *s.Maximum) {
+			if s.ExclusiveMaximum {
+				fail("应小于 %v", *s.Maximum)
+			} else {
+				fail("不能大于 %v", *s.Maximum)
+			}
+		}
+	case "boolean":
+		if _, ok := val.(bool); !ok {
+			fail("应为布尔值")
+			return
+		}
+	case "array":
+		items, ok := val.([]any)
+		if !ok {
+			fail("应为数组")
+			return
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			fail("至少需要 %d 项", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			fail("最多 %d 项", *s.MaxItems)
+		}
+		for i, item := range items {
+			v.check(s.Items, item, in, fmt.Sprintf("%s[%d]", field, i), out)
+		}
+	case "object":
+		obj, ok := val.(map[string]any)
+		if !ok {
+			fail("应为对象")
+			return
+		}
+		empty := make(map[string]bool)
+		for _, name := range s.Required {
+			if item, ok := obj[name]; !ok || isEmpty(v.doc.Resolve(s.Properties[name]), item) {
+				empty[name] = true
+				*out = append(*out, Violation{In: in, Field: joinField(field, name), Message: "不能为空"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if empty[name] {
+				continue
+			}
+			if prop, ok := s.Properties[name]; ok {
+				v.check(prop, obj[name], in, joinField(field, name), out)
+			} else if s.AdditionalProperties != nil {
+				v.check(s.AdditionalProperties, obj[name], in, joinField(field, name), out)
+			}
+		}
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, val) {
+		values := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			values[i] = fmt.Sprint(e)
+		}
+		fail("取值应为 %s 之一", strings.Join(values, "、"))
+	}
+}
+
+func joinField(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+// checkFormat 校验字符串格式，不认识的格式不校验
+func checkFormat(format, s string) string {
+	switch format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return "应为 RFC3339 格式的时间，如 2006-01-02T15:04:05+08:00"
+		}
+	case "email":
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return "应为邮箱地址"
+		}
+	case "uri":
+		if u, err := url.Parse(s); err != nil || u.Scheme == "" {
+			return "应为完整的 URL"
+		}
+	case "byte":
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			return "应为 base64 编码"
+		}
+	}
+	return ""
+}
+
+// isZero 值是否为 Go 中的零值
+func isZero(val any) bool {
+	switch val := val.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case bool:
+		return !val
+	case json.Number:
+		f, err := val.Float64()
+		return err == nil && f == 0
+	}
+	return false
+}
+
+// isEmpty 必填字段是否没有值：可为 null 的字段（服务中的指针）只要求不是 null，其它字段不能是零值
+func isEmpty(s *Schema, val any) bool {
+	if val == nil {
+		return true
+	}
+	if s != nil && s.Nullable {
+		return false
+	}
+	return isZero(val)
+}
+
+// inEnum 数字按数值比较，文档中的枚举值解码后是 float64
+func inEnum(enum []any, val any) bool {
+	if num, ok := val.(json.Number); ok {
+		f, err := num.Float64()
+		if err != nil {
+			return false
+		}
+		for _, e := range enum {
+			if ef, ok := e.(float64); ok && ef == f {
+				return true
+			}
+		}
+		return false
+	}
+	for _, e := range enum {
+		if e == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+const testDocument = `{
+  "paths": {
+    "/api/teacher/experiments/{id}": {
+      "get": {"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 1}}]},
+      "put": {
+        "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 1}}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Experiment"}}}}
+      }
+    },
+    "/api/teacher/experiments/notifications": {"get": {}},
+    "/api/student/experiments": {
+      "get": {"parameters": [
+        {"name": "page", "in": "query", "schema": {"type": "integer", "minimum": 1, "x-omitempty": true}},
+        {"name": "status", "in": "query", "schema": {"type": "string", "enum": ["active", "expired"]}},
+        {"name": "keyword", "in": "query", "required": true, "schema": {"type": "string", "maxLength": 4}},
+        {"name": "ids", "in": "query", "schema": {"type": "array", "items": {"type": "integer"}}}
+      ]}
+    }
+  },
+  "components": {"schemas": {
+    "Experiment": {
+      "type": "object",
+      "required": ["title", "deadline", "questions", "visible"],
+      "properties": {
+        "title": {"type": "string", "maxLength": 5},
+        "deadline": {"type": "string", "format": "date-time"},
+        "visible": {"type": "boolean", "nullable": true},
+        "contact": {"type": "string", "format": "email", "x-omitempty": true},
+        "questions": {"type": "array", "minItems": 1, "items": {"$ref": "#/components/schemas/Question"}}
+      }
+    },
+    "Question": {
+      "type": "object",
+      "required": ["score"],
+      "properties": {
+        "score": {"type": "integer", "minimum": 0, "exclusiveMinimum": true, "maximum": 100},
+        "type": {"type": "string", "enum": ["choice", "blank"]}
+      }
+    }
+  }}
+}`
+
+func newTestValidator(t *testing.T) *Validator {
+	t.Helper()
+	var doc Document
+	if err := json.Unmarshal([]byte(testDocument), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return NewValidator(&doc)
+}
+
+func TestValidatorFind(t *testing.T) {
+	v := newTestValidator(t)
+	tests := []struct {
+		method, path string
+		ok           bool
+		params       map[string]string
+	}{
+		{"PUT", "/api/teacher/experiments/12", true, map[string]string{"id": "12"}},
+		{"GET", "/api/teacher/experiments/12/", true, map[string]string{"id": "12"}},
+		// 固定的路径段优先于路径参数
+		{"GET", "/api/teacher/experiments/notifications", true, map[string]string{}},
+		{"PUT", "/api/teacher/experiments/notifications", true, map[string]string{"id": "notifications"}},
+		{"DELETE", "/api/teacher/experiments/12", false, nil},
+		{"GET", "/api/teacher/experiments/12/questions", false, nil},
+	}
+	for _, tt := range tests {
+		_, params, ok := v.Find(tt.method, tt.path)
+		if ok != tt.ok || !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("Find(%s %s) = %v, %v, want %v, %v", tt.method, tt.path, params, ok, tt.params, tt.ok)
+		}
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	v := newTestValidator(t)
+	tests := []struct {
+		name  string
+		path  string
+		query string
+		want  []Violation
+	}{
+		{name: "valid", path: "/api/student/experiments", query: "page=2&status=active&keyword=lab&ids=1&ids=2"},
+		{name: "omitempty zero page", path: "/api/student/experiments", query: "page=&keyword=lab"},
+		{name: "missing required", path: "/api/student/experiments", query: "page=1", want: []Violation{
+			{In: "query", Field: "keyword", Message: "缺少参数"},
+		}},
+		{name: "empty required", path: "/api/student/experiments", query: "keyword=", want: []Violation{
+			{In: "query", Field: "keyword", Message: "不能为空"},
+		}},
+		{name: "bad values", path: "/api/student/experiments", query: "page=x&status=draft&keyword=实验报告五&ids=1&ids=a", want: []Violation{
+			{In: "query", Field: "page", Message: "应为整数"},
+			{In: "query", Field: "status", Message: "取值应为 active、expired 之一"},
+			{In: "query", Field: "keyword", Message: "长度不能超过 4"},
+			{In: "query", Field: "ids[1]", Message: "应为整数"},
+		}},
+		{name: "only the first value is used", path: "/api/student/experiments", query: "keyword=lab&status=active&status=draft"},
+		{name: "path parameter", path: "/api/teacher/experiments/0", want: []Violation{
+			{In: "path", Field: "id", Message: "不能为空"},
+		}},
+		{name: "path parameter below minimum", path: "/api/teacher/experiments/-1", want: []Violation{
+			{In: "path", Field: "id", Message: "不能小于 1"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, params, ok := v.Find("GET", tt.path)
+			if !ok {
+				t.Fatalf("Find(GET %s) not found", tt.path)
+			}
+			query, _ := url.ParseQuery(tt.query)
+			if got := v.ValidateParams(op, params, query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateParams() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	v := newTestValidator(t)
+	op, _, _ := v.Find("PUT", "/api/teacher/experiments/1")
+	const valid = `"title":"lab","deadline":"2025-01-02T15:04:05+08:00","visible":false,"questions":[{"score":10,"type":"choice"}]`
+	tests := []struct {
+		name string
+		body string
+		want []Violation
+	}{
+		{name: "valid", body: `{` + valid + `}`},
+		{name: "unknown fields ignored", body: `{` + valid + `,"extra":1}`},
+		{name: "empty body", body: " ", want: []Violation{{In: "body", Message: "请求体不能为空"}}},
+		{name: "malformed", body: `{"title":`, want: []Violation{{In: "body", Message: "请求体不是合法的 JSON"}}},
+		{name: "not an object", body: `[]`, want: []Violation{{In: "body", Message: "应为对象"}}},
+		{name: "missing and zero required fields", body: `{"title":"","visible":null,"questions":[]}`, want: []Violation{
+			{In: "body", Field: "title", Message: "不能为空"},
+			{In: "body", Field: "deadline", Message: "不能为空"},
+			{In: "body", Field: "visible", Message: "不能为空"},
+			{In: "body", Field: "questions", Message: "至少需要 1 项"},
+		}},
+		// 必填字段按 required 的顺序报告，其余字段按名称排序
+		{name: "nested violations", body: `{"title":"实验报告一二","deadline":"2025-01-02","visible":true,"contact":"a@","questions":[{"score":0},{"score":1.5,"type":"essay"},{"score":"10"},{"score":101}]}`, want: []Violation{
+			{In: "body", Field: "contact", Message: "应为邮箱地址"},
+			{In: "body", Field: "deadline", Message: "应为 RFC3339 格式的时间，如 2006-01-02T15:04:05+08:00"},
+			{In: "body", Field: "questions[0].score", Message: "不能为空"},
+			{In: "body", Field: "questions[1].score", Message: "应为整数"},
+			{In: "body", Field: "questions[1].type", Message: "取值应为 choice、blank 之一"},
+			{In: "body", Field: "questions[2].score", Message: "应为整数"},
+			{In: "body", Field: "questions[3].score", Message: "不能大于 100"},
+			{In: "body", Field: "title", Message: "长度不能超过 5"},
+		}},
+		{name: "omitempty zero value", body: `{` + valid + `,"contact":""}`},
+		{name: "exclusive minimum", body: `{"title":"lab","deadline":"2025-01-02T15:04:05Z","visible":true,"questions":[{"score":-1}]}`, want: []Violation{
+			{In: "body", Field: "questions[0].score", Message: "应大于 0"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := v.ValidateBody(op, []byte(tt.body)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateBody() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gateway/config"
+	"gateway/metrics"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"shared/identity"
+	"shared/logger"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NoInstanceError 上游没有可用实例（全部不健康或被摘除）
+type NoInstanceError struct {
+	Upstream string
+}
+
+func (e *NoInstanceError) Error() string {
+	return fmt.Sprintf("upstream %s has no available instance", e.Upstream)
+}
+
+// InstanceStatus 上游实例的当前状态
+type InstanceStatus struct {
+	URL            string          `json:"url"`
+	Healthy        bool            `json:"healthy"`
+	Ejected        bool            `json:"ejected"`
+	Drained        bool            `json:"drained"`
+	ActiveRequests int64           `json:"active_requests"`
+	LastCheck      *UpstreamHealth `json:"last_check,omitempty"`
+}
+
+// instance 上游的一个实例
+type instance struct {
+	url  *url.URL
+	host string // 转发时的 Host 头，dns 发现的实例使用原主机名
+
+	active atomic.Int64 // 正在处理的请求数，least_conn 使用
+
+	mu           sync.Mutex
+	failures     int       // 连续失败次数
+	ejectedUntil time.Time // 被动摘除的到期时间
+	healthy      bool      // 最近一次主动健康检查的结果，检查前视为健康
+	drained      bool      // 通过管理接口摘除，不再分配新请求，正在处理的请求不受影响
+	lastCheck    *UpstreamHealth
+}
+
+func newInstance(u *url.URL, host string) *instance {
+	return &instance{url: u, host: host, healthy: true}
+}
+
+func (i *instance) available(now time.Time) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.healthy && !i.drained && !now.Before(i.ejectedUntil)
+}
+
+func (i *instance) status(now time.Time) InstanceStatus {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return InstanceStatus{
+		URL:            i.url.String(),
+		Healthy:        i.healthy,
+		Ejected:        now.Before(i.ejectedUntil),
+		Drained:        i.drained,
+		ActiveRequests: i.active.Load(),
+		LastCheck:      i.lastCheck,
+	}
+}
+
+// instanceList 某一时刻的实例列表，dns 发现更新时整体替换
+type instanceList struct {
+	instances []*instance
+	ring      []ringPoint // user_hash 使用的一致性哈希环
+}
+
+type ringPoint struct {
+	hash uint32
+	inst *instance
+}
+
+// 一致性哈希环上每个实例的虚拟节点数，实例增减时只有约 1/N 的用户换到别的实例
+const ringReplicas = 100
+
+func newInstanceList(instances []*instance) *instanceList {
+	l := &instanceList{instances: instances}
+	for _, inst := range instances {
+		for r := 0; r < ringReplicas; r++ {
+			h := crc32.ChecksumIEEE([]byte(inst.url.Host + "#" + strconv.Itoa(r)))
+			l.ring = append(l.ring, ringPoint{hash: h, inst: inst})
+		}
+	}
+	sort.Slice(l.ring, func(a, b int) bool { return l.ring[a].hash < l.ring[b].hash })
+	return l
+}
+
+// instanceSet 一个上游的所有实例，负责挑选实例和被动摘除
+type instanceSet struct {
+	name string
+	cfg  config.UpstreamConfig
+	base *url.URL // url 配置项，dns 发现解析它的主机名
+
+	current atomic.Pointer[instanceList]
+	next    atomic.Uint64 // 轮询计数
+	ejectMu sync.Mutex    // 判断摘除比例和摘除需要一起完成
+	now     func() time.Time
+}
+
+func newInstanceSet(name string, cfg config.UpstreamConfig) (*instanceSet, error) {
+	s := &instanceSet{name: name, cfg: cfg, now: time.Now}
+	var instances []*instance
+	for _, target := range cfg.Targets() {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, newInstance(u, u.Host))
+	}
+	s.base = instances[0].url
+	s.current.Store(newInstanceList(instances))
+	s.updateGauge()
+	return s, nil
+}
+
+func (s *instanceSet) instances() []*instance {
+	return s.current.Load().instances
+}
+
+// pick 按负载均衡策略挑选一个可用实例
+func (s *instanceSet) pick(req *http.Request) (*instance, error) {
+	list := s.current.Load()
+	now := s.now()
+
+	if s.cfg.Balancer == config.BalancerUserHash && len(list.ring) > 0 {
+		// 从用户所在位置沿哈希环找第一个可用实例，实例被摘除时用户临时落到下一个实例
+		h := crc32.ChecksumIEEE([]byte(hashKey(req)))
+		start := sort.Search(len(list.ring), func(i int) bool { return list.ring[i].hash >= h })
+		for i := 0; i < len(list.ring); i++ {
+			p := list.ring[(start+i)%len(list.ring)]
+			if p.inst.available(now) {
+				return p.inst, nil
+			}
+		}
+		return nil, &NoInstanceError{Upstream: s.name}
+	}
+
+	available := make([]*instance, 0, len(list.instances))
+	for _, inst := range list.instances {
+		if inst.available(now) {
+			available = append(available, inst)
+		}
+	}
+	if len(available) == 0 {
+		return nil, &NoInstanceError{Upstream: s.name}
+	}
+	start := int(s.next.Add(1) % uint64(len(available)))
+	if s.cfg.Balancer != config.BalancerLeastConn {
+		return available[start], nil
+	}
+	// 从轮询位置开始找正在处理请求最少的实例，请求数相同时不会总是落到第一个
+	best := available[start]
+	for i := 1; i < len(available); i++ {
+		inst := available[(start+i)%len(available)]
+		if inst.active.Load() < best.active.Load() {
+			best = inst
+		}
+	}
+	return best, nil
+}
+
+// hashKey user_hash 的哈希键：已登录请求使用用户 ID，否则使用客户端地址
+func hashKey(req *http.Request) string {
+	if id, ok := identity.FromContext(req.Context()); ok {
+		return "user:" + strconv.FormatUint(uint64(id.UserID), 10)
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// success 记录实例一次成功，清零连续失败次数
+func (s *instanceSet) success(inst *instance) {
+	inst.mu.Lock()
+	inst.failures = 0
+	inst.mu.Unlock()
+}
+
+// failure 记录实例一次失败，连续失败达到阈值且摘除比例允许时摘除实例
+func (s *instanceSet) failure(inst *instance) {
+	inst.mu.Lock()
+	inst.failures++
+	reached := inst.failures >= s.cfg.Ejection.ConsecutiveFailures
+	inst.mu.Unlock()
+	if !reached {
+		return
+	}
+
+	s.ejectMu.Lock()
+	defer s.ejectMu.Unlock()
+	now := s.now()
+	instances := s.instances()
+	ejected := 0
+	for _, other := range instances {
+		if other.status(now).Ejected {
+			ejected++
+		}
+	}
+	inst.mu.Lock()
+	if now.Before(inst.ejectedUntil) || (ejected+1)*100 > len(instances)*s.cfg.Ejection.MaxPercent {
+		inst.mu.Unlock()
+		return
+	}
+	inst.ejectedUntil = now.Add(s.cfg.Ejection.Duration)
+	inst.failures = 0
+	inst.mu.Unlock()
+
+	metrics.InstanceEjections.WithLabelValues(s.name).Inc()
+	s.updateGauge()
+	logger.Log.WithFields(logrus.Fields{
+		"upstream": s.name,
+		"instance": inst.url.String(),
+		"duration": s.cfg.Ejection.Duration.String(),
+	}).Warn("Ejected failing upstream instance")
+	// 摘除到期后更新可用实例数
+	time.AfterFunc(s.cfg.Ejection.Duration, s.updateGauge)
+}
+
+func (s *instanceSet) updateGauge() {
+	now := s.now()
+	available := 0
+	for _, inst := range s.instances() {
+		if inst.available(now) {
+			available++
+		}
+	}
+	metrics.AvailableInstances.WithLabelValues(s.name).Set(float64(available))
+}
+
+// statuses 返回所有实例的当前状态
+func (s *instanceSet) statuses() []InstanceStatus {
+	now := s.now()
+	instances := s.instances()
+	result := make([]InstanceStatus, 0, len(instances))
+	for _, inst := range instances {
+		result = append(result, inst.status(now))
+	}
+	return result
+}
+
+// setDrained 设置实例是否被手动摘除，instanceURL 不属于该上游时返回 false
+func (s *instanceSet) setDrained(instanceURL string, drained bool) bool {
+	for _, inst := range s.instances() {
+		if inst.url.String() != instanceURL {
+			continue
+		}
+		inst.mu.Lock()
+		inst.drained = drained
+		inst.mu.Unlock()
+		s.updateGauge()
+		return true
+	}
+	return false
+}
+
+// watchDNS 定期解析 url 的主机名，用解析到的地址替换实例列表，阻塞直到 ctx 结束
+func (s *instanceSet) watchDNS(ctx context.Context) {
+	s.resolve(ctx)
+	ticker := time.NewTicker(s.cfg.DNSRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.resolve(ctx)
+		}
+	}
+}
+
+// resolve 解析一次。解析失败或没有地址时保留原来的实例列表；
+// 地址没有变化的实例沿用原对象，保留健康状态和摘除状态
+func (s *instanceSet) resolve(ctx context.Context) {
+	log := logger.Log.WithFields(logrus.Fields{"upstream": s.name, "host": s.base.Hostname()})
+	lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(lookupCtx, s.base.Hostname())
+	if err == nil && len(addrs) == 0 {
+		err = errors.New("no address")
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			log.WithError(err).Warn("Failed to resolve upstream, keeping current instances")
+		}
+		return
+	}
+	sort.Strings(addrs)
+
+	port := s.base.Port()
+	if port == "" {
+		port = "80"
+		if s.base.Scheme == "https" {
+			port = "443"
+		}
+	}
+	existing := make(map[string]*instance)
+	for _, inst := range s.instances() {
+		existing[inst.url.Host] = inst
+	}
+	instances := make([]*instance, 0, len(addrs))
+	changed := len(addrs) != len(existing)
+	for _, addr := range addrs {
+		hostPort := net.JoinHostPort(addr, port)
+		if inst, ok := existing[hostPort]; ok {
+			instances = append(instances, inst)
+			continue
+		}
+		changed = true
+		u := *s.base
+		u.Host = hostPort
+		instances = append(instances, newInstance(&u, s.base.Host))
+	}
+	if !changed {
+		return
+	}
+	s.current.Store(newInstanceList(instances))
+	s.updateGauge()
+	log.WithField("instances", addrs).Info("Upstream instances changed")
+}
+
+// balancerTransport 为每个请求挑选实例并改写目标地址，记录实例的成功和失败
+type balancerTransport struct {
+	set  *instanceSet
+	next http.RoundTripper
+}
+
+func (t *balancerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	inst, err := t.set.pick(req)
+	if err != nil {
+		return nil, err
+	}
+	// RoundTripper 不能修改传入的请求，改写副本的地址
+	out := *req
+	u := *req.URL
+	u.Scheme = inst.url.Scheme
+	u.Host = inst.url.Host
+	out.URL = &u
+	out.Host = inst.host
+
+	inst.active.Add(1)
+	resp, err := t.next.RoundTrip(&out)
+	var openErr *CircuitOpenError
+	switch {
+	case errors.As(err, &openErr):
+		// 熔断拒绝的请求没有到达实例
+	case err != nil:
+		if upstreamFailed(req, err) {
+			t.set.failure(inst)
+		}
+	case resp.StatusCode == http.StatusBadGateway ||
+		resp.StatusCode == http.StatusServiceUnavailable ||
+		resp.StatusCode == http.StatusGatewayTimeout:
+		t.set.failure(inst)
+	default:
+		t.set.success(inst)
+	}
+	if err != nil {
+		inst.active.Add(-1)
+		return nil, err
+	}
+	// 响应体读完或关闭时才算请求结束，长连接会一直计数
+	resp.Body = newCountedBody(resp.Body, func() { inst.active.Add(-1) })
+	return resp, nil
+}
+
+// countedBody 关闭时调用一次 done
+type countedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *countedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// countedRWBody WebSocket 升级后的响应体可写，ReverseProxy 要求保留 io.Writer
+type countedRWBody struct {
+	*countedBody
+	w io.Writer
+}
+
+func (b *countedRWBody) Write(p []byte) (int, error) {
+	return b.w.Write(p)
+}
+
+func newCountedBody(body io.ReadCloser, done func()) io.ReadCloser {
+	cb := &countedBody{ReadCloser: body, done: done}
+	if w, ok := body.(io.Writer); ok {
+		return &countedRWBody{countedBody: cb, w: w}
+	}
+	return cb
+}
@@ -0,0 +1,225 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"gateway/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"shared/identity"
+	"shared/logger"
+	"testing"
+	"time"
+)
+
+var testInstances = []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.0.3:8080"}
+
+func newTestInstanceSet(t *testing.T, balancer string, instances ...string) *instanceSet {
+	t.Helper()
+	s, err := newInstanceSet("test", config.UpstreamConfig{
+		Instances: instances,
+		Balancer:  balancer,
+		Ejection:  config.EjectionConfig{ConsecutiveFailures: 2, Duration: time.Minute, MaxPercent: 50},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func userRequest(userID uint) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/student/experiments", nil)
+	return req.WithContext(identity.WithIdentity(req.Context(), identity.Identity{UserID: userID, Role: "student"}))
+}
+
+// pickHost 挑选实例，返回实例地址
+func pickHost(t *testing.T, s *instanceSet, req *http.Request) string {
+	t.Helper()
+	inst, err := s.pick(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inst.url.Host
+}
+
+func TestUserHashRing(t *testing.T) {
+	const users = 300
+	s := newTestInstanceSet(t, config.BalancerUserHash, testInstances...)
+	before := make(map[uint]string, users)
+	counts := make(map[string]int)
+	for id := uint(1); id <= users; id++ {
+		host := pickHost(t, s, userRequest(id))
+		if again := pickHost(t, s, userRequest(id)); again != host {
+			t.Fatalf("user %d moved from %s to %s without any change", id, host, again)
+		}
+		before[id] = host
+		counts[host]++
+	}
+	for host, n := range counts {
+		// 虚拟节点让用户大致均匀分布，每个实例都应分到不少于 1/6 的用户
+		if n < users/6 {
+			t.Errorf("instance %s got %d of %d users", host, n, users)
+		}
+	}
+
+	tests := []struct {
+		name string
+		// change 修改实例集合，返回可以接收新用户的实例
+		change func(t *testing.T, s *instanceSet) string
+	}{
+		{
+			name: "instance added",
+			change: func(t *testing.T, s *instanceSet) string {
+				added := newTestInstanceSet(t, config.BalancerUserHash, append(testInstances, "http://10.0.0.4:8080")...)
+				s.current.Store(added.current.Load())
+				return "10.0.0.4:8080"
+			},
+		},
+		{
+			name: "instance drained",
+			change: func(t *testing.T, s *instanceSet) string {
+				s.setDrained("http://10.0.0.2:8080", true)
+				return ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestInstanceSet(t, config.BalancerUserHash, testInstances...)
+			target := tt.change(t, s)
+			moved := 0
+			for id := uint(1); id <= users; id++ {
+				host := pickHost(t, s, userRequest(id))
+				if host == before[id] {
+					continue
+				}
+				moved++
+				// 新增实例时只有换到新实例的用户会移动；摘除实例时只有原来在该实例上的用户会移动
+				if target != "" && host != target {
+					t.Errorf("user %d moved from %s to %s, want %s", id, before[id], host, target)
+				}
+				if target == "" && before[id] != "10.0.0.2:8080" {
+					t.Errorf("user %d moved from %s although it was not drained", id, before[id])
+				}
+			}
+			if moved == 0 || moved > users/2 {
+				t.Errorf("%d of %d users moved", moved, users)
+			}
+		})
+	}
+}
+
+func TestUserHashAnonymousUsesClientIP(t *testing.T) {
+	s := newTestInstanceSet(t, config.BalancerUserHash, testInstances...)
+	hosts := make(map[string]bool)
+	for port := 40000; port < 40010; port++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/login", nil)
+		req.RemoteAddr = fmt.Sprintf("192.168.1.10:%d", port)
+		hosts[pickHost(t, s, req)] = true
+	}
+	if len(hosts) != 1 {
+		t.Errorf("requests from one client IP went to %d instances", len(hosts))
+	}
+}
+
+func TestLeastConn(t *testing.T) {
+	tests := []struct {
+		name    string
+		active  []int64
+		drained []bool
+		want    []string // 可能选中的实例
+	}{
+		{"fewest active requests", []int64{3, 1, 2}, nil, []string{"10.0.0.2:8080"}},
+		{"drained instance skipped", []int64{3, 0, 2}, []bool{false, true, false}, []string{"10.0.0.3:8080"}},
+		{"ties", []int64{1, 1, 5}, nil, []string{"10.0.0.1:8080", "10.0.0.2:8080"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestInstanceSet(t, config.BalancerLeastConn, testInstances...)
+			for i, inst := range s.instances() {
+				inst.active.Store(tt.active[i])
+				if tt.drained != nil && tt.drained[i] {
+					s.setDrained(inst.url.String(), true)
+				}
+			}
+			seen := make(map[string]bool)
+			for range 10 {
+				seen[pickHost(t, s, userRequest(1))] = true
+			}
+			if len(seen) != len(tt.want) {
+				t.Errorf("picked %v, want %v", seen, tt.want)
+			}
+			for _, host := range tt.want {
+				if !seen[host] {
+					t.Errorf("picked %v, want %v", seen, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	s := newTestInstanceSet(t, config.BalancerRoundRobin, testInstances...)
+	counts := make(map[string]int)
+	for range 30 {
+		counts[pickHost(t, s, userRequest(1))]++
+	}
+	for _, target := range testInstances {
+		if n := counts[target[len("http://"):]]; n != 10 {
+			t.Errorf("%s picked %d times, want 10", target, n)
+		}
+	}
+}
+
+func TestEjection(t *testing.T) {
+	out := logger.Log.Out
+	logger.Log.SetOutput(io.Discard)
+	t.Cleanup(func() { logger.Log.SetOutput(out) })
+
+	for _, balancer := range []string{config.BalancerRoundRobin, config.BalancerLeastConn, config.BalancerUserHash} {
+		t.Run(balancer, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			s := newTestInstanceSet(t, balancer, testInstances...)
+			s.now = func() time.Time { return now }
+			instances := s.instances()
+
+			// 连续失败 2 次摘除；最多摘除 50%，3 个实例中只能摘除 1 个
+			s.failure(instances[0])
+			if !instances[0].available(now) {
+				t.Fatal("instance ejected after a single failure")
+			}
+			s.failure(instances[0])
+			s.failure(instances[1])
+			s.failure(instances[1])
+			if instances[0].available(now) || !instances[1].available(now) {
+				t.Fatalf("available = %v, %v; want only the first instance ejected", instances[0].available(now), instances[1].available(now))
+			}
+			for id := uint(1); id <= 20; id++ {
+				if host := pickHost(t, s, userRequest(id)); host == instances[0].url.Host {
+					t.Fatalf("user %d sent to ejected instance", id)
+				}
+			}
+
+			now = now.Add(time.Minute)
+			if !instances[0].available(now) {
+				t.Error("instance still ejected after the ejection duration")
+			}
+		})
+	}
+}
+
+func TestNoInstanceAvailable(t *testing.T) {
+	for _, balancer := range []string{config.BalancerRoundRobin, config.BalancerLeastConn, config.BalancerUserHash} {
+		t.Run(balancer, func(t *testing.T) {
+			s := newTestInstanceSet(t, balancer, testInstances...)
+			for _, target := range testInstances {
+				s.setDrained(target, true)
+			}
+			var noInstance *NoInstanceError
+			if _, err := s.pick(userRequest(1)); !errors.As(err, &noInstance) {
+				t.Errorf("pick() error = %v, want NoInstanceError", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"fmt"
+	"gateway/config"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitOpenError 熔断期间直接拒绝请求时返回的错误
+type CircuitOpenError struct {
+	Upstream   string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for upstream %s is open", e.Upstream)
+}
+
+// Breaker 单个上游的熔断器
+//
+// closed: 正常放行，连续失败达到 FailureThreshold 次后进入 open；
+// open: 直接拒绝，经过 OpenTimeout 后进入 half-open；
+// half-open: 最多放行 HalfOpenRequests 个探测请求，全部成功则恢复 closed，任一失败则重新 open。
+type Breaker struct {
+	mu       sync.Mutex
+	cfg      config.CircuitBreakerConfig
+	state    BreakerState
+	failures int // closed 状态下的连续失败次数
+	openedAt time.Time
+	inFlight int // half-open 状态下已放行的探测请求数
+	passed   int // half-open 状态下已成功的探测请求数
+	now      func() time.Time
+}
+
+func NewBreaker(cfg config.CircuitBreakerConfig) *Breaker {
+	return &Breaker{cfg: cfg, now: time.Now}
+}
+
+// Allow 判断是否放行请求，不放行时返回还需等待的时间
+func (b *Breaker) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		wait := b.cfg.OpenTimeout - b.now().Sub(b.openedAt)
+		if wait > 0 {
+			return false, wait
+		}
+		b.toHalfOpen()
+		fallthrough
+	case StateHalfOpen:
+		if b.inFlight >= b.cfg.HalfOpenRequests {
+			return false, b.cfg.OpenTimeout
+		}
+		b.inFlight++
+	}
+	return true, 0
+}
+
+// Success 记录一次成功
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		b.failures = 0
+	case StateHalfOpen:
+		b.passed++
+		if b.passed >= b.cfg.HalfOpenRequests {
+			b.state = StateClosed
+			b.failures = 0
+		}
+	}
+}
+
+// Failure 记录一次失败
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.toOpen()
+		}
+	case StateHalfOpen:
+		b.toOpen()
+	}
+}
+
+// Release 放弃一次已放行但没有结果的请求，例如客户端主动断开
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateHalfOpen && b.inFlight > b.passed {
+		b.inFlight--
+	}
+}
+
+// Trip 立即熔断，用于主动健康检查失败时
+func (b *Breaker) Trip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != StateOpen {
+		b.toOpen()
+	}
+}
+
+// Probe 主动健康检查成功时调用，熔断中的上游提前进入半开状态，由真实请求确认是否恢复
+func (b *Breaker) Probe() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen {
+		b.toHalfOpen()
+	}
+}
+
+// State 返回当前状态
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) toOpen() {
+	b.state = StateOpen
+	b.openedAt = b.now()
+	b.failures = 0
+}
+
+func (b *Breaker) toHalfOpen() {
+	b.state = StateHalfOpen
+	b.inFlight = 0
+	b.passed = 0
+}
+
+// breakerTransport 在连接池外包一层熔断判断
+type breakerTransport struct {
+	upstream string
+	breaker  *Breaker
+	next     http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if ok, wait := t.breaker.Allow(); !ok {
+		return nil, &CircuitOpenError{Upstream: t.upstream, RetryAfter: wait}
+	}
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		if upstreamFailed(req, err) {
+			t.breaker.Failure()
+		} else {
+			t.breaker.Release()
+		}
+	case resp.StatusCode == http.StatusBadGateway ||
+		resp.StatusCode == http.StatusServiceUnavailable ||
+		resp.StatusCode == http.StatusGatewayTimeout:
+		t.breaker.Failure()
+	default:
+		t.breaker.Success()
+	}
+	return resp, err
+}
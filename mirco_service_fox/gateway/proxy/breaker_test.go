@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"gateway/config"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// breakerStep 对熔断器执行一次操作后检查状态；op 为 allow 时同时检查是否放行
+type breakerStep struct {
+	op      string // allow | success | failure | release | trip | probe | wait
+	wait    time.Duration
+	allowed bool
+	state   BreakerState
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	cfg := config.CircuitBreakerConfig{FailureThreshold: 3, OpenTimeout: 10 * time.Second, HalfOpenRequests: 2}
+	tests := []struct {
+		name  string
+		steps []breakerStep
+	}{
+		{
+			name: "opens after consecutive failures",
+			steps: []breakerStep{
+				{op: "failure", state: StateClosed},
+				{op: "failure", state: StateClosed},
+				{op: "failure", state: StateOpen},
+				{op: "allow", allowed: false, state: StateOpen},
+			},
+		},
+		{
+			name: "success resets the failure count",
+			steps: []breakerStep{
+				{op: "failure", state: StateClosed},
+				{op: "failure", state: StateClosed},
+				{op: "success", state: StateClosed},
+				{op: "failure", state: StateClosed},
+				{op: "failure", state: StateClosed},
+				{op: "allow", allowed: true, state: StateClosed},
+			},
+		},
+		{
+			name: "half-open after timeout and closes when probes succeed",
+			steps: []breakerStep{
+				{op: "trip", state: StateOpen},
+				{op: "wait", wait: 9 * time.Second, state: StateOpen},
+				{op: "allow", allowed: false, state: StateOpen},
+				{op: "wait", wait: time.Second, state: StateOpen},
+				{op: "allow", allowed: true, state: StateHalfOpen},
+				{op: "allow", allowed: true, state: StateHalfOpen},
+				{op: "allow", allowed: false, state: StateHalfOpen},
+				{op: "success", state: StateHalfOpen},
+				{op: "success", state: StateClosed},
+				{op: "allow", allowed: true, state: StateClosed},
+			},
+		},
+		{
+			name: "half-open failure reopens",
+			steps: []breakerStep{
+				{op: "trip", state: StateOpen},
+				{op: "wait", wait: 10 * time.Second, state: StateOpen},
+				{op: "allow", allowed: true, state: StateHalfOpen},
+				{op: "failure", state: StateOpen},
+				{op: "allow", allowed: false, state: StateOpen},
+			},
+		},
+		{
+			name: "release frees a half-open slot",
+			steps: []breakerStep{
+				{op: "trip", state: StateOpen},
+				{op: "probe", state: StateHalfOpen},
+				{op: "allow", allowed: true, state: StateHalfOpen},
+				{op: "allow", allowed: true, state: StateHalfOpen},
+				{op: "release", state: StateHalfOpen},
+				{op: "allow", allowed: true, state: StateHalfOpen},
+				{op: "allow", allowed: false, state: StateHalfOpen},
+			},
+		},
+		{
+			name: "probe only affects an open breaker",
+			steps: []breakerStep{
+				{op: "probe", state: StateClosed},
+				{op: "trip", state: StateOpen},
+				{op: "probe", state: StateHalfOpen},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			b := NewBreaker(cfg)
+			b.now = func() time.Time { return now }
+			for i, s := range tt.steps {
+				switch s.op {
+				case "allow":
+					if ok, _ := b.Allow(); ok != s.allowed {
+						t.Fatalf("step %d: Allow() = %v, want %v", i, ok, s.allowed)
+					}
+				case "success":
+					b.Success()
+				case "failure":
+					b.Failure()
+				case "release":
+					b.Release()
+				case "trip":
+					b.Trip()
+				case "probe":
+					b.Probe()
+				case "wait":
+					now = now.Add(s.wait)
+				}
+				if got := b.State(); got != s.state {
+					t.Fatalf("step %d (%s): state = %v, want %v", i, s.op, got, s.state)
+				}
+			}
+		})
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestBreakerTransportCountsUpstreamFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		err      error
+		canceled bool // 客户端已断开
+		state    BreakerState
+	}{
+		{"ok", http.StatusOK, nil, false, StateClosed},
+		{"client error", http.StatusNotFound, nil, false, StateClosed},
+		{"internal error", http.StatusInternalServerError, nil, false, StateClosed},
+		{"bad gateway", http.StatusBadGateway, nil, false, StateOpen},
+		{"unavailable", http.StatusServiceUnavailable, nil, false, StateOpen},
+		{"gateway timeout", http.StatusGatewayTimeout, nil, false, StateOpen},
+		{"connection refused", 0, errors.New("connection refused"), false, StateOpen},
+		{"client canceled", 0, context.Canceled, true, StateClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := NewBreaker(config.CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenRequests: 1})
+			now := time.Unix(1700000000, 0)
+			breaker.now = func() time.Time { return now }
+			rt := &breakerTransport{upstream: "test", breaker: breaker, next: roundTripFunc(func(*http.Request) (*http.Response, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.canceled {
+				cancel()
+			}
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://test/api", nil)
+			if resp, err := rt.RoundTrip(req); err == nil {
+				resp.Body.Close()
+			}
+			if got := breaker.State(); got != tt.state {
+				t.Fatalf("state = %v, want %v", got, tt.state)
+			}
+			if tt.state == StateOpen {
+				var open *CircuitOpenError
+				if _, err := rt.RoundTrip(req); !errors.As(err, &open) || open.RetryAfter != time.Minute {
+					t.Errorf("RoundTrip() while open = %v, want CircuitOpenError with RetryAfter 1m", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"gateway/config"
+	"net/http"
+	"shared/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UpstreamHealth 上游实例一次主动健康检查的结果
+type UpstreamHealth struct {
+	Healthy   bool          `json:"healthy"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Latency   time.Duration `json:"latency"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// healthChecker 定期请求每个上游所有实例的健康检查接口。不健康的实例不再分配请求；
+// 上游的实例全部不健康时直接熔断，有实例恢复后熔断器进入半开状态
+type healthChecker struct {
+	cfg    config.HealthCheckConfig
+	client *http.Client
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newHealthChecker(cfg config.HealthCheckConfig) *healthChecker {
+	return &healthChecker{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (h *healthChecker) start(sets map[string]*instanceSet, breakers map[string]*Breaker) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	for name, set := range sets {
+		h.wg.Add(1)
+		go h.run(ctx, set, breakers[name])
+	}
+}
+
+func (h *healthChecker) run(ctx context.Context, set *instanceSet, breaker *Breaker) {
+	defer h.wg.Done()
+	ticker := time.NewTicker(h.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		// 实例列表可能随 dns 解析变化，每轮重新获取
+		instances := set.instances()
+		var wg sync.WaitGroup
+		for _, inst := range instances {
+			wg.Add(1)
+			go func(inst *instance) {
+				defer wg.Done()
+				h.checkInstance(ctx, set, inst)
+			}(inst)
+		}
+		wg.Wait()
+		set.updateGauge()
+
+		anyHealthy := false
+		for _, inst := range instances {
+			if inst.status(time.Now()).Healthy {
+				anyHealthy = true
+				break
+			}
+		}
+		if anyHealthy {
+			breaker.Probe()
+		} else {
+			breaker.Trip()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *healthChecker) checkInstance(ctx context.Context, set *instanceSet, inst *instance) {
+	result := h.check(ctx, strings.TrimSuffix(inst.url.String(), "/")+set.cfg.HealthPath, inst.host)
+	if ctx.Err() != nil {
+		return
+	}
+	inst.mu.Lock()
+	prev := inst.lastCheck
+	inst.lastCheck = &result
+	inst.healthy = result.Healthy
+	inst.mu.Unlock()
+	if prev == nil || prev.Healthy != result.Healthy {
+		logger.Log.WithFields(logrus.Fields{
+			"upstream": set.name,
+			"instance": inst.url.String(),
+			"healthy":  result.Healthy,
+			"error":    result.Error,
+		}).Info("Upstream health changed")
+	}
+}
+
+func (h *healthChecker) check(ctx context.Context, url, host string) UpstreamHealth {
+	start := time.Now()
+	result := UpstreamHealth{CheckedAt: start}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Host = host
+	resp, err := h.client.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		result.Error = fmt.Sprintf("status %d", resp.StatusCode)
+		return result
+	}
+	result.Healthy = true
+	return result
+}
+
+func (h *healthChecker) stop() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	h.wg.Wait()
+}
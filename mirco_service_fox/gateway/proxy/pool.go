@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"gateway/config"
+	"net/http"
+	"net/http/httputil"
+	"shared/identity"
+	"shared/tracing"
+	"sync"
+)
+
+// Pool 每个上游一个反向代理、连接池和熔断器，启动时创建，所有请求复用。
+// 上游可以有多个实例，每个请求按上游的负载均衡策略挑选实例
+type Pool struct {
+	proxies    map[string]*httputil.ReverseProxy
+	streams    map[string]*streamProxy
+	transports []*http.Transport
+	breakers   map[string]*Breaker
+	sets       map[string]*instanceSet
+	health     *healthChecker
+
+	cancel context.CancelFunc // 停止 dns 解析
+	wg     sync.WaitGroup
+}
+
+// NewPool 为配置中的每个上游创建反向代理
+func NewPool(cfg *config.ServiceConfig) (*Pool, error) {
+	p := &Pool{
+		proxies:    make(map[string]*httputil.ReverseProxy, len(cfg.Upstreams)),
+		streams:    make(map[string]*streamProxy, len(cfg.Upstreams)),
+		transports: make([]*http.Transport, 0, 2*len(cfg.Upstreams)),
+		breakers:   make(map[string]*Breaker, len(cfg.Upstreams)),
+		sets:       make(map[string]*instanceSet, len(cfg.Upstreams)),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	signer := identity.NewSigner(cfg.InternalIdentitySecret)
+	for name, up := range cfg.Upstreams {
+		set, err := newInstanceSet(name, up)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("upstream %q: %w", name, err)
+		}
+		p.sets[name] = set
+		transport := NewTransport(up.Transport)
+		breaker := NewBreaker(up.CircuitBreaker)
+		// 先挑选实例再经过链路追踪和熔断：span 中是实际请求的实例地址，熔断拒绝的请求也能在链路中看到
+		rt := &balancerTransport{set: set, next: tracing.Transport(&breakerTransport{upstream: name, breaker: breaker, next: transport})}
+		rp, err := NewReverseProxy(up.Targets()[0], rt, signer)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("upstream %q: %w", name, err)
+		}
+		p.proxies[name] = rp
+		p.transports = append(p.transports, transport)
+		p.breakers[name] = breaker
+
+		// 长连接使用单独的连接池：不等待响应头超时，数据按 flush_interval 刷新给客户端
+		streamCfg := up.Transport
+		streamCfg.ResponseHeaderTimeout = 0
+		streamTransport := NewTransport(streamCfg)
+		srt := &balancerTransport{set: set, next: tracing.Transport(&breakerTransport{upstream: name, breaker: breaker, next: streamTransport})}
+		srp, err := NewReverseProxy(up.Targets()[0], srt, signer)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("upstream %q: %w", name, err)
+		}
+		srp.FlushInterval = -1
+		if cfg.Streaming.FlushInterval > 0 {
+			srp.FlushInterval = cfg.Streaming.FlushInterval
+		}
+		p.streams[name] = &streamProxy{upstream: name, rp: srp, idleTimeout: cfg.Streaming.IdleTimeout}
+		p.transports = append(p.transports, streamTransport)
+
+		if up.Discovery == config.DiscoveryDNS {
+			// 先同步解析一次，解析失败时暂时使用 url 本身
+			set.resolve(ctx)
+			p.wg.Add(1)
+			go func() {
+				defer p.wg.Done()
+				set.watchDNS(ctx)
+			}()
+		}
+	}
+	if cfg.HealthCheck.Enabled {
+		p.health = newHealthChecker(cfg.HealthCheck)
+		p.health.start(p.sets, p.breakers)
+	}
+	return p, nil
+}
+
+// Get 返回上游对应的反向代理
+func (p *Pool) Get(upstream string) (*httputil.ReverseProxy, bool) {
+	rp, ok := p.proxies[upstream]
+	return rp, ok
+}
+
+// GetStream 返回上游的长连接代理，用于 SSE 和 WebSocket 请求
+func (p *Pool) GetStream(upstream string) (http.Handler, bool) {
+	sp, ok := p.streams[upstream]
+	return sp, ok
+}
+
+// Breaker 返回上游的熔断器
+func (p *Pool) Breaker(upstream string) (*Breaker, bool) {
+	b, ok := p.breakers[upstream]
+	return b, ok
+}
+
+// Instances 返回上游所有实例的当前状态
+func (p *Pool) Instances(upstream string) ([]InstanceStatus, bool) {
+	set, ok := p.sets[upstream]
+	if !ok {
+		return nil, false
+	}
+	return set.statuses(), true
+}
+
+// SetDrained 手动摘除或恢复上游的一个实例，上游或实例不存在时返回 false。
+// 摘除后不再分配新请求，正在处理的请求和长连接不受影响
+func (p *Pool) SetDrained(upstream, instanceURL string, drained bool) bool {
+	set, ok := p.sets[upstream]
+	if !ok {
+		return false
+	}
+	return set.setDrained(instanceURL, drained)
+}
+
+// Close 停止健康检查和 dns 解析，关闭所有空闲连接
+func (p *Pool) Close() {
+	if p.health != nil {
+		p.health.stop()
+	}
+	p.cancel()
+	p.wg.Wait()
+	for _, t := range p.transports {
+		t.CloseIdleConnections()
+	}
+}
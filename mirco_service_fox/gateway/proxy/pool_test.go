@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"gateway/config"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"shared/logger"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestUpstream 返回的上游统计新建的 TCP 连接数
+func newTestUpstream(tb testing.TB) (*httptest.Server, *atomic.Int64) {
+	out := logger.Log.Out
+	logger.Log.SetOutput(io.Discard)
+	tb.Cleanup(func() { logger.Log.SetOutput(out) })
+	var conns atomic.Int64
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":"success"}`)
+	}))
+	upstream.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	upstream.Start()
+	tb.Cleanup(upstream.Close)
+	return upstream, &conns
+}
+
+func newTestPool(tb testing.TB, url string) *Pool {
+	pool, err := NewPool(&config.ServiceConfig{
+		Upstreams: map[string]config.UpstreamConfig{
+			"test": {
+				URL:            url,
+				Transport:      config.DefaultTransportConfig(),
+				CircuitBreaker: config.DefaultCircuitBreakerConfig(),
+			},
+		},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(pool.Close)
+	return pool
+}
+
+// serveOnce 转发一个请求，返回状态码以及是否为 200
+func serveOnce(h http.Handler) (ok bool, status int) {
+	req := httptest.NewRequest(http.MethodGet, "/api/student/experiments", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code == http.StatusOK, rec.Code
+}
+
+func TestPoolGet(t *testing.T) {
+	upstream, _ := newTestUpstream(t)
+	pool := newTestPool(t, upstream.URL)
+	tests := []struct {
+		upstream string
+		ok       bool
+	}{
+		{"test", true},
+		{"unknown", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.upstream, func(t *testing.T) {
+			rp, ok := pool.Get(tt.upstream)
+			if ok != tt.ok {
+				t.Fatalf("Get(%q) ok = %v, want %v", tt.upstream, ok, tt.ok)
+			}
+			again, _ := pool.Get(tt.upstream)
+			if rp != again {
+				t.Errorf("Get(%q) returned a different proxy on the second call", tt.upstream)
+			}
+			if _, ok := pool.GetStream(tt.upstream); ok != tt.ok {
+				t.Errorf("GetStream(%q) ok = %v, want %v", tt.upstream, ok, tt.ok)
+			}
+			if _, ok := pool.Breaker(tt.upstream); ok != tt.ok {
+				t.Errorf("Breaker(%q) ok = %v, want %v", tt.upstream, ok, tt.ok)
+			}
+		})
+	}
+}
+
+// TestPoolReusesConnections 顺序转发的请求复用同一个上游连接，每个请求新建代理时每次都要重新建连
+func TestPoolReusesConnections(t *testing.T) {
+	const requests = 20
+	tests := []struct {
+		name      string
+		handler   func(t *testing.T, url string) func() http.Handler
+		wantConns int64
+	}{
+		{
+			name: "pooled",
+			handler: func(t *testing.T, url string) func() http.Handler {
+				rp, _ := newTestPool(t, url).Get("test")
+				return func() http.Handler { return rp }
+			},
+			wantConns: 1,
+		},
+		{
+			name: "per request",
+			handler: func(t *testing.T, url string) func() http.Handler {
+				return func() http.Handler {
+					transport := NewTransport(config.DefaultTransportConfig())
+					t.Cleanup(transport.CloseIdleConnections)
+					rp, err := NewReverseProxy(url, transport, nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+					return rp
+				}
+			},
+			wantConns: requests,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream, conns := newTestUpstream(t)
+			next := tt.handler(t, upstream.URL)
+			for i := 0; i < requests; i++ {
+				if ok, status := serveOnce(next()); !ok {
+					t.Fatalf("request %d: unexpected status %d", i, status)
+				}
+			}
+			if got := conns.Load(); got != tt.wantConns {
+				t.Errorf("upstream connections = %d, want %d", got, tt.wantConns)
+			}
+		})
+	}
+}
+
+// 对比每个请求新建代理和复用连接池两种方式的吞吐量：
+//
+//	go test ./proxy -bench . -benchmem
+//
+// RunParallel 的 goroutine 中不能调用 b.Fatal，出错时用 b.Error 记录后结束当前 goroutine
+
+// BenchmarkPerRequestProxy 旧实现：每个请求新建 ReverseProxy 和 Transport
+func BenchmarkPerRequestProxy(b *testing.B) {
+	upstream, _ := newTestUpstream(b)
+	cfg := config.DefaultTransportConfig()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			transport := NewTransport(cfg)
+			rp, err := NewReverseProxy(upstream.URL, transport, nil)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			ok, status := serveOnce(rp)
+			transport.CloseIdleConnections()
+			if !ok {
+				b.Errorf("unexpected status %d", status)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkPooledProxy 新实现：启动时创建代理，所有请求复用
+func BenchmarkPooledProxy(b *testing.B) {
+	upstream, _ := newTestUpstream(b)
+	rp, _ := newTestPool(b, upstream.URL).Get("test")
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if ok, status := serveOnce(rp); !ok {
+				b.Errorf("unexpected status %d", status)
+				return
+			}
+		}
+	})
+}
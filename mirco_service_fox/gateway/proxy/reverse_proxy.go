@@ -1,56 +1,126 @@
 package proxy
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gateway/config"
-	"log"
+	"gateway/metrics"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"strings"
+	"shared/identity"
+	"shared/logger"
+	"strconv"
 	"time"
 )
 
-type contextKey string
-
-const (
-	userIDKey   contextKey = "userID"
-	userRoleKey contextKey = "userRole"
-)
+// NewTransport 按配置创建上游连接池，同一上游的所有请求共用
+func NewTransport(cfg config.TransportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+}
 
-func NewReverseProxy(target string) (*httputil.ReverseProxy, error) {
+// NewReverseProxy 创建到 target 的反向代理，上游有多个实例时由 transport 改写为实际的实例地址。
+// 客户端带来的身份头一律删除，
+// 已登录的请求由 signer 签发内部身份断言交给上游校验。
+func NewReverseProxy(target string, transport http.RoundTripper, signer *identity.Signer) (*httputil.ReverseProxy, error) {
 	targetUrl, err := url.Parse(target)
 	if err != nil {
 		return nil, err
 	}
 	proxy := httputil.NewSingleHostReverseProxy(targetUrl)
-
-	// 设置超时
-	proxy.Transport = &http.Transport{
-		ResponseHeaderTimeout: 30 * time.Second,
-	}
+	proxy.Transport = transport
 	// 修改请求头，确保正确传递
 	originalDirector := proxy.Director
 	proxy.Director = func(req *http.Request) {
 		originalDirector(req)
-		log.Printf("Proxying request to: %s", targetUrl.String())
+		logger.FromContext(req.Context()).WithField("target", targetUrl.String()).Debug("Proxying request")
 		req.Header.Set("X-Forwarded-Host", req.Header.Get("Host"))
 		req.Header.Set("X-Original-URI", req.URL.String())
 
-		// 传递用户信息（如果上游服务需要）
-		if userID, exists := req.Context().Value(userIDKey).(string); exists {
-			req.Header.Set("X-User-ID", userID)
-		}
-		if userRole, exists := req.Context().Value(userRoleKey).(string); exists {
-			req.Header.Set("X-User-Role", userRole)
+		// 上游只信任网关签发的身份断言，客户端自带的身份头不能透传
+		req.Header.Del(identity.Header)
+		req.Header.Del(identity.UserIDHeader)
+		req.Header.Del(identity.UserRoleHeader)
+		if id, ok := identity.FromContext(req.Context()); ok && signer != nil {
+			assertion, err := signer.Sign(id)
+			if err != nil {
+				logger.FromContext(req.Context()).WithError(err).Error("Failed to sign identity assertion")
+			} else {
+				req.Header.Set(identity.Header, assertion)
+			}
 		}
 		req.Host = targetUrl.Host
 	}
 
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		// 熔断中直接返回 503，不再等待上游超时
+		var openErr *CircuitOpenError
+		if errors.As(err, &openErr) {
+			metrics.CircuitRejected.WithLabelValues(openErr.Upstream).Inc()
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			jsonData, _ := json.Marshal(map[string]string{
+				"error":    "service unavailable",
+				"message":  "Upstream service is temporarily unavailable, please retry later",
+				"upstream": openErr.Upstream,
+			})
+			w.Write(jsonData)
+			return
+		}
+		// 实例全部不健康或被摘除
+		var noInstanceErr *NoInstanceError
+		if errors.As(err, &noInstanceErr) {
+			logger.FromContext(r.Context()).WithError(err).Error("No available upstream instance")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			jsonData, _ := json.Marshal(map[string]string{
+				"error":    "service unavailable",
+				"message":  "No healthy upstream instance is available, please retry later",
+				"upstream": noInstanceErr.Upstream,
+			})
+			w.Write(jsonData)
+			return
+		}
+		// 请求体超过路由的大小上限（没有 Content-Length 的请求在转发过程中才能发现）
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			WriteBodyTooLarge(w, maxBytesErr.Limit)
+			return
+		}
+		// 超过路由的超时时间
+		if errors.Is(err, context.DeadlineExceeded) {
+			logger.FromContext(r.Context()).WithError(err).Warn("Upstream request timed out")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusGatewayTimeout)
+			jsonData, _ := json.Marshal(map[string]string{
+				"error":   "gateway timeout",
+				"message": "Upstream service did not respond in time",
+			})
+			w.Write(jsonData)
+			return
+		}
 		errorMsg := fmt.Sprintf("Error occurred while proxying request: %v", err)
-		log.Print(errorMsg)
+		logger.FromContext(r.Context()).WithError(err).Error("Error occurred while proxying request")
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadGateway)
 		response := map[string]string{
@@ -64,36 +134,24 @@ func NewReverseProxy(target string) (*httputil.ReverseProxy, error) {
 	return proxy, nil
 }
 
-// 根据路径确定目标服务
-func GetTargetService(path string, cfg *config.ServiceConfig) string {
-	// 这一处的代码转发需要根据实际路径进行修改
-	switch {
-	// 用户服务路由
-	case strings.HasPrefix(path, "/api/auth") ||
-		strings.HasPrefix(path, "/api/student_list") ||
-		strings.HasPrefix(path, "/api/teacher/students") ||
-		strings.HasPrefix(path, "/api/teacher/groups") ||
-		strings.HasPrefix(path, "/internal/users"):
-		return cfg.UserServiceURL
-
-	// 通知服务路由
-	case strings.HasPrefix(path, "/api/teacher/experiments/notifications") ||
-		strings.HasPrefix(path, "/api/student/experiments/notifications"):
-		return cfg.NotificationServiceURL
-
-	// 提交服务路由
-	case strings.Contains(path, "/save") ||
-		strings.Contains(path, "/submit") ||
-		strings.HasPrefix(path, "/api/student/submissions"):
-		return cfg.SubmissionServiceURL
-
-	// 实验服务路由
-	case strings.HasPrefix(path, "/api/student/experiments") ||
-		strings.HasPrefix(path, "/api/teacher/experiments") ||
-		strings.HasPrefix(path, "/api/experiments"):
-		return cfg.ExperimentServiceURL
+// WriteBodyTooLarge 返回 413，请求体超过 limit 字节
+func WriteBodyTooLarge(w http.ResponseWriter, limit int64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	jsonData, _ := json.Marshal(map[string]interface{}{
+		"error":   "request entity too large",
+		"message": "Request body exceeds the limit of " + config.ByteSize(limit).String(),
+		"limit":   limit,
+	})
+	w.Write(jsonData)
+}
 
-	default:
-		return cfg.UserServiceURL // 默认回退到用户服务
+// upstreamFailed 判断转发出错是否算上游故障：客户端主动断开、请求体超过上限不算，超时算
+func upstreamFailed(req *http.Request, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return false
 	}
+	return !errors.Is(req.Context().Err(), context.Canceled)
 }
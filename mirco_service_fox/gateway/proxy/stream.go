@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"gateway/metrics"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"shared/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 长连接类型
+const (
+	StreamSSE       = "sse"
+	StreamWebSocket = "websocket"
+)
+
+// StreamKind 判断请求是否为长连接：WebSocket 升级请求或 Accept: text/event-stream 的 SSE 请求
+func StreamKind(r *http.Request) (string, bool) {
+	if headerContainsToken(r.Header, "Connection", "upgrade") && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return StreamWebSocket, true
+	}
+	if headerContainsToken(r.Header, "Accept", "text/event-stream") {
+		return StreamSSE, true
+	}
+	return "", false
+}
+
+// headerContainsToken 判断逗号分隔的请求头中是否包含 token（忽略大小写和参数）
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if i := strings.IndexByte(part, ';'); i >= 0 {
+				part = part[:i]
+			}
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// streamProxy 转发长连接，两个方向都没有数据超过 idleTimeout 就取消请求，
+// ReverseProxy 随之关闭上游连接和客户端连接
+type streamProxy struct {
+	upstream    string
+	rp          *httputil.ReverseProxy
+	idleTimeout time.Duration
+}
+
+func (s *streamProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kind, _ := StreamKind(r)
+	gauge := metrics.StreamConnections.WithLabelValues(s.upstream, kind)
+	gauge.Inc()
+	defer gauge.Dec()
+
+	ctx, cancel := context.WithCancelCause(r.Context())
+	defer cancel(nil)
+	idle := newIdleTimer(s.idleTimeout, func() { cancel(errStreamIdle) })
+	defer idle.stop()
+	defer activeStreams.add(cancel)()
+
+	// 响应头已发出后读上游出错时 ReverseProxy 会 panic(http.ErrAbortHandler)，
+	// 空闲断开和网关退出时断开属于正常结束，不再向上抛
+	defer func() {
+		rec := recover()
+		cause := context.Cause(ctx)
+		if !errors.Is(cause, errStreamIdle) && !errors.Is(cause, errStreamShutdown) {
+			if rec != nil {
+				panic(rec)
+			}
+			return
+		}
+		if rec != nil && rec != http.ErrAbortHandler {
+			panic(rec)
+		}
+		if errors.Is(cause, errStreamShutdown) {
+			logger.FromContext(r.Context()).WithField("kind", kind).Info("Closed streaming connection for shutdown")
+			return
+		}
+		metrics.StreamIdleClosed.WithLabelValues(s.upstream, kind).Inc()
+		logger.FromContext(r.Context()).WithField("kind", kind).Info("Closed idle streaming connection")
+	}()
+
+	s.rp.ServeHTTP(&streamWriter{ResponseWriter: w, idle: idle}, r.WithContext(ctx))
+}
+
+var (
+	errStreamIdle     = errors.New("stream idle timeout")
+	errStreamShutdown = errors.New("gateway shutting down")
+)
+
+// activeStreams 所有连接池中正在转发的长连接，网关退出时统一断开
+var activeStreams = &streamRegistry{cancels: make(map[*context.CancelCauseFunc]struct{})}
+
+type streamRegistry struct {
+	mu      sync.Mutex
+	cancels map[*context.CancelCauseFunc]struct{}
+}
+
+// add 登记一个长连接，返回的函数在连接结束时注销
+func (r *streamRegistry) add(cancel context.CancelCauseFunc) func() {
+	key := &cancel
+	r.mu.Lock()
+	r.cancels[key] = struct{}{}
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		delete(r.cancels, key)
+		r.mu.Unlock()
+	}
+}
+
+// CloseStreams 断开所有正在转发的 SSE 和 WebSocket 连接，客户端重连到其它网关实例。
+// http.Server.Shutdown 不会等待已接管的 WebSocket 连接，SSE 请求也不会自己结束，
+// 退出时需要主动断开
+func CloseStreams() {
+	activeStreams.mu.Lock()
+	defer activeStreams.mu.Unlock()
+	for cancel := range activeStreams.cancels {
+		(*cancel)(errStreamShutdown)
+	}
+}
+
+// idleTimer 每次有数据时重新计时，超时后调用 onIdle
+type idleTimer struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func newIdleTimer(timeout time.Duration, onIdle func()) *idleTimer {
+	return &idleTimer{timeout: timeout, timer: time.AfterFunc(timeout, onIdle)}
+}
+
+func (t *idleTimer) touch() {
+	t.mu.Lock()
+	t.timer.Reset(t.timeout)
+	t.mu.Unlock()
+}
+
+func (t *idleTimer) stop() {
+	t.mu.Lock()
+	t.timer.Stop()
+	t.mu.Unlock()
+}
+
+// streamWriter 在写出数据时重新计时；WebSocket 升级后接管的连接在读写时重新计时
+type streamWriter struct {
+	http.ResponseWriter
+	idle *idleTimer
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.idle.touch()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *streamWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *streamWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &idleConn{Conn: conn, idle: w.idle}, brw, nil
+}
+
+func (w *streamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type idleConn struct {
+	net.Conn
+	idle *idleTimer
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.idle.touch()
+	}
+	return n, err
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	c.idle.touch()
+	return c.Conn.Write(b)
+}
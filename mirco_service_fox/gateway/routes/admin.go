@@ -0,0 +1,267 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"gateway/middleware"
+	"net/http"
+	"shared/logger"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AdminTokenHeader 管理接口的令牌请求头
+const AdminTokenHeader = "X-Admin-Token"
+
+// 维护模式未指定提示信息时返回给客户端的内容
+const defaultMaintenanceMessage = "该功能正在维护，请稍后再试"
+
+// 审计记录查询每页的默认条数和上限
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AdminHandler 管理接口：查看路由表、上游实例状态、限流配置和最近的错误数，
+// 手动摘除上游实例、把路由设为维护模式，查询审计记录
+type AdminHandler struct {
+	rt     *Runtime
+	errors *middleware.ErrorCounter
+	audit  middleware.AuditStore
+}
+
+// NewAdminRouter 创建管理接口的路由，单独监听，所有接口都需要 X-Admin-Token。
+// audit 为 nil（审计关闭）时不注册审计查询接口
+func NewAdminRouter(rt *Runtime, errors *middleware.ErrorCounter, audit middleware.AuditStore, auth *AuthHandler, token string) *gin.Engine {
+	h := &AdminHandler{rt: rt, errors: errors, audit: audit}
+	r := gin.New()
+	r.Use(gin.Recovery(), middleware.RequestIDMiddleware(), middleware.AccessLogMiddleware())
+	admin := r.Group("/admin", requireAdmin(token))
+	admin.GET("/routes", h.Routes)
+	admin.GET("/upstreams", h.Upstreams)
+	admin.GET("/rate-limits", h.RateLimits)
+	admin.GET("/errors", h.Errors)
+	admin.POST("/upstreams/:name/drain", h.Drain)
+	admin.DELETE("/upstreams/:name/drain", h.Undrain)
+	admin.PUT("/routes/:name/maintenance", h.SetMaintenance)
+	admin.DELETE("/routes/:name/maintenance", h.ClearMaintenance)
+	admin.POST("/users/:id/revoke-tokens", auth.RevokeUserTokens)
+	if audit != nil {
+		admin.GET("/audit", h.Audit)
+	}
+	return r
+}
+
+// requireAdmin 校验 X-Admin-Token 请求头
+func requireAdmin(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader(AdminTokenHeader)), []byte(token)) != 1 {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "需要管理员权限"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Routes 按匹配优先级列出当前路由表，包括生效的超时、请求体上限、限流类别和维护状态
+func (h *AdminHandler) Routes(c *gin.Context) {
+	state := h.rt.load()
+	routes := make([]gin.H, 0, len(state.table.Routes()))
+	for _, r := range state.table.Routes() {
+		class, _, _ := state.cfg.RateLimit.Rule(r.RateLimit)
+		item := gin.H{
+			"name":          r.Name,
+			"match":         r.Match,
+			"path":          r.Path,
+			"methods":       r.Methods,
+			"upstream":      r.Upstream,
+			"strip_prefix":  r.StripPrefix,
+			"rewrite":       r.Rewrite,
+			"role":          r.Role,
+			"rate_limit":    class,
+			"timeout":       r.Timeout.String(),
+			"max_body_size": r.MaxBodySize.String(),
+			"scopes":        gin.H{"read": r.Scopes.Read, "write": r.Scopes.Write},
+		}
+		if r.Canary != nil {
+			item["canary"] = r.Canary
+		}
+		if r.Mirror != nil {
+			item["mirror"] = gin.H{
+				"upstream":      r.Mirror.Upstream,
+				"percent":       r.Mirror.Percent,
+				"methods":       r.Mirror.Methods,
+				"timeout":       r.Mirror.Timeout.String(),
+				"max_body_size": r.Mirror.MaxBodySize.String(),
+				"compare":       r.Mirror.Compare,
+				"ignore_fields": r.Mirror.IgnoreFields,
+			}
+		}
+		if message, ok := h.rt.Maintenance(r.Name); ok {
+			item["maintenance"] = gin.H{"message": message}
+		}
+		routes = append(routes, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "data": routes})
+}
+
+// Upstreams 列出每个上游的负载均衡策略、熔断状态和所有实例的健康、摘除状态
+func (h *AdminHandler) Upstreams(c *gin.Context) {
+	state := h.rt.load()
+	upstreams := make(gin.H, len(state.cfg.Upstreams))
+	for name, up := range state.cfg.Upstreams {
+		item := gin.H{
+			"balancer":  up.Balancer,
+			"discovery": up.Discovery,
+		}
+		if breaker, ok := state.pool.Breaker(name); ok {
+			item["circuit_state"] = breaker.State().String()
+		}
+		if instances, ok := state.pool.Instances(name); ok {
+			item["instances"] = instances
+		}
+		upstreams[name] = item
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "data": upstreams})
+}
+
+// RateLimits 返回当前生效的限流配置
+func (h *AdminHandler) RateLimits(c *gin.Context) {
+	cfg := h.rt.load().cfg.RateLimit
+	classes := make(gin.H, len(cfg.Classes))
+	for name, rule := range cfg.Classes {
+		burst := rule.Burst
+		if burst == 0 {
+			burst = rule.Requests
+		}
+		classes[name] = gin.H{"requests": rule.Requests, "per": rule.Per.String(), "burst": burst}
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{
+		"enabled":       cfg.Enabled,
+		"default_class": cfg.DefaultClass,
+		"classes":       classes,
+	}})
+}
+
+// Errors 返回每个路由最近 1、5、15 分钟的 4xx、5xx 响应数
+func (h *AdminHandler) Errors(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"code": 200, "data": h.errors.Snapshot()})
+}
+
+// Drain 手动摘除上游实例（?instance=实例地址），不再分配新请求，正在处理的请求不受影响
+func (h *AdminHandler) Drain(c *gin.Context) {
+	h.setDrained(c, true)
+}
+
+// Undrain 恢复被手动摘除的实例
+func (h *AdminHandler) Undrain(c *gin.Context) {
+	h.setDrained(c, false)
+}
+
+func (h *AdminHandler) setDrained(c *gin.Context, drained bool) {
+	upstream, instance := c.Param("name"), c.Query("instance")
+	if instance == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "缺少 instance 参数"})
+		return
+	}
+	if !h.rt.SetDrained(upstream, instance, drained) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "上游或实例不存在"})
+		return
+	}
+	logger.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+		"upstream": upstream,
+		"instance": instance,
+		"drained":  drained,
+	}).Warn("Upstream instance drain state changed by admin")
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "操作成功", "data": gin.H{"upstream": upstream, "instance": instance, "drained": drained}})
+}
+
+// SetMaintenance 把路由设为维护模式，请求体可以带 {"message": "..."} 作为返回给客户端的提示
+func (h *AdminHandler) SetMaintenance(c *gin.Context) {
+	name := c.Param("name")
+	if !h.routeExists(name) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "路由不存在"})
+		return
+	}
+	var req struct {
+		Message string `json:"message"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "请求体格式错误"})
+			return
+		}
+	}
+	if req.Message == "" {
+		req.Message = defaultMaintenanceMessage
+	}
+	h.rt.SetMaintenance(name, req.Message)
+	logger.FromContext(c.Request.Context()).WithFields(logrus.Fields{"route": name, "message": req.Message}).Warn("Route put into maintenance by admin")
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "操作成功", "data": gin.H{"route": name, "maintenance": gin.H{"message": req.Message}}})
+}
+
+// ClearMaintenance 结束路由的维护模式
+func (h *AdminHandler) ClearMaintenance(c *gin.Context) {
+	name := c.Param("name")
+	if !h.rt.ClearMaintenance(name) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "路由不在维护中"})
+		return
+	}
+	logger.FromContext(c.Request.Context()).WithField("route", name).Warn("Route maintenance ended by admin")
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "操作成功", "data": gin.H{"route": name}})
+}
+
+func (h *AdminHandler) routeExists(name string) bool {
+	for _, r := range h.rt.load().table.Routes() {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Audit 按时间倒序查询审计记录，参数（都可选）：
+// user_id、resource（如 experiments）、resource_id、from/to（RFC3339，含 from 不含 to）、
+// limit（默认 100，最大 1000）、before_id（上一页最后一条的 id，用于翻页）
+func (h *AdminHandler) Audit(c *gin.Context) {
+	q := middleware.AuditQuery{
+		Resource:   c.Query("resource"),
+		ResourceID: c.Query("resource_id"),
+		Limit:      defaultAuditLimit,
+	}
+	var err error
+	if v := c.Query("user_id"); v != "" {
+		var id uint64
+		if id, err = strconv.ParseUint(v, 10, 64); err == nil {
+			q.UserID = uint(id)
+		}
+	}
+	if v := c.Query("from"); v != "" && err == nil {
+		q.From, err = time.Parse(time.RFC3339, v)
+	}
+	if v := c.Query("to"); v != "" && err == nil {
+		q.To, err = time.Parse(time.RFC3339, v)
+	}
+	if v := c.Query("before_id"); v != "" && err == nil {
+		q.BeforeID, err = strconv.ParseUint(v, 10, 64)
+	}
+	if v := c.Query("limit"); v != "" && err == nil {
+		q.Limit, err = strconv.Atoi(v)
+		q.Limit = min(q.Limit, maxAuditLimit)
+	}
+	if err != nil || q.Limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "查询参数格式错误"})
+		return
+	}
+
+	entries, err := h.audit.Query(c.Request.Context(), q)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).WithError(err).Error("Failed to query audit entries")
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "查询审计记录失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "data": entries})
+}
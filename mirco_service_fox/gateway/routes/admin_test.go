@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"gateway/config"
+	"gateway/middleware"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAdminDrainAndMaintenance(t *testing.T) {
+	rt, r := newTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "ok")
+	}), config.RouteConfig{Name: "api", Match: config.MatchPrefix, Path: "/api", Upstream: "svc"})
+	const token = "admin-token"
+	admin := NewAdminRouter(rt, middleware.NewErrorCounter(), nil, nil, token)
+	instance := url.QueryEscape(rt.load().cfg.Upstreams["svc"].URL)
+
+	// 每一步先调用管理接口，再请求 /api/x 看转发结果
+	steps := []struct {
+		name        string
+		method      string
+		target      string
+		body        string
+		token       string
+		adminStatus int
+		status      int
+		want        string
+	}{
+		{"without token", http.MethodPut, "/admin/routes/api/maintenance", "", "", http.StatusForbidden, http.StatusOK, "ok"},
+		{"unknown route", http.MethodPut, "/admin/routes/other/maintenance", "", token, http.StatusNotFound, http.StatusOK, "ok"},
+		{"maintenance", http.MethodPut, "/admin/routes/api/maintenance", `{"message":"升级中"}`, token, http.StatusOK, http.StatusServiceUnavailable, `{"code":503,"message":"升级中"}`},
+		{"end maintenance", http.MethodDelete, "/admin/routes/api/maintenance", "", token, http.StatusOK, http.StatusOK, "ok"},
+		{"default maintenance message", http.MethodPut, "/admin/routes/api/maintenance", "", token, http.StatusOK, http.StatusServiceUnavailable, `{"code":503,"message":"` + defaultMaintenanceMessage + `"}`},
+		{"end maintenance again", http.MethodDelete, "/admin/routes/api/maintenance", "", token, http.StatusOK, http.StatusOK, "ok"},
+		{"drain unknown instance", http.MethodPost, "/admin/upstreams/svc/drain?instance=http%3A%2F%2Fother", "", token, http.StatusNotFound, http.StatusOK, "ok"},
+		{"drain the only instance", http.MethodPost, "/admin/upstreams/svc/drain?instance=" + instance, "", token, http.StatusOK, http.StatusServiceUnavailable,
+			`{"error":"service unavailable","message":"No healthy upstream instance is available, please retry later","upstream":"svc"}`},
+		{"undrain", http.MethodDelete, "/admin/upstreams/svc/drain?instance=" + instance, "", token, http.StatusOK, http.StatusOK, "ok"},
+	}
+	for _, s := range steps {
+		req := httptest.NewRequest(s.method, s.target, strings.NewReader(s.body))
+		if s.token != "" {
+			req.Header.Set(AdminTokenHeader, s.token)
+		}
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, req)
+		if rec.Code != s.adminStatus {
+			t.Errorf("%s: %s %s = %d %s, want %d", s.name, s.method, s.target, rec.Code, rec.Body, s.adminStatus)
+		}
+
+		proxied := newRecorder()
+		r.ServeHTTP(proxied, httptest.NewRequest(http.MethodGet, "/api/x", nil))
+		if proxied.Code != s.status || proxied.Body.String() != s.want {
+			t.Errorf("%s: GET /api/x = %d %s, want %d %s", s.name, proxied.Code, proxied.Body, s.status, s.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"gateway/config"
+	"gateway/middleware"
+	"net/http"
+	"shared/identity"
+	"shared/logger"
+	"shared/tracing"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AuthHandler 注销相关接口
+type AuthHandler struct {
+	revocations  middleware.RevocationStore
+	upstreams    *config.Reloader // user-service 的地址跟随配置热加载
+	serviceToken string
+	client       *http.Client
+}
+
+func NewAuthHandler(cfg *config.Reloader, revocations middleware.RevocationStore) *AuthHandler {
+	current := cfg.Current()
+	return &AuthHandler{
+		revocations:  revocations,
+		upstreams:    cfg,
+		serviceToken: current.ServiceToken,
+		client:       &http.Client{Timeout: 5 * time.Second, Transport: tracing.Transport(http.DefaultTransport)},
+	}
+}
+
+// Register 注册注销接口，注销访问令牌后交给 forward 转发给 user-service 作废刷新令牌。
+// 注销指定用户全部令牌的 RevokeUserTokens 只在管理接口上注册，见 NewAdminRouter
+func (h *AuthHandler) Register(r *gin.Engine, forward gin.HandlerFunc) {
+	r.POST(middleware.LogoutPath, h.Logout, forward)
+}
+
+// Logout 注销当前令牌。没有 jti 的旧令牌无法单独注销，改为注销该用户此前签发的全部令牌，
+// 记录按这个令牌的有效期保留
+func (h *AuthHandler) Logout(c *gin.Context) {
+	value, _ := c.Get("claims")
+	claims, ok := value.(*middleware.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "权限不足"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var err error
+	if claims.Id != "" {
+		err = h.revocations.RevokeToken(ctx, claims.Id, time.Unix(claims.ExpiresAt, 0))
+	} else {
+		now := time.Now()
+		ttl := time.Duration(claims.ExpiresAt-claims.IssuedAt) * time.Second
+		err = h.revocations.RevokeUser(ctx, claims.UserID, now, now.Add(ttl))
+	}
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Failed to revoke token")
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "退出登录失败"})
+		return
+	}
+	logger.FromContext(ctx).WithFields(logrus.Fields{"user_id": claims.UserID, "jti": claims.Id}).Info("User logged out")
+	c.Next()
+}
+
+// RevokeUserTokens 先通知 user-service 作废指定用户的刷新令牌，再按它返回的时间点和访问令牌有效期
+// 注销此前签发的全部访问令牌，用户需要重新登录
+func (h *AuthHandler) RevokeUserTokens(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "用户ID不合法"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	revoked, err := h.revokeRefreshTokens(c, uint(id))
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Failed to revoke user refresh tokens")
+		c.JSON(http.StatusBadGateway, gin.H{"code": 502, "message": "注销失败，user-service 暂不可用"})
+		return
+	}
+	before := time.UnixMilli(revoked.RevokedBeforeMs)
+	expiresAt := before.Add(time.Duration(revoked.AccessTokenTTL) * time.Second)
+	if err := h.revocations.RevokeUser(ctx, uint(id), before, expiresAt); err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Failed to revoke user tokens")
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "刷新令牌已作废，但注销访问令牌失败"})
+		return
+	}
+	logger.FromContext(ctx).WithField("user_id", id).Warn("Revoked all tokens of user")
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "已注销该用户的全部令牌",
+		"data":    gin.H{"user_id": id, "revoked_before": before.Unix()},
+	})
+}
+
+// userRevocation user-service 作废刷新令牌后返回的注销时间点和访问令牌有效期
+type userRevocation struct {
+	RevokedBeforeMs int64 `json:"revoked_before_ms"`
+	AccessTokenTTL  int64 `json:"access_token_ttl_s"`
+}
+
+// revokeRefreshTokens 通知 user-service 作废用户的全部刷新令牌
+func (h *AuthHandler) revokeRefreshTokens(c *gin.Context, userID uint) (*userRevocation, error) {
+	userServiceURL := strings.TrimSuffix(h.upstreams.Current().Upstreams["user"].Targets()[0], "/")
+	url := fmt.Sprintf("%s/internal/users/%d/revoke-tokens", userServiceURL, userID)
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(logger.RequestIDHeader, logger.RequestID(c.Request.Context()))
+	req.Header.Set(identity.ServiceNameHeader, identity.GatewayName)
+	req.Header.Set(identity.ServiceTokenHeader, h.serviceToken)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user-service returned %d", resp.StatusCode)
+	}
+	var body struct {
+		Data userRevocation `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode user-service response: %w", err)
+	}
+	if body.Data.RevokedBeforeMs <= 0 || body.Data.AccessTokenTTL <= 0 {
+		return nil, fmt.Errorf("user-service response has no revocation time or token ttl")
+	}
+	return &body.Data, nil
+}
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"gateway/config"
+	"hash/fnv"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pickUpstream 返回请求转发的上游，配置了金丝雀时按 CanaryConfig 的规则选择
+func (r *Route) pickUpstream(c *gin.Context) (upstream string, canary bool) {
+	cn := r.Canary
+	if cn == nil {
+		return r.Upstream, false
+	}
+	for _, v := range []string{canaryHeader(c, cn.Header), canaryCookie(c, cn.Cookie)} {
+		switch v {
+		case config.CanaryAlways:
+			return cn.Upstream, true
+		case config.CanaryNever:
+			return r.Upstream, false
+		}
+	}
+	userID := c.GetString("userID")
+	if userID != "" && slices.Contains(cn.UserIDs, userID) {
+		return cn.Upstream, true
+	}
+	key := userID
+	if key == "" {
+		key = "ip:" + c.ClientIP()
+	}
+	if bucket(r.Name+"/"+key) < cn.Weight {
+		return cn.Upstream, true
+	}
+	return r.Upstream, false
+}
+
+func canaryHeader(c *gin.Context, name string) string {
+	if name == "" {
+		return ""
+	}
+	return c.GetHeader(name)
+}
+
+func canaryCookie(c *gin.Context, name string) string {
+	if name == "" {
+		return ""
+	}
+	v, _ := c.Cookie(name)
+	return v
+}
+
+// bucket 把 key 稳定地映射到 0-99，加上路由名称，不同路由的金丝雀用户互不相同
+func bucket(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"fmt"
+	"gateway/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCanaryRoute(name string, weight int) *Route {
+	return &Route{RouteConfig: config.RouteConfig{
+		Name:     name,
+		Upstream: "experiment",
+		Canary: &config.CanaryConfig{
+			Upstream: "experiment-canary",
+			Weight:   weight,
+			Header:   "X-Canary",
+			Cookie:   "canary",
+			UserIDs:  []string{"7"},
+		},
+	}}
+}
+
+// canaryContext 构造请求上下文，userID 为空表示未登录
+func canaryContext(userID, remoteAddr string, headers map[string]string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/student/experiments", nil)
+	if remoteAddr != "" {
+		c.Request.RemoteAddr = remoteAddr
+	}
+	for k, v := range headers {
+		c.Request.Header.Set(k, v)
+	}
+	if userID != "" {
+		c.Set("userID", userID)
+	}
+	return c
+}
+
+func TestPickUpstreamOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		weight  int
+		userID  string
+		headers map[string]string
+		canary  bool
+	}{
+		{"weight 0", 0, "1", nil, false},
+		{"weight 100", 100, "1", nil, true},
+		{"header always", 0, "1", map[string]string{"X-Canary": "always"}, true},
+		{"header never", 100, "1", map[string]string{"X-Canary": "never"}, false},
+		{"cookie always", 0, "1", map[string]string{"Cookie": "canary=always"}, true},
+		{"header before cookie", 0, "1", map[string]string{"X-Canary": "never", "Cookie": "canary=always"}, false},
+		{"other header value ignored", 0, "1", map[string]string{"X-Canary": "yes"}, false},
+		{"listed user", 0, "7", nil, true},
+		{"header never overrides listed user", 0, "7", map[string]string{"X-Canary": "never"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream, canary := newCanaryRoute("experiments", tt.weight).pickUpstream(canaryContext(tt.userID, "", tt.headers))
+			want := "experiment"
+			if tt.canary {
+				want = "experiment-canary"
+			}
+			if upstream != want || canary != tt.canary {
+				t.Errorf("pickUpstream() = %s, %v, want %s, %v", upstream, canary, want, tt.canary)
+			}
+		})
+	}
+
+	noCanary := &Route{RouteConfig: config.RouteConfig{Name: "experiments", Upstream: "experiment"}}
+	if upstream, canary := noCanary.pickUpstream(canaryContext("1", "", map[string]string{"X-Canary": "always"})); upstream != "experiment" || canary {
+		t.Errorf("pickUpstream() without canary = %s, %v", upstream, canary)
+	}
+}
+
+func TestPickUpstreamBucketing(t *testing.T) {
+	const users = 2000
+	tests := []struct {
+		name string
+		// ctx 第 i 个用户的请求
+		ctx func(i int) *gin.Context
+	}{
+		{"by user id", func(i int) *gin.Context { return canaryContext(fmt.Sprint(i+100), "", nil) }},
+		{"anonymous by client ip", func(i int) *gin.Context {
+			// 同一 IP 的不同端口是同一个客户端
+			return canaryContext("", fmt.Sprintf("10.1.%d.%d:%d", i/250, i%250, 40000+i%7), nil)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := newCanaryRoute("experiments", 20)
+			wider := newCanaryRoute("experiments", 50)
+			other := newCanaryRoute("submissions", 20)
+			inCanary, sameOnOther := 0, 0
+			for i := range users {
+				_, canary := route.pickUpstream(tt.ctx(i))
+				if _, again := route.pickUpstream(tt.ctx(i)); again != canary {
+					t.Fatalf("user %d switched versions between requests", i)
+				}
+				// 调大比例时已经在金丝雀的用户不会回到原版本
+				if _, widened := wider.pickUpstream(tt.ctx(i)); canary && !widened {
+					t.Fatalf("user %d left the canary when the weight was raised", i)
+				}
+				if _, onOther := other.pickUpstream(tt.ctx(i)); canary {
+					inCanary++
+					if onOther {
+						sameOnOther++
+					}
+				}
+			}
+			if inCanary < users*15/100 || inCanary > users*25/100 {
+				t.Errorf("%d of %d users in a 20%% canary", inCanary, users)
+			}
+			// 不同路由的分桶相互独立，两个路由都在金丝雀的用户约为 20%
+			if sameOnOther > inCanary/2 {
+				t.Errorf("%d of %d canary users are also in the other route's canary", sameOnOther, inCanary)
+			}
+		})
+	}
+}
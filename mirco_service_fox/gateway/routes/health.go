@@ -0,0 +1,195 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"gateway/config"
+	"gateway/proxy"
+	"io"
+	"net/http"
+	"shared/server"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 依赖检查结果状态
+const (
+	statusUp       = "up"
+	statusDegraded = "degraded" // 服务本身正常，但它依赖的服务（如判题服务）异常
+	statusDown     = "down"
+)
+
+// DependencyReport 单个上游的检查结果
+type DependencyReport struct {
+	Status     string                 `json:"status"`
+	URL        string                 `json:"url"`
+	HTTPStatus int                    `json:"http_status,omitempty"`
+	LatencyMs  int64                  `json:"latency_ms"`
+	Error      string                 `json:"error,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`   // 上游 /health 返回的内容
+	Instances  []DependencyReport     `json:"instances,omitempty"` // 上游有多个实例时每个实例的结果
+}
+
+// ReadinessChecker 并发检查所有上游每个实例的 /health
+type ReadinessChecker struct {
+	upstreams map[string]config.UpstreamConfig
+	pool      *proxy.Pool
+	client    *http.Client
+}
+
+func NewReadinessChecker(cfg *config.ServiceConfig, pool *proxy.Pool) *ReadinessChecker {
+	timeout := cfg.HealthCheck.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &ReadinessChecker{
+		upstreams: cfg.Upstreams,
+		pool:      pool,
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+// Check 返回每个上游的检查结果和总体状态：
+// 全部正常为 ok，部分异常为 degraded，全部不可用为 down
+func (rc *ReadinessChecker) Check(ctx context.Context) (string, map[string]DependencyReport) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		reports = make(map[string]DependencyReport, len(rc.upstreams))
+	)
+	for name, up := range rc.upstreams {
+		wg.Add(1)
+		go func(name string, up config.UpstreamConfig) {
+			defer wg.Done()
+			report := rc.probeUpstream(ctx, name, up)
+			mu.Lock()
+			reports[name] = report
+			mu.Unlock()
+		}(name, up)
+	}
+	wg.Wait()
+
+	down := 0
+	degraded := 0
+	for _, r := range reports {
+		switch r.Status {
+		case statusDown:
+			down++
+		case statusDegraded:
+			degraded++
+		}
+	}
+	switch {
+	case down == len(reports) && down > 0:
+		return "down", reports
+	case down > 0 || degraded > 0:
+		return "degraded", reports
+	default:
+		return "ok", reports
+	}
+}
+
+// probeUpstream 检查上游的所有实例：全部正常为 up，部分异常为 degraded，全部不可用为 down。
+// 只有一个实例时直接返回该实例的结果
+func (rc *ReadinessChecker) probeUpstream(ctx context.Context, name string, up config.UpstreamConfig) DependencyReport {
+	var urls []string
+	if instances, ok := rc.pool.Instances(name); ok {
+		for _, inst := range instances {
+			urls = append(urls, inst.URL)
+		}
+	} else {
+		urls = up.Targets()
+	}
+	reports := make([]DependencyReport, len(urls))
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			reports[i] = rc.probe(ctx, strings.TrimSuffix(u, "/")+up.HealthPath)
+		}(i, u)
+	}
+	wg.Wait()
+	if len(reports) == 1 {
+		return reports[0]
+	}
+
+	// 汇总结果沿用第一个正常实例的详情
+	report := reports[0]
+	down := 0
+	for _, r := range reports {
+		if r.Status == statusDown {
+			down++
+		} else if report.Status == statusDown {
+			report = r
+		}
+	}
+	switch {
+	case down == len(reports):
+		report.Status = statusDown
+	case down > 0:
+		report.Status = statusDegraded
+	}
+	report.URL = up.URL
+	report.Instances = reports
+	return report
+}
+
+func (rc *ReadinessChecker) probe(ctx context.Context, url string) DependencyReport {
+	report := DependencyReport{Status: statusDown, URL: url}
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	resp, err := rc.client.Do(req)
+	report.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	defer resp.Body.Close()
+	report.HTTPStatus = resp.StatusCode
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = json.Unmarshal(body, &report.Details)
+	if resp.StatusCode != http.StatusOK {
+		report.Error = http.StatusText(resp.StatusCode)
+		return report
+	}
+	report.Status = statusUp
+	// 上游会在 status 中报告自身依赖的情况，例如提交服务报告判题服务
+	if s, _ := report.Details["status"].(string); s == statusDegraded {
+		report.Status = statusDegraded
+	}
+	return report
+}
+
+// LiveHandler 存活探针，只表示网关进程在运行
+func LiveHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+// ReadyHandler 就绪探针，返回每个上游的检查结果。
+// 只有所有上游都不可用时返回 503，避免单个服务故障导致网关整体下线；
+// 网关正在退出时直接返回 503
+func (rc *ReadinessChecker) ReadyHandler(c *gin.Context) {
+	if server.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining", "checked_at": time.Now().Format(time.RFC3339)})
+		return
+	}
+	status, reports := rc.Check(c.Request.Context())
+	code := http.StatusOK
+	if status == "down" {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"status":       status,
+		"checked_at":   time.Now().Format(time.RFC3339),
+		"dependencies": reports,
+	})
+}
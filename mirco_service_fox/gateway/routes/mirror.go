@@ -0,0 +1,283 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gateway/config"
+	"gateway/metrics"
+	"gateway/proxy"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"shared/logger"
+	"slices"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// MirrorHeader 影子请求带这个请求头，客户端自带的会被删除
+const MirrorHeader = "X-Gateway-Mirror"
+
+// 一次比较最多记录的不同字段数
+const maxMirrorDiffs = 10
+
+// startMirror 按路由的 mirror 配置复制请求，影子请求与正式请求同时发出，不受客户端断开和路由超时影响。
+// 返回的函数在正式响应写完后调用，在后台等影子响应并比较，不阻塞客户端；没有复制时返回 nil。
+// 只复制 mirror.methods 中的方法（默认 GET、HEAD）；长连接和请求体超过 mirror.max_body_size 的请求不复制
+func startMirror(c *gin.Context, route *Route, pool *proxy.Pool) func() {
+	c.Request.Header.Del(MirrorHeader)
+	m := route.Mirror
+	if m == nil || !slices.Contains(m.Methods, c.Request.Method) || rand.IntN(100) >= m.Percent {
+		return nil
+	}
+	if _, ok := proxy.StreamKind(c.Request); ok {
+		return nil
+	}
+	rp, ok := pool.Get(m.Upstream)
+	if !ok {
+		return nil
+	}
+	body, ok := peekBody(c.Request, int64(m.MaxBodySize))
+	if !ok {
+		metrics.MirrorRequests.WithLabelValues(route.Name, "skipped").Inc()
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), m.Timeout)
+	req := c.Request.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set(MirrorHeader, "1")
+	shadow := &captureWriter{header: make(http.Header), limit: int(m.MaxBodySize)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel()
+		rp.ServeHTTP(shadow, req)
+	}()
+
+	var primary *teeWriter
+	if m.Compare {
+		primary = &teeWriter{ResponseWriter: c.Writer, limit: int(m.MaxBodySize)}
+		c.Writer = primary
+	}
+	log := logger.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+		"route": route.Name, "upstream": c.GetString("upstream"), "mirror_upstream": m.Upstream,
+	})
+	return func() {
+		if primary == nil {
+			metrics.MirrorRequests.WithLabelValues(route.Name, "sent").Inc()
+			return
+		}
+		c.Writer = primary.ResponseWriter
+		go func() {
+			<-done
+			diffs := compareMirror(m, primary, shadow)
+			if len(diffs) == 0 {
+				metrics.MirrorRequests.WithLabelValues(route.Name, "match").Inc()
+				return
+			}
+			metrics.MirrorRequests.WithLabelValues(route.Name, "mismatch").Inc()
+			log.WithFields(logrus.Fields{
+				"status": primary.status, "mirror_status": shadow.status, "diffs": diffs,
+			}).Warn("Mirror response differs")
+		}()
+	}
+}
+
+// peekBody 读取请求体，读过的部分放回请求中继续转发；请求体超过 limit 或读取出错时 ok 为 false
+func peekBody(r *http.Request, limit int64) (body []byte, ok bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	if r.ContentLength > limit {
+		return nil, false
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil || int64(len(buf)) > limit {
+		return nil, false
+	}
+	return buf, true
+}
+
+// compareMirror 比较正式响应和影子响应，返回不同之处。
+// 响应体超过缓存大小时只比较状态码；两边都是未压缩的 JSON 时按字段比较并忽略 ignore_fields，否则按字节比较
+func compareMirror(m *config.MirrorConfig, primary *teeWriter, shadow *captureWriter) []string {
+	if primary.status != shadow.status {
+		return []string{"status"}
+	}
+	if primary.truncated || shadow.truncated {
+		return nil
+	}
+	if primary.plainJSON && isPlainJSON(shadow.header) {
+		a, errA := decodeJSON(primary.buf.Bytes())
+		b, errB := decodeJSON(shadow.buf.Bytes())
+		if errA == nil && errB == nil {
+			ignore := make(map[string]bool, len(m.IgnoreFields))
+			for _, f := range m.IgnoreFields {
+				ignore[f] = true
+			}
+			var diffs []string
+			jsonDiff("$", a, b, ignore, &diffs)
+			return diffs
+		}
+	}
+	if !bytes.Equal(primary.buf.Bytes(), shadow.buf.Bytes()) {
+		return []string{"body"}
+	}
+	return nil
+}
+
+func isPlainJSON(h http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType == "application/json" && h.Get("Content-Encoding") == ""
+}
+
+func decodeJSON(b []byte) (any, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// jsonDiff 记录两个 JSON 值中不同的字段路径，如 $.data.score
+func jsonDiff(path string, a, b any, ignore map[string]bool, out *[]string) {
+	if len(*out) >= maxMirrorDiffs {
+		return
+	}
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			*out = append(*out, path)
+			return
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ignore[k] {
+				continue
+			}
+			va, okA := av[k]
+			vb, okB := bv[k]
+			if okA != okB {
+				if len(*out) < maxMirrorDiffs {
+					*out = append(*out, path+"."+k)
+				}
+				continue
+			}
+			jsonDiff(path+"."+k, va, vb, ignore, out)
+		}
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			*out = append(*out, path)
+			return
+		}
+		for i := range av {
+			jsonDiff(fmt.Sprintf("%s[%d]", path, i), av[i], bv[i], ignore, out)
+		}
+	default:
+		// 其余为 string、json.Number、bool 或 nil，可以直接比较
+		if a != b {
+			*out = append(*out, path)
+		}
+	}
+}
+
+// teeWriter 照常写出正式响应，同时缓存最多 limit 字节用于比较。
+// 状态码和响应类型在写响应头时记下，之后的中间件（如压缩）修改响应头不影响比较
+type teeWriter struct {
+	gin.ResponseWriter
+	limit     int
+	status    int
+	plainJSON bool
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (w *teeWriter) WriteHeader(code int) {
+	w.record(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// record 记下第一次写响应头时的状态码和响应类型，没有调用 WriteHeader 直接写响应体时为 200
+func (w *teeWriter) record(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.plainJSON = isPlainJSON(w.Header())
+	}
+}
+
+func (w *teeWriter) Write(b []byte) (int, error) {
+	w.record(http.StatusOK)
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.record(http.StatusOK)
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *teeWriter) capture(b []byte) {
+	if w.truncated {
+		return
+	}
+	if w.buf.Len()+len(b) > w.limit {
+		w.truncated = true
+		w.buf.Reset()
+		return
+	}
+	w.buf.Write(b)
+}
+
+// captureWriter 接收影子响应，只保留状态码、响应头和最多 limit 字节的响应体
+type captureWriter struct {
+	header    http.Header
+	status    int
+	limit     int
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (w *captureWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if !w.truncated && w.buf.Len()+len(b) > w.limit {
+		w.truncated = true
+		w.buf.Reset()
+	}
+	if !w.truncated {
+		w.buf.Write(b)
+	}
+	return len(b), nil
+}
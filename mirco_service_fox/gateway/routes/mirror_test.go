@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"fmt"
+	"gateway/config"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestJSONDiff(t *testing.T) {
+	many := func(prefix string) string {
+		fields := make([]string, 15)
+		for i := range fields {
+			fields[i] = fmt.Sprintf(`"f%02d":"%s%d"`, i, prefix, i)
+		}
+		return "{" + strings.Join(fields, ",") + "}"
+	}
+	tests := []struct {
+		name   string
+		a, b   string
+		ignore []string
+		want   []string
+	}{
+		{name: "equal", a: `{"data":{"id":1,"tags":["a","b"]}}`, b: `{"data":{"tags":["a","b"],"id":1}}`},
+		{name: "changed value", a: `{"data":{"score":90,"name":"a"}}`, b: `{"data":{"score":85,"name":"a"}}`, want: []string{"$.data.score"}},
+		{name: "missing fields on either side", a: `{"a":1,"c":null}`, b: `{"b":1,"c":null}`, want: []string{"$.a", "$.b"}},
+		{name: "null and missing differ", a: `{"a":null}`, b: `{}`, want: []string{"$.a"}},
+		{name: "ignored at any level", a: `{"id":1,"data":[{"id":2,"created_at":"x","v":1}]}`, b: `{"id":3,"data":[{"id":4,"created_at":"y","v":1}]}`, ignore: []string{"id", "created_at"}},
+		{name: "array element", a: `{"items":[1,2,3]}`, b: `{"items":[1,5,3]}`, want: []string{"$.items[1]"}},
+		{name: "array length", a: `{"items":[1,2]}`, b: `{"items":[1,2,3]}`, want: []string{"$.items"}},
+		{name: "type change", a: `{"data":{"a":1}}`, b: `{"data":[1]}`, want: []string{"$.data"}},
+		{name: "number type", a: `{"n":1}`, b: `{"n":"1"}`, want: []string{"$.n"}},
+		{name: "capped", a: many("a"), b: many("b"), want: []string{"$.f00", "$.f01", "$.f02", "$.f03", "$.f04", "$.f05", "$.f06", "$.f07", "$.f08", "$.f09"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, errA := decodeJSON([]byte(tt.a))
+			b, errB := decodeJSON([]byte(tt.b))
+			if errA != nil || errB != nil {
+				t.Fatal(errA, errB)
+			}
+			ignore := make(map[string]bool)
+			for _, f := range tt.ignore {
+				ignore[f] = true
+			}
+			var got []string
+			jsonDiff("$", a, b, ignore, &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jsonDiff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareMirror(t *testing.T) {
+	m := &config.MirrorConfig{MaxBodySize: 64, IgnoreFields: []string{"request_id"}}
+	jsonHeader := http.Header{"Content-Type": {"application/json; charset=utf-8"}}
+	tests := []struct {
+		name          string
+		primaryStatus int
+		primaryJSON   bool
+		primaryBody   string
+		shadowStatus  int
+		shadowHeader  http.Header
+		shadowBody    string
+		want          []string
+	}{
+		{"same json", 200, true, `{"a":1,"request_id":"x"}`, 200, jsonHeader, `{"request_id":"y","a":1}`, nil},
+		{"json field differs", 200, true, `{"a":1}`, 200, jsonHeader, `{"a":2}`, []string{"$.a"}},
+		{"status differs", 200, true, `{"a":1}`, 500, jsonHeader, `{"a":1}`, []string{"status"}},
+		{"primary too large", 200, true, strings.Repeat("a", 65), 200, jsonHeader, `{}`, nil},
+		{"shadow too large", 200, true, `{}`, 200, jsonHeader, strings.Repeat("a", 65), nil},
+		{"shadow compressed", 200, true, `{"a":1}`, 200, http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}}, `{"a": 1}`, []string{"body"}},
+		{"text compared by bytes", 200, false, "ok", 200, http.Header{"Content-Type": {"text/plain"}}, "ok", nil},
+		{"text differs", 200, false, "ok", 200, http.Header{"Content-Type": {"text/plain"}}, "ok\n", []string{"body"}},
+		{"invalid json compared by bytes", 200, true, `{"a":`, 200, jsonHeader, `{"a":`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			if tt.primaryJSON {
+				c.Header("Content-Type", "application/json")
+			}
+			primary := &teeWriter{ResponseWriter: c.Writer, limit: int(m.MaxBodySize)}
+			primary.WriteHeader(tt.primaryStatus)
+			primary.WriteString(tt.primaryBody)
+			shadow := &captureWriter{header: tt.shadowHeader, limit: int(m.MaxBodySize)}
+			shadow.WriteHeader(tt.shadowStatus)
+			shadow.Write([]byte(tt.shadowBody))
+
+			if got := compareMirror(m, primary, shadow); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareMirror() = %v, want %v", got, tt.want)
+			}
+			// 正式响应照常写给客户端
+			if rec.Body.String() != tt.primaryBody {
+				t.Errorf("client got %q, want %q", rec.Body, tt.primaryBody)
+			}
+		})
+	}
+}
+
+func TestStartMirrorSkipsUnlistedMethods(t *testing.T) {
+	route := &Route{RouteConfig: config.RouteConfig{Name: "experiments", Mirror: &config.MirrorConfig{
+		Upstream: "experiment-shadow", Percent: 100, Methods: []string{http.MethodGet, http.MethodHead},
+	}}}
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(method, "/api/student/experiments", nil)
+		c.Request.Header.Set(MirrorHeader, "1")
+		// 不复制时不会用到连接池
+		if done := startMirror(c, route, nil); done != nil {
+			t.Errorf("%s request mirrored", method)
+		}
+		if c.Request.Header.Get(MirrorHeader) != "" {
+			t.Errorf("%s request kept the client's %s header", method, MirrorHeader)
+		}
+	}
+}
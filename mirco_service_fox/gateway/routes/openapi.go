@@ -0,0 +1,196 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gateway/config"
+	"gateway/middleware"
+	"gateway/openapi"
+	"io"
+	"net/http"
+	"regexp"
+	"shared/logger"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDocSize 单个上游文档的大小上限
+const maxDocSize = 4 << 20
+
+// APIDocs 合并各上游发布的 OpenAPI 文档。合并结果缓存 openapi.cache_ttl，路由表重新加载后重新合并；
+// 取不到某个上游的文档时沿用上次取到的内容，从没取到过的上游列在 x-unavailable-services 中
+type APIDocs struct {
+	rt     *Runtime
+	client *http.Client
+
+	mu        sync.Mutex
+	fetched   map[string][]byte // 上游名称 -> 最近一次取到的文档
+	merged    *openapi.Document
+	validator *openapi.Validator // 按 merged 校验请求
+	mergedOf  *runtimeState      // 合并时使用的路由表
+	mergedAt  time.Time
+
+	refreshing atomic.Bool
+}
+
+func NewAPIDocs(rt *Runtime) *APIDocs {
+	return &APIDocs{rt: rt, client: &http.Client{}, fetched: make(map[string][]byte)}
+}
+
+// Document 返回合并后的文档，调用方不能修改
+func (d *APIDocs) Document(ctx context.Context) *openapi.Document {
+	state := d.rt.load()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.merged != nil && d.mergedOf == state && time.Since(d.mergedAt) < state.cfg.OpenAPI.CacheTTL {
+		return d.merged
+	}
+	d.fetchAll(ctx, state.cfg)
+	d.merged, d.mergedOf, d.mergedAt = d.merge(state), state, time.Now()
+	d.validator = openapi.NewValidator(d.merged)
+	return d.merged
+}
+
+// Validator 返回按合并后的文档校验请求的校验器。只有第一次调用时等待取文档，
+// 之后缓存过期或路由表重新加载时先返回上次的校验器，在后台重新合并，不阻塞请求
+func (d *APIDocs) Validator(ctx context.Context) *openapi.Validator {
+	state := d.rt.load()
+	d.mu.Lock()
+	v := d.validator
+	fresh := d.mergedOf == state && time.Since(d.mergedAt) < state.cfg.OpenAPI.CacheTTL
+	d.mu.Unlock()
+	if v == nil {
+		d.Document(ctx)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.validator
+	}
+	if !fresh && d.refreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer d.refreshing.Store(false)
+			d.Document(context.Background())
+		}()
+	}
+	return v
+}
+
+// fetchAll 并发取所有上游的文档，失败的保留上次的结果
+func (d *APIDocs) fetchAll(ctx context.Context, cfg *config.ServiceConfig) {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, up := range cfg.Upstreams {
+		wg.Add(1)
+		go func(name string, up config.UpstreamConfig) {
+			defer wg.Done()
+			body, err := d.fetch(ctx, strings.TrimSuffix(up.Targets()[0], "/")+"/openapi.json", cfg.OpenAPI.Timeout)
+			if err != nil {
+				logger.FromContext(ctx).WithError(err).WithField("upstream", name).Warn("Failed to fetch OpenAPI document")
+				return
+			}
+			mu.Lock()
+			d.fetched[name] = body
+			mu.Unlock()
+		}(name, up)
+	}
+	wg.Wait()
+}
+
+func (d *APIDocs) fetch(ctx context.Context, url string, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocSize))
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("invalid json")
+	}
+	return body, nil
+}
+
+var pathParam = regexp.MustCompile(`\{[^/}]+\}`)
+
+// merge 只保留路由表把请求原样转发到该上游的接口：路径参数用 "1" 代入后匹配路由，
+// 匹配到其它上游、会被重写路径或属于 /internal 的接口不对外暴露，不写入文档
+func (d *APIDocs) merge(state *runtimeState) *openapi.Document {
+	doc := openapi.New("SmartFox API", "1.0.0")
+	names := make([]string, 0, len(state.cfg.Upstreams))
+	for name := range state.cfg.Upstreams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		body, ok := d.fetched[name]
+		if !ok {
+			doc.XUnavailable = append(doc.XUnavailable, name)
+			continue
+		}
+		// 每次合并重新解析，Merge 会修改传入的文档
+		var src openapi.Document
+		if err := json.Unmarshal(body, &src); err != nil {
+			doc.XUnavailable = append(doc.XUnavailable, name)
+			continue
+		}
+		doc.Merge(name, &src, func(path, method string, op *openapi.Operation) bool {
+			sample := pathParam.ReplaceAllString(path, "1")
+			if config.IsInternalPath(sample) {
+				return false
+			}
+			method = strings.ToUpper(method)
+			route, status := state.table.Match(method, sample)
+			if status != http.StatusOK || route.Upstream != name || route.RewritePath(sample) != sample {
+				return false
+			}
+			op.XGatewayRoute = route.Name
+			op.XRequiredRole = route.Role
+			op.XAPIKeyScope = route.RequiredScope(method)
+			op.Security = []openapi.SecurityRequirement{}
+			if !middleware.PublicPath(sample) {
+				op.Security = append(op.Security, openapi.SecurityRequirement{openapi.SecurityBearer: {}})
+				if op.XAPIKeyScope != "" {
+					op.Security = append(op.Security, openapi.SecurityRequirement{openapi.SecurityAPIKey: {op.XAPIKeyScope}})
+				}
+			}
+			return true
+		})
+	}
+	return doc
+}
+
+// JSONHandler GET /openapi.json
+func (d *APIDocs) JSONHandler(c *gin.Context) {
+	if !d.rt.load().cfg.OpenAPI.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "未找到匹配的路由"})
+		return
+	}
+	c.JSON(http.StatusOK, d.Document(c.Request.Context()))
+}
+
+// PageHandler GET /docs
+func (d *APIDocs) PageHandler(c *gin.Context) {
+	if !d.rt.load().cfg.OpenAPI.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "未找到匹配的路由"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+}
@@ -1,31 +1,144 @@
 package routes
 
 import (
+	"context"
 	"gateway/config"
+	"gateway/metrics"
+	"gateway/middleware"
 	"gateway/proxy"
-	"log"
 	"net/http"
+	"shared/logger"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func SetupRoutes(r *gin.Engine, cfg *config.ServiceConfig) {
+var roleNames = map[string]string{
+	"teacher": "教师",
+	"student": "学生",
+}
+
+// SetupRoutes 注册网关自身的端点，其余请求按 rt 中当前的路由表转发
+func SetupRoutes(r *gin.Engine, rt *Runtime, auth *AuthHandler, docs *APIDocs) {
 	// 健康检查端点
-	r.Any("/*path", func(c *gin.Context) {
-		// 特殊处理健康检查
-		if c.Request.URL.Path == "/health" {
-			c.JSON(200, gin.H{"status": "OK"})
+	r.GET("/health", LiveHandler)
+	r.GET("/health/live", LiveHandler)
+	r.GET("/health/ready", func(c *gin.Context) { rt.load().ready.ReadyHandler(c) })
+	// Prometheus 指标
+	r.GET("/metrics", metrics.Handler())
+	// 合并各服务的接口文档
+	r.GET("/openapi.json", docs.JSONHandler)
+	r.GET("/docs", docs.PageHandler)
+
+	// 其余请求按路由表转发；退出登录先在网关注销访问令牌，再转发给 user-service 作废刷新令牌
+	forward := proxyHandler(rt)
+	auth.Register(r, forward)
+	r.NoRoute(forward)
+}
+
+// proxyHandler 按路由表匹配请求并转发到对应上游。
+// 每个请求开始时取一次当前的路由表和连接池，处理过程中配置重新加载也不受影响
+func proxyHandler(rt *Runtime) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logger.FromContext(c.Request.Context())
+		state := rt.load()
+		table, pool := state.table, state.pool
+		// 服务间调用的 /internal 接口一律不对外暴露
+		if config.IsInternalPath(c.Request.URL.Path) {
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "未找到匹配的路由"})
+			return
+		}
+		route, status := table.Match(c.Request.Method, c.Request.URL.Path)
+		switch status {
+		case http.StatusNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "未找到匹配的路由"})
+			return
+		case http.StatusMethodNotAllowed:
+			c.Header("Allow", strings.Join(table.AllowedMethods(c.Request.URL.Path), ", "))
+			c.JSON(http.StatusMethodNotAllowed, gin.H{"code": 405, "message": "请求方法不被允许"})
+			return
+		}
+
+		// 配置了金丝雀的路由可能转发到另一个上游，指标和错误数按实际的上游统计
+		upstream, canary := route.pickUpstream(c)
+		c.Set("route", route.Name)
+		c.Set("upstream", upstream)
+		span := trace.SpanFromContext(c.Request.Context())
+		span.SetName(c.Request.Method + " " + route.Name)
+		span.SetAttributes(attribute.String("gateway.route", route.Name), attribute.String("gateway.upstream", upstream))
+		if route.Canary != nil {
+			span.SetAttributes(attribute.Bool("gateway.canary", canary))
+		}
+
+		// 管理接口设置了维护模式的路由直接返回 503
+		if message, ok := rt.Maintenance(route.Name); ok {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"code": 503, "message": message})
+			return
+		}
+
+		// 检查路由要求的角色
+		if route.Role != "" && c.GetString("userRole") != route.Role {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "需要" + roleNames[route.Role] + "权限"})
 			return
 		}
-		log.Printf("Received request: %s %s", c.Request.Method, c.Request.URL.Path)
-		target := proxy.GetTargetService(c.Request.URL.Path, cfg)
-		log.Printf("Routing to: %s", target)
-		proxy, err := proxy.NewReverseProxy(target)
-		if err != nil {
-			log.Printf("Failed to create reverse proxy: %v", err)
+		// API Key 只能访问路由声明了权限范围、且 Key 拥有该范围的接口
+		if key, ok := middleware.APIKeyFromContext(c); ok {
+			scope := route.RequiredScope(c.Request.Method)
+			if scope == "" {
+				c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "该接口不支持 API Key 访问"})
+				return
+			}
+			if !key.HasScope(scope) {
+				c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "API Key 缺少权限范围 " + scope, "required_scope": scope})
+				return
+			}
+		}
+
+		if path := route.RewritePath(c.Request.URL.Path); path != c.Request.URL.Path {
+			// 正则重写的结果在请求时才知道，同样不能落到 /internal
+			if config.IsInternalPath(path) {
+				c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "未找到匹配的路由"})
+				return
+			}
+			c.Request.URL.Path = path
+			c.Request.URL.RawPath = ""
+		}
+		// 请求体大小：声明了 Content-Length 的直接拒绝，分块上传的在读取超过上限时中断转发
+		if limit := int64(route.MaxBodySize); limit > 0 && c.Request.Body != nil {
+			if c.Request.ContentLength > limit {
+				proxy.WriteBodyTooLarge(c.Writer, limit)
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+
+		log.WithFields(logrus.Fields{"route": route.Name, "upstream": upstream, "canary": canary}).Debug("Routing request")
+		// SSE 和 WebSocket 走长连接代理
+		if kind, ok := proxy.StreamKind(c.Request); ok {
+			span.SetAttributes(attribute.String("gateway.stream", kind))
+			if sp, ok := pool.GetStream(upstream); ok {
+				sp.ServeHTTP(c.Writer, c.Request)
+				return
+			}
+		}
+		rp, ok := pool.Get(upstream)
+		if !ok {
+			log.WithField("upstream", upstream).Error("No reverse proxy for upstream")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reverse proxy"})
 			return
 		}
-		proxy.ServeHTTP(c.Writer, c.Request)
-	})
+		if route.Timeout > 0 {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), route.Timeout)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+		}
+		finishMirror := startMirror(c, route, pool)
+		rp.ServeHTTP(c.Writer, c.Request)
+		if finishMirror != nil {
+			finishMirror()
+		}
+	}
 }
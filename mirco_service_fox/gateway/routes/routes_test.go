@@ -0,0 +1,195 @@
+package routes
+
+import (
+	"gateway/config"
+	"gateway/middleware"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"shared/logger"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestProxy 把 routes 全部指向 upstream，返回运行时和只挂了 proxyHandler 的引擎
+func newTestProxy(t *testing.T, upstream http.Handler, routes ...config.RouteConfig) (*Runtime, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	out := logger.Log.Out
+	logger.Log.SetOutput(io.Discard)
+	t.Cleanup(func() { logger.Log.SetOutput(out) })
+
+	srv := httptest.NewServer(upstream)
+	t.Cleanup(srv.Close)
+	rt, err := NewRuntime(&config.ServiceConfig{
+		Upstreams: map[string]config.UpstreamConfig{"svc": {URL: srv.URL}},
+		Routes:    routes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rt.Close() })
+	r := gin.New()
+	r.NoRoute(proxyHandler(rt))
+	return rt, r
+}
+
+// recorder 给 ResponseRecorder 补上 httputil.ReverseProxy 需要的 CloseNotify
+type recorder struct {
+	*httptest.ResponseRecorder
+}
+
+func newRecorder() recorder { return recorder{httptest.NewRecorder()} }
+
+func (recorder) CloseNotify() <-chan bool { return nil }
+
+func TestProxyHandlerHidesInternalPaths(t *testing.T) {
+	var hits atomic.Int32
+	_, r := newTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits.Add(1)
+		io.WriteString(w, req.URL.Path)
+	}),
+		config.RouteConfig{Name: "all", Match: config.MatchPrefix, Path: "/", Upstream: "svc"},
+		config.RouteConfig{Name: "legacy", Match: config.MatchRegex, Path: `^/legacy(/.*)$`, Rewrite: "$1", Upstream: "svc"},
+	)
+
+	const notFound = `{"code":404,"message":"未找到匹配的路由"}`
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/internal", http.StatusNotFound, notFound},
+		{"/internal/users/1", http.StatusNotFound, notFound},
+		// 正则重写后落到 /internal 的同样拒绝
+		{"/legacy/internal/users/1", http.StatusNotFound, notFound},
+		{"/internals", http.StatusOK, "/internals"},
+		{"/api/users/1", http.StatusOK, "/api/users/1"},
+		{"/legacy/api/users/1", http.StatusOK, "/api/users/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			before := hits.Load()
+			rec := newRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.status || rec.Body.String() != tt.body {
+				t.Errorf("GET %s = %d %s, want %d %s", tt.path, rec.Code, rec.Body, tt.status, tt.body)
+			}
+			if forwarded := hits.Load() != before; forwarded != (tt.status == http.StatusOK) {
+				t.Errorf("GET %s forwarded = %v", tt.path, forwarded)
+			}
+		})
+	}
+}
+
+func TestProxyHandlerBodyLimitAndTimeout(t *testing.T) {
+	var hits atomic.Int32
+	_, r := newTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits.Add(1)
+		if req.URL.Path == "/slow" {
+			select {
+			case <-time.After(time.Second):
+			case <-req.Context().Done():
+			}
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		w.Write(body)
+	}),
+		config.RouteConfig{Name: "upload", Match: config.MatchExact, Path: "/upload", MaxBodySize: 16, Upstream: "svc"},
+		config.RouteConfig{Name: "slow", Match: config.MatchExact, Path: "/slow", Timeout: 50 * time.Millisecond, Upstream: "svc"},
+	)
+
+	const tooLarge = `{"error":"request entity too large","limit":16,"message":"Request body exceeds the limit of 16B"}`
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		chunked bool // 不带 Content-Length，转发过程中才发现超过上限
+		status  int
+		want    string
+		early   bool // 转发之前就拒绝
+	}{
+		{"within limit", "/upload", "0123456789abcdef", false, http.StatusOK, "0123456789abcdef", false},
+		{"content length over limit", "/upload", "0123456789abcdefg", false, http.StatusRequestEntityTooLarge, tooLarge, true},
+		{"chunked within limit", "/upload", "0123456789", true, http.StatusOK, "0123456789", false},
+		{"chunked over limit", "/upload", strings.Repeat("x", 1024), true, http.StatusRequestEntityTooLarge, tooLarge, false},
+		{"route timeout", "/slow", "", false, http.StatusGatewayTimeout, `{"error":"gateway timeout","message":"Upstream service did not respond in time"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := hits.Load()
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := newRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.status || rec.Body.String() != tt.want {
+				t.Errorf("POST %s = %d %s, want %d %s", tt.path, rec.Code, rec.Body, tt.status, tt.want)
+			}
+			if tt.status != http.StatusOK && rec.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", rec.Header().Get("Content-Type"))
+			}
+			if tt.early && hits.Load() != before {
+				t.Errorf("POST %s was forwarded", tt.path)
+			}
+		})
+	}
+}
+
+func TestProxyHandlerAPIKeyScopes(t *testing.T) {
+	_, r := newTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "ok")
+	}),
+		config.RouteConfig{Name: "grades", Match: config.MatchPrefix, Path: "/api/grades", Role: "teacher", Upstream: "svc",
+			Scopes: config.RouteScopes{Read: "grades:read"}},
+		config.RouteConfig{Name: "experiments", Match: config.MatchPrefix, Path: "/api/experiments", Upstream: "svc",
+			Scopes: config.RouteScopes{Read: "experiments:read", Write: "experiments:write"}},
+		config.RouteConfig{Name: "profile", Match: config.MatchPrefix, Path: "/api/profile", Upstream: "svc"},
+	)
+	// 和 AuthMiddleware 校验 API Key 后设置的一样，X-Test-Login 为 token 时模拟令牌登录
+	r.Use(func(c *gin.Context) {
+		c.Set("userRole", "teacher")
+		if c.GetHeader("X-Test-Login") != "token" {
+			c.Set("apiKey", &middleware.APIKey{KeyID: 1, UserID: 7, Role: "teacher", Scopes: []string{"grades:read", "experiments:read"}})
+		}
+	})
+
+	const unsupported = `{"code":403,"message":"该接口不支持 API Key 访问"}`
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		login  string
+		status int
+		want   string
+	}{
+		{"read scope granted", http.MethodGet, "/api/grades/export", "", http.StatusOK, "ok"},
+		{"another read scope granted", http.MethodHead, "/api/experiments", "", http.StatusOK, ""},
+		{"write scope missing", http.MethodPost, "/api/experiments", "", http.StatusForbidden,
+			`{"code":403,"message":"API Key 缺少权限范围 experiments:write","required_scope":"experiments:write"}`},
+		{"write scope undeclared", http.MethodPut, "/api/grades/1", "", http.StatusForbidden, unsupported},
+		{"no scopes declared", http.MethodGet, "/api/profile", "", http.StatusForbidden, unsupported},
+		// 令牌登录的请求不检查权限范围
+		{"token login", http.MethodPost, "/api/experiments", "token", http.StatusOK, "ok"},
+		{"token login without scopes", http.MethodGet, "/api/profile", "token", http.StatusOK, "ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.login != "" {
+				req.Header.Set("X-Test-Login", tt.login)
+			}
+			rec := newRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.status || rec.Body.String() != tt.want {
+				t.Errorf("%s %s = %d %s, want %d %s", tt.method, tt.path, rec.Code, rec.Body, tt.status, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"gateway/config"
+	"gateway/proxy"
+	"sync"
+	"sync/atomic"
+)
+
+// Runtime 当前生效的路由表和上游连接池。配置重新加载时先用新配置完整构建一份，
+// 成功后整体替换；已经在处理的请求（包括长连接）继续使用取到的旧连接池，不会被中断
+type Runtime struct {
+	current atomic.Pointer[runtimeState]
+
+	// 管理接口设置的实例摘除和路由维护状态，配置重新加载后继续生效
+	mu          sync.RWMutex
+	drained     map[string]map[string]bool // 上游名称 -> 被摘除的实例地址
+	maintenance map[string]string          // 路由名称 -> 返回给客户端的提示
+}
+
+type runtimeState struct {
+	cfg   *config.ServiceConfig
+	table *Table
+	pool  *proxy.Pool
+	ready *ReadinessChecker
+}
+
+// NewRuntime 按启动配置构建路由表和连接池
+func NewRuntime(cfg *config.ServiceConfig) (*Runtime, error) {
+	rt := &Runtime{
+		drained:     make(map[string]map[string]bool),
+		maintenance: make(map[string]string),
+	}
+	if err := rt.Apply(cfg); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// Apply 用新配置构建路由表和连接池并替换当前的，构建失败时保持不变。
+// 旧连接池只停止健康检查、关闭空闲连接，正在使用的连接在请求结束后释放
+func (rt *Runtime) Apply(cfg *config.ServiceConfig) error {
+	table, err := NewTable(cfg)
+	if err != nil {
+		return err
+	}
+	pool, err := proxy.NewPool(cfg)
+	if err != nil {
+		return err
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for upstream, instances := range rt.drained {
+		for instance := range instances {
+			pool.SetDrained(upstream, instance, true)
+		}
+	}
+	old := rt.current.Swap(&runtimeState{cfg: cfg, table: table, pool: pool, ready: NewReadinessChecker(cfg, pool)})
+	if old != nil {
+		go old.pool.Close()
+	}
+	return nil
+}
+
+// Close 关闭当前连接池，网关退出时调用
+func (rt *Runtime) Close() error {
+	rt.load().pool.Close()
+	return nil
+}
+
+func (rt *Runtime) load() *runtimeState {
+	return rt.current.Load()
+}
+
+// RateLimitClass 返回请求在当前路由表中的限流类别
+func (rt *Runtime) RateLimitClass(method, path string) string {
+	return rt.load().table.RateLimitClass(method, path)
+}
+
+// SetDrained 手动摘除或恢复上游实例，上游或实例不在当前配置中时返回 false
+func (rt *Runtime) SetDrained(upstream, instance string, drained bool) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if !rt.load().pool.SetDrained(upstream, instance, drained) {
+		return false
+	}
+	if drained {
+		if rt.drained[upstream] == nil {
+			rt.drained[upstream] = make(map[string]bool)
+		}
+		rt.drained[upstream][instance] = true
+	} else {
+		delete(rt.drained[upstream], instance)
+	}
+	return true
+}
+
+// SetMaintenance 把路由设为维护模式，之后的请求直接返回 503 和 message
+func (rt *Runtime) SetMaintenance(route, message string) {
+	rt.mu.Lock()
+	rt.maintenance[route] = message
+	rt.mu.Unlock()
+}
+
+// ClearMaintenance 结束路由的维护模式，路由不在维护中时返回 false
+func (rt *Runtime) ClearMaintenance(route string) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if _, ok := rt.maintenance[route]; !ok {
+		return false
+	}
+	delete(rt.maintenance, route)
+	return true
+}
+
+// Maintenance 返回路由是否处于维护模式及提示信息
+func (rt *Runtime) Maintenance(route string) (string, bool) {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	message, ok := rt.maintenance[route]
+	return message, ok
+}
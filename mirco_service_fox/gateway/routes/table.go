@@ -0,0 +1,158 @@
+package routes
+
+import (
+	"gateway/config"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Route 编译后的路由
+type Route struct {
+	config.RouteConfig
+	Target string // 上游地址
+
+	regex   *regexp.Regexp
+	methods map[string]bool
+}
+
+// Table 路由表
+//
+// 匹配优先级固定为：exact > regex > prefix。
+// 同类 exact/regex 路由按配置顺序匹配，prefix 路由按前缀长度从长到短匹配，长度相同时按配置顺序。
+type Table struct {
+	routes []*Route
+}
+
+func matchRank(match string) int {
+	switch match {
+	case config.MatchExact:
+		return 0
+	case config.MatchRegex:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// NewTable 根据配置构建路由表，配置需已通过 Validate 校验
+func NewTable(cfg *config.ServiceConfig) (*Table, error) {
+	routes := make([]*Route, 0, len(cfg.Routes))
+	for _, rc := range cfg.Routes {
+		r := &Route{
+			RouteConfig: rc,
+			Target:      cfg.Upstreams[rc.Upstream].URL,
+		}
+		if rc.Match == config.MatchRegex {
+			re, err := regexp.Compile(rc.Path)
+			if err != nil {
+				return nil, err
+			}
+			r.regex = re
+		}
+		if len(rc.Methods) > 0 {
+			r.methods = make(map[string]bool, len(rc.Methods))
+			for _, m := range rc.Methods {
+				r.methods[m] = true
+			}
+		}
+		routes = append(routes, r)
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		ri, rj := matchRank(routes[i].Match), matchRank(routes[j].Match)
+		if ri != rj {
+			return ri < rj
+		}
+		if routes[i].Match == config.MatchPrefix {
+			return len(routes[i].Path) > len(routes[j].Path)
+		}
+		return false
+	})
+	return &Table{routes: routes}, nil
+}
+
+// Routes 返回按优先级排好序的路由
+func (t *Table) Routes() []*Route {
+	return t.routes
+}
+
+// Match 查找路由。没有路径匹配时返回 404，路径匹配但方法不允许时返回 405
+func (t *Table) Match(method, path string) (*Route, int) {
+	status := http.StatusNotFound
+	for _, r := range t.routes {
+		if !r.matchPath(path) {
+			continue
+		}
+		if r.methods != nil && !r.methods[method] {
+			status = http.StatusMethodNotAllowed
+			continue
+		}
+		return r, http.StatusOK
+	}
+	return nil, status
+}
+
+// RateLimitClass 返回请求对应路由的限流类别，未匹配或未配置时返回空字符串
+func (t *Table) RateLimitClass(method, path string) string {
+	if r, _ := t.Match(method, path); r != nil {
+		return r.RateLimit
+	}
+	return ""
+}
+
+// AllowedMethods 返回路径可用的方法，用于 405 响应的 Allow 头
+func (t *Table) AllowedMethods(path string) []string {
+	var methods []string
+	seen := make(map[string]bool)
+	for _, r := range t.routes {
+		if !r.matchPath(path) {
+			continue
+		}
+		for _, m := range r.Methods {
+			if !seen[m] {
+				seen[m] = true
+				methods = append(methods, m)
+			}
+		}
+	}
+	return methods
+}
+
+func (r *Route) matchPath(path string) bool {
+	switch r.Match {
+	case config.MatchExact:
+		return path == r.Path
+	case config.MatchRegex:
+		return r.regex.MatchString(path)
+	default:
+		return hasPathPrefix(path, r.Path)
+	}
+}
+
+// hasPathPrefix 按路径段判断前缀，避免 /api/student 匹配到 /api/student_list
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// RewritePath 计算转发到上游的路径，配置了 rewrite 时忽略 strip_prefix
+func (r *Route) RewritePath(path string) string {
+	switch {
+	case r.Rewrite != "" && r.Match == config.MatchRegex:
+		return r.regex.ReplaceAllString(path, r.Rewrite)
+	case r.Rewrite != "" && r.Match == config.MatchPrefix:
+		return r.Rewrite + strings.TrimPrefix(path, r.Path)
+	case r.Rewrite != "":
+		return r.Rewrite
+	case r.StripPrefix != "" && hasPathPrefix(path, r.StripPrefix):
+		path = strings.TrimPrefix(path, r.StripPrefix)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	return path
+}
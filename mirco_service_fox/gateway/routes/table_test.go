@@ -0,0 +1,119 @@
+package routes
+
+import (
+	"gateway/config"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func newTestTable(t *testing.T) *Table {
+	t.Helper()
+	table, err := NewTable(&config.ServiceConfig{
+		Upstreams: map[string]config.UpstreamConfig{
+			"user":       {URL: "http://user-service:8081"},
+			"experiment": {URL: "http://experiment-service:8082"},
+		},
+		Routes: []config.RouteConfig{
+			{Name: "experiments", Match: config.MatchPrefix, Path: "/api/student", Upstream: "experiment"},
+			{Name: "student-submit", Match: config.MatchRegex, Path: `^/api/student/experiments/[^/]+/submit$`, Methods: []string{"POST"}, Upstream: "experiment"},
+			{Name: "profile", Match: config.MatchExact, Path: "/api/student/profile", Methods: []string{"GET", "PUT"}, Upstream: "user"},
+			{Name: "auth", Match: config.MatchPrefix, Path: "/api/auth", Methods: []string{"POST"}, Upstream: "user"},
+			{Name: "auth-login", Match: config.MatchPrefix, Path: "/api/auth/login", Methods: []string{"POST"}, Upstream: "user"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func TestTableMatch(t *testing.T) {
+	table := newTestTable(t)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		route  string
+		status int
+	}{
+		{"exact before prefix", "GET", "/api/student/profile", "profile", http.StatusOK},
+		{"regex before prefix", "POST", "/api/student/experiments/1/submit", "student-submit", http.StatusOK},
+		{"regex method mismatch falls back to prefix", "GET", "/api/student/experiments/1/submit", "experiments", http.StatusOK},
+		{"exact method mismatch falls back to prefix", "DELETE", "/api/student/profile", "experiments", http.StatusOK},
+		{"longer prefix first", "POST", "/api/auth/login", "auth-login", http.StatusOK},
+		{"shorter prefix", "POST", "/api/auth/register", "auth", http.StatusOK},
+		{"prefix matches whole segments", "GET", "/api/student_list", "", http.StatusNotFound},
+		{"no route", "GET", "/api/unknown", "", http.StatusNotFound},
+		{"method not allowed", "GET", "/api/auth/login", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, status := table.Match(tt.method, tt.path)
+			if status != tt.status {
+				t.Fatalf("Match(%s %s) status = %d, want %d", tt.method, tt.path, status, tt.status)
+			}
+			var name string
+			if r != nil {
+				name = r.Name
+			}
+			if name != tt.route {
+				t.Errorf("Match(%s %s) route = %q, want %q", tt.method, tt.path, name, tt.route)
+			}
+		})
+	}
+}
+
+func TestTableAllowedMethods(t *testing.T) {
+	table := newTestTable(t)
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/api/auth/login", []string{"POST"}},
+		{"/api/student/profile", []string{"GET", "PUT"}},
+		{"/api/unknown", nil},
+	}
+	for _, tt := range tests {
+		if got := table.AllowedMethods(tt.path); !slices.Equal(got, tt.want) {
+			t.Errorf("AllowedMethods(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRouteRewritePath(t *testing.T) {
+	table, err := NewTable(&config.ServiceConfig{
+		Upstreams: map[string]config.UpstreamConfig{"user": {URL: "http://user-service:8081"}},
+		Routes: []config.RouteConfig{
+			{Name: "strip", Match: config.MatchPrefix, Path: "/api/users", StripPrefix: "/api", Upstream: "user"},
+			{Name: "strip-exact", Match: config.MatchPrefix, Path: "/files", StripPrefix: "/files", Upstream: "user"},
+			{Name: "strip-segment", Match: config.MatchPrefix, Path: "/apix", StripPrefix: "/api", Upstream: "user"},
+			{Name: "prefix-rewrite", Match: config.MatchPrefix, Path: "/v2/users", Rewrite: "/api/users", Upstream: "user"},
+			{Name: "regex-rewrite", Match: config.MatchRegex, Path: `^/u/(\d+)$`, Rewrite: "/api/users/$1", Upstream: "user"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/users/1", "/users/1"},
+		{"/files", "/"},
+		{"/files/a.txt", "/a.txt"},
+		// strip_prefix 只去掉完整的路径段
+		{"/apix/1", "/apix/1"},
+		{"/v2/users/1", "/api/users/1"},
+		{"/u/42", "/api/users/42"},
+	}
+	for _, tt := range tests {
+		r, status := table.Match("GET", tt.path)
+		if status != http.StatusOK {
+			t.Fatalf("Match(%q) status = %d", tt.path, status)
+		}
+		if got := r.RewritePath(tt.path); got != tt.want {
+			t.Errorf("%s: RewritePath(%q) = %q, want %q", r.Name, tt.path, got, tt.want)
+		}
+	}
+}
@@ -4,27 +4,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"shared/identity"
 	"strconv"
 	"time"
 
 	"notification-service/config"
 	"notification-service/database"
+	"notification-service/metrics"
 	"notification-service/models"
+	"shared/logger"
+	"shared/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// CreateNotificationRequest 创建通知的请求结构体
+type CreateNotificationRequest struct {
+	Title        string `json:"title" binding:"required"`
+	Content      string `json:"content" binding:"required"`
+	ExperimentID string `json:"experiment_id"`
+	IsImportant  bool   `json:"is_important"`
+	UserIDs      []uint `json:"user_ids"` // 用户ID列表
+}
+
 // CreateNotification 创建通知
 func CreateNotification(c *gin.Context) {
-	var req struct {
-		Title        string `json:"title" binding:"required"`
-		Content      string `json:"content" binding:"required"`
-		ExperimentID string `json:"experiment_id"`
-		IsImportant  bool   `json:"is_important"`
-		UserIDs      []uint `json:"user_ids"` // 用户ID列表
-
-	}
+	var req CreateNotificationRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -32,15 +38,25 @@ func CreateNotification(c *gin.Context) {
 	}
 	// 通知服务调用用户服务的 API 来验证学生ID是否存在
 	client := &http.Client{
-		Timeout: time.Second * 5, // 设置5秒超时
+		Timeout:   time.Second * 5,                          // 设置5秒超时
+		Transport: tracing.Transport(http.DefaultTransport), // 记录调用链路并传递 traceparent
 	}
 	cfg := config.LoadConfig()
 	// 验证每个用户ID
 	for _, userID := range req.UserIDs {
 		// 调用用户服务API
 		url := fmt.Sprintf("%s/internal/users/%d", cfg.UserServiceURL, userID)
-		resp, err := client.Get(url)
+		userReq, err := logger.NewRequest(c.Request.Context(), http.MethodGet, url, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "无法连接用户服务",
+			})
+			return
+		}
+		identity.SetServiceCredentials(userReq)
+		resp, err := client.Do(userReq)
 		if err != nil {
+			logger.FromContext(c.Request.Context()).WithError(err).WithField("user_id", userID).Error("无法连接用户服务")
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "无法连接用户服务",
 			})
@@ -93,7 +109,7 @@ func CreateNotification(c *gin.Context) {
 	}
 
 	// 使用事务保存通知和用户关联
-	tx := database.DB.Begin()
+	tx := database.DB.WithContext(c.Request.Context()).Begin()
 	if err := tx.Create(&notification).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create notification"})
@@ -114,6 +130,8 @@ func CreateNotification(c *gin.Context) {
 	}
 
 	tx.Commit()
+	metrics.NotificationsCreated.WithLabelValues(strconv.FormatBool(notification.IsImportant)).Inc()
+	metrics.NotificationRecipients.Add(float64(len(req.UserIDs)))
 
 	c.JSON(http.StatusCreated, notification)
 }
@@ -138,7 +156,7 @@ func GetTeacherNotifications(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的每页数量"})
 		return
 	}
-	db := database.GetDB()
+	db := database.GetDB().WithContext(c.Request.Context())
 	// 构建查询
 	query := db.Model(&models.Notification{}).Order("created_at DESC")
 
@@ -194,6 +212,11 @@ func GetStudentNotifications(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "student_id is required"})
 		return
 	}
+	// 学生只能查看自己的通知
+	if identity.Role(c) != "teacher" && studentID != strconv.FormatUint(uint64(identity.UserID(c)), 10) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权查看其他学生的通知"})
+		return
+	}
 
 	// 解析查询参数
 	pageStr := c.DefaultQuery("page", "1")
@@ -214,7 +237,7 @@ func GetStudentNotifications(c *gin.Context) {
 	}
 
 	// 构建查询 with join table
-	query := database.DB.
+	query := database.DB.WithContext(c.Request.Context()).
 		Joins("JOIN notification_users ON notification_users.notification_id = notifications.id").
 		Where("notification_users.user_id = ?", studentID).
 		Order("notifications.created_at DESC")
@@ -2,12 +2,13 @@ package database
 
 import (
 	"fmt"
-	"log"
 	"notification-service/models"
 	"os"
+	"shared/logger"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	otelgorm "gorm.io/plugin/opentelemetry/tracing"
 )
 
 var (
@@ -30,12 +31,17 @@ func InitDB() error {
 		return fmt.Errorf("failed to connect to database: %v", err)
 	}
 
+	// 每条 SQL 记录一个 span，挂在请求的链路下（需要 db.WithContext）
+	if err := DB.Use(otelgorm.NewPlugin(otelgorm.WithoutMetrics())); err != nil {
+		return fmt.Errorf("failed to register gorm tracing plugin: %v", err)
+	}
+
 	// 自动迁移
 	if err := models.AutoMigrate(DB); err != nil {
 		return fmt.Errorf("failed to migrate database: %v", err)
 	}
 
-	log.Println("Database connection established successfully")
+	logger.Log.Info("Database connection established successfully")
 	return nil
 }
 
@@ -1,56 +1,75 @@
 package main
 
 import (
-	"log"
+	"context"
 	"net/http"
 	"notification-service/database"
 	"notification-service/routers"
+	"shared/identity"
+	"shared/logger"
+	"shared/server"
+	"shared/tracing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	logger.Init("notification-service")
+	// 初始化链路追踪
+	shutdownTracing, err := tracing.Init(context.Background(), "notification-service")
+	if err != nil {
+		logger.Log.WithError(err).Fatal("Failed to init tracing")
+	}
+	defer shutdownTracing(context.Background())
+	// 身份校验失败时按本服务的 {"error"} 格式返回
+	identity.ErrorBody = func(status int, message string) any {
+		return gin.H{"error": message}
+	}
+	// 内部身份断言的签名密钥，没有时无法识别用户，直接退出
+	if err := identity.Init(); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to init identity verification")
+	}
+	// 调用用户服务内部接口的令牌
+	if err := identity.InitServiceToken("notification-service"); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to init service token")
+	}
+	// 实验服务发布实验时调用本服务的内部接口
+	if err := identity.AllowCallers("experiment-service"); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to init service token")
+	}
+
 	// 初始化数据库
-	var err error
 	maxRetries := 10
 	for i := range maxRetries {
 		err = database.InitDB()
 		if err == nil {
 			break
 		}
-		log.Printf("Database initialization failed (attempt %d/%d): %v", i+1, maxRetries, err)
+		logger.Log.WithError(err).Warnf("Database initialization failed (attempt %d/%d)", i+1, maxRetries)
 		time.Sleep(5 * time.Second)
 	}
 
 	if err != nil {
-		log.Fatalf("Failed to initialize database after %d attempts: %v", maxRetries, err)
+		logger.Log.WithError(err).Fatalf("Failed to initialize database after %d attempts", maxRetries)
 	}
 
 	// 初始化路由
 	router := routers.InitRouter()
 
-	// 添加健康检查端点
-	router.GET("/health", func(c *gin.Context) {
-		// 检查数据库连接
-		db := database.GetDB()
-		sqlDB, err := db.DB()
-		if err != nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": "database error"})
-			return
-		}
-
-		if err := sqlDB.Ping(); err != nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": "database ping failed"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
-	})
+	// 启动服务，收到 SIGTERM 后等正在处理的请求结束，再关闭数据库连接池
+	logger.Log.Info("Notification service starting on :8083")
+	srv := &http.Server{Addr: ":8083", Handler: router}
+	if err := server.Run(srv, server.OptionsFromEnv(), closeDB); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to start server")
+	}
+}
 
-	// 启动服务
-	log.Println("Notification service starting on :8083")
-	if err := router.Run(":8083"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// closeDB 关闭数据库连接池
+func closeDB() error {
+	sqlDB, err := database.GetDB().DB()
+	if err != nil {
+		return err
 	}
+	return sqlDB.Close()
 }
@@ -0,0 +1,20 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// NotificationsCreated 创建的通知数，按是否重要区分
+	NotificationsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notifications_created_total",
+		Help: "Number of notifications created.",
+	}, []string{"important"})
+
+	// NotificationRecipients 通知送达的用户数
+	NotificationRecipients = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "notification_recipients_total",
+		Help: "Number of notification deliveries to users.",
+	})
+)
@@ -0,0 +1,51 @@
+package routers
+
+import (
+	"net/http"
+	"notification-service/controllers"
+	"notification-service/models"
+	"shared/openapi"
+)
+
+// 下面的结构体只用于生成文档，描述接口中用 gin.H 拼出的请求参数和响应
+
+type notificationQuery struct {
+	Page         int    `form:"page,default=1"`
+	Limit        int    `form:"limit,default=10"`
+	ExperimentID string `form:"experiment_id" doc:"只看这个实验的通知"`
+	IsImportant  string `form:"is_important" doc:"true 只看重要公告，false 只看普通通知"`
+	CreatedAfter string `form:"created_after" doc:"RFC3339 时间，只看这之后创建的通知"`
+}
+
+type pagination struct {
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"`
+	Total int64 `json:"total"`
+}
+
+type notificationList struct {
+	Status     string                `json:"status"`
+	Data       []models.Notification `json:"data"`
+	Pagination pagination            `json:"pagination"`
+}
+
+// Spec 生成本服务对外接口的 OpenAPI 文档，修改接口时同步更新
+func Spec() *openapi.Spec {
+	spec := openapi.New("notification-service", "1.0.0")
+
+	spec.Add(openapi.Route{
+		Method: http.MethodPost, Path: "/api/teacher/experiments/notifications", Tag: "teacher",
+		Summary: "发布通知", Description: "user_ids 中的用户会先到用户服务校验是否存在",
+		Body: controllers.CreateNotificationRequest{}, Status: http.StatusCreated, Response: models.Notification{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/teacher/experiments/notifications", Tag: "teacher",
+		Summary: "全部通知", Query: notificationQuery{}, Response: notificationList{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/student/experiments/notifications/:student_id", Tag: "student",
+		Summary: "学生收到的通知", Description: "学生只能查看自己的通知",
+		Params: map[string]string{"student_id": "学生的用户 ID"}, Query: notificationQuery{}, Response: notificationList{},
+	})
+	return spec
+}
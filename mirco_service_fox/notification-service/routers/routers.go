@@ -2,24 +2,66 @@
 package routers
 
 import (
-	"notification-service/controllers"
 	"net/http"
+	"notification-service/controllers"
+	"notification-service/database"
+	"shared/identity"
+	"shared/logger"
+	"shared/metrics"
+	"shared/server"
+	"shared/tracing"
 
 	"github.com/gin-gonic/gin"
 )
 
 func InitRouter() *gin.Engine {
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), tracing.Middleware("notification-service"), logger.Middleware(), identity.Middleware())
+	router.Use(metrics.Middleware())
 
 	// 通知相关路由
-	router.POST("/api/teacher/experiments/notifications", controllers.CreateNotification)
-	router.GET("/api/teacher/experiments/notifications", controllers.GetTeacherNotifications)
+	teacher := router.Group("/api/teacher", identity.RequireRole("teacher"))
+	teacher.POST("/experiments/notifications", controllers.CreateNotification)
+	teacher.GET("/experiments/notifications", controllers.GetTeacherNotifications)
+	// 实验服务发布实验时调用的内部接口，网关不对外暴露
+	internal := router.Group("/internal", identity.RequireService())
+	internal.POST("/notifications", controllers.CreateNotification)
 	router.GET("/api/student/experiments/notifications/:student_id", controllers.GetStudentNotifications)
 
-	// 添加健康检查端点（main.go 中也有，但这里也加一个保险）
+	// Prometheus 指标
+	router.GET("/metrics", metrics.Handler())
+	// 接口文档，网关合并各服务的文档后对外提供
+	spec := Spec()
+	router.GET("/openapi.json", spec.Handler())
+
+	// 存活检查，不依赖数据库，退出过程中也返回 200
+	router.GET("/live", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "alive"})
+	})
+	// 就绪检查：正在退出或数据库不可用时返回 503
 	router.GET("/health", func(c *gin.Context) {
+		if server.Draining() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+			return
+		}
+		// 检查数据库连接
+		db := database.GetDB()
+		sqlDB, err := db.DB()
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": "database error"})
+			return
+		}
+
+		if err := sqlDB.Ping(); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": "database ping failed"})
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
+	for _, route := range spec.Missing(router.Routes()) {
+		logger.Log.Warnf("接口 %s 没有写入 OpenAPI 文档", route)
+	}
 
 	return router
 }
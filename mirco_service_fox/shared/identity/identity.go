@@ -0,0 +1,38 @@
+// Package identity 网关转发请求时附带的内部身份断言，以及服务之间调用 /internal 接口时的服务令牌。
+// 断言是网关用共享密钥签名的 HS256 JWT，密钥只有网关和各个服务持有；服务只有校验通过后才把用户放进请求上下文，
+// 客户端直接带来的 X-User-ID / X-User-Role 请求头一律丢弃。
+package identity
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+const (
+	// Header 内部身份断言所在的请求头
+	Header = "X-Internal-Identity"
+	// UserIDHeader、UserRoleHeader 旧的明文身份头，网关转发前删除，服务也不信任
+	UserIDHeader   = "X-User-ID"
+	UserRoleHeader = "X-User-Role"
+)
+
+// MinSecretLength 签名密钥和服务令牌的最短长度（字节）
+const MinSecretLength = 32
+
+// 固定的 JWT 头，校验时要求完全一致
+var encodedHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// ErrInvalidAssertion 断言格式、签名或有效期不正确
+var ErrInvalidAssertion = errors.New("invalid identity assertion")
+
+// Identity 已登录用户
+type Identity struct {
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+type claims struct {
+	Identity
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
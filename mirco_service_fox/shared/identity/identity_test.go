@@ -0,0 +1,134 @@
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"shared/logger"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// signAt 用 testSecret 在 now 时刻签发断言
+func signAt(t *testing.T, id Identity, now time.Time) string {
+	t.Helper()
+	s := NewSigner(testSecret)
+	s.now = func() time.Time { return now }
+	assertion, err := s.Sign(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assertion
+}
+
+// resign 用 testSecret 给任意的头和内容签名，用于构造签名正确但内容不合法的断言
+func resign(parts []string) string {
+	signingInput := parts[0] + "." + parts[1]
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	issued := time.Unix(1700000000, 0)
+	student := Identity{UserID: 42, Role: "student"}
+	valid := signAt(t, student, issued)
+	parts := strings.Split(valid, ".")
+	payload := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	tests := []struct {
+		name      string
+		key       string
+		assertion string
+		now       time.Time
+		want      Identity
+		expired   bool
+		invalid   bool
+	}{
+		{name: "valid", key: testSecret, assertion: valid, now: issued, want: student},
+		{name: "valid until ttl plus skew", key: testSecret, assertion: valid, now: issued.Add(TTL + clockSkew), want: student},
+		{name: "expired", key: testSecret, assertion: valid, now: issued.Add(TTL + clockSkew + time.Second), expired: true},
+		{name: "issued in the future within skew", key: testSecret, assertion: valid, now: issued.Add(-clockSkew), want: student},
+		{name: "issued in the future", key: testSecret, assertion: valid, now: issued.Add(-clockSkew - time.Second), expired: true},
+		{name: "wrong key", key: strings.Repeat("x", MinSecretLength), assertion: valid, now: issued, invalid: true},
+		{name: "tampered payload", key: testSecret, assertion: parts[0] + "." + payload(claims{Identity: Identity{UserID: 42, Role: "teacher"}, IssuedAt: issued.Unix(), ExpiresAt: issued.Add(TTL).Unix()}) + "." + parts[2], now: issued, invalid: true},
+		{name: "alg none", key: testSecret, assertion: base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + ".", now: issued, invalid: true},
+		{name: "other header", key: testSecret, assertion: resign([]string{base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"HS256"}`)), parts[1]}), now: issued, invalid: true},
+		{name: "missing role", key: testSecret, assertion: resign([]string{parts[0], payload(claims{Identity: Identity{UserID: 42}, IssuedAt: issued.Unix(), ExpiresAt: issued.Add(TTL).Unix()})}), now: issued, invalid: true},
+		{name: "missing user", key: testSecret, assertion: resign([]string{parts[0], payload(claims{Identity: Identity{Role: "student"}, IssuedAt: issued.Unix(), ExpiresAt: issued.Add(TTL).Unix()})}), now: issued, invalid: true},
+		{name: "two segments", key: testSecret, assertion: parts[0] + "." + parts[1], now: issued, invalid: true},
+		{name: "empty", key: testSecret, assertion: "", now: issued, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify([]byte(tt.key), tt.assertion, tt.now)
+			if tt.expired || tt.invalid {
+				if !errors.Is(err, ErrInvalidAssertion) {
+					t.Fatalf("Verify() error = %v, want ErrInvalidAssertion", err)
+				}
+				if expired := strings.HasSuffix(err.Error(), "expired"); expired != tt.expired {
+					t.Errorf("Verify() error = %v, expired = %v", err, tt.expired)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Verify() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret = []byte(testSecret)
+	out := logger.Log.Out
+	logger.Log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		secret = nil
+		logger.Log.SetOutput(out)
+	})
+
+	teacher := signAt(t, Identity{UserID: 7, Role: "teacher"}, time.Now())
+	student := signAt(t, Identity{UserID: 8, Role: "student"}, time.Now())
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		body    string
+	}{
+		{"teacher", map[string]string{Header: teacher}, http.StatusOK, "7 teacher"},
+		{"forged plain headers are dropped", map[string]string{UserIDHeader: "1", UserRoleHeader: "teacher"}, http.StatusUnauthorized, `{"message":"请先登录","status":"error"}`},
+		{"forged plain headers next to an assertion", map[string]string{Header: student, UserIDHeader: "1", UserRoleHeader: "teacher"}, http.StatusForbidden, `{"message":"权限不足","status":"error"}`},
+		{"invalid assertion", map[string]string{Header: teacher + "x"}, http.StatusUnauthorized, `{"message":"身份校验失败","status":"error"}`},
+	}
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/teacher", RequireRole("teacher"), func(c *gin.Context) {
+		c.String(http.StatusOK, "%d %s", UserID(c), Role(c))
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/teacher", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.status || rec.Body.String() != tt.body {
+				t.Errorf("GET /teacher = %d %s, want %d %s", rec.Code, rec.Body, tt.status, tt.body)
+			}
+		})
+	}
+}
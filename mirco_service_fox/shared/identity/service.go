@@ -0,0 +1,93 @@
+package identity
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"shared/logger"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ServiceNameHeader、ServiceTokenHeader 服务间调用时标识调用方的请求头
+	ServiceNameHeader  = "X-Service-Name"
+	ServiceTokenHeader = "X-Service-Token"
+)
+
+// GatewayName 网关调用其他服务 /internal 接口时使用的服务名
+const GatewayName = "gateway"
+
+// 上下文中保存调用方服务名的 key
+const serviceKey = "identity.service"
+
+var (
+	serviceName  string
+	serviceToken string
+	// callerTokens 允许调用本服务 /internal 接口的服务及其令牌
+	callerTokens = map[string]string{}
+)
+
+// ServiceTokenEnv 服务令牌所在的环境变量，例如 experiment-service 对应 SERVICE_TOKEN_EXPERIMENT_SERVICE
+func ServiceTokenEnv(name string) string {
+	return "SERVICE_TOKEN_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+func loadServiceToken(name string) (string, error) {
+	token := os.Getenv(ServiceTokenEnv(name))
+	if len(token) < MinSecretLength {
+		return "", fmt.Errorf("%s must be at least %d bytes", ServiceTokenEnv(name), MinSecretLength)
+	}
+	return token, nil
+}
+
+// InitServiceToken 读取本服务调用其他服务 /internal 接口时使用的令牌
+func InitServiceToken(self string) error {
+	token, err := loadServiceToken(self)
+	if err != nil {
+		return err
+	}
+	serviceName, serviceToken = self, token
+	return nil
+}
+
+// AllowCallers 读取允许调用本服务 /internal 接口的服务令牌，缺少任何一个都返回错误
+func AllowCallers(callers ...string) error {
+	for _, name := range callers {
+		token, err := loadServiceToken(name)
+		if err != nil {
+			return err
+		}
+		callerTokens[name] = token
+	}
+	return nil
+}
+
+// SetServiceCredentials 给调用其他服务 /internal 接口的请求带上本服务的令牌
+func SetServiceCredentials(req *http.Request) {
+	req.Header.Set(ServiceNameHeader, serviceName)
+	req.Header.Set(ServiceTokenHeader, serviceToken)
+}
+
+// RequireService 只放行 AllowCallers 中的服务，用于 /internal 路由组
+func RequireService() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.GetHeader(ServiceNameHeader)
+		expected, ok := callerTokens[name]
+		token := c.GetHeader(ServiceTokenHeader)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			logger.FromContext(c.Request.Context()).WithField("caller", name).Warn("Rejected internal request")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorBody(http.StatusUnauthorized, "服务身份校验失败"))
+			return
+		}
+		c.Set(serviceKey, name)
+		c.Next()
+	}
+}
+
+// Service 返回调用方服务名，不是服务间调用时为空
+func Service(c *gin.Context) string {
+	return c.GetString(serviceKey)
+}
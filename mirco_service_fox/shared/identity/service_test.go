@@ -0,0 +1,63 @@
+package identity
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"shared/logger"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireService(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const gatewayToken = "gateway-token-0123456789abcdef01"
+	const experimentToken = "experiment-token-0123456789abcde"
+	t.Setenv(ServiceTokenEnv(GatewayName), gatewayToken)
+	t.Setenv(ServiceTokenEnv("experiment-service"), experimentToken)
+	if err := AllowCallers(GatewayName); err != nil {
+		t.Fatal(err)
+	}
+	out := logger.Log.Out
+	logger.Log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		callerTokens = map[string]string{}
+		logger.Log.SetOutput(out)
+	})
+
+	tests := []struct {
+		name   string
+		caller string
+		token  string
+		status int
+		body   string
+	}{
+		{"allowed caller", GatewayName, gatewayToken, http.StatusOK, "gateway"},
+		{"wrong token", GatewayName, experimentToken, http.StatusUnauthorized, `{"message":"服务身份校验失败","status":"error"}`},
+		// experiment-service 有令牌但不在 AllowCallers 中
+		{"caller not allowed", "experiment-service", experimentToken, http.StatusUnauthorized, `{"message":"服务身份校验失败","status":"error"}`},
+		{"no credentials", "", "", http.StatusUnauthorized, `{"message":"服务身份校验失败","status":"error"}`},
+		{"empty token", GatewayName, "", http.StatusUnauthorized, `{"message":"服务身份校验失败","status":"error"}`},
+	}
+	r := gin.New()
+	r.GET("/internal/ping", RequireService(), func(c *gin.Context) {
+		c.String(http.StatusOK, Service(c))
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/internal/ping", nil)
+			if tt.caller != "" {
+				req.Header.Set(ServiceNameHeader, tt.caller)
+			}
+			if tt.token != "" {
+				req.Header.Set(ServiceTokenHeader, tt.token)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.status || rec.Body.String() != tt.body {
+				t.Errorf("GET /internal/ping = %d %s, want %d %s", rec.Code, rec.Body, tt.status, tt.body)
+			}
+		})
+	}
+}
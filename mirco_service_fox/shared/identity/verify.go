@@ -0,0 +1,122 @@
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"shared/logger"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 允许的时钟偏差
+const clockSkew = 30 * time.Second
+
+// 上下文中保存用户信息的 key
+const (
+	userIDKey   = "identity.userID"
+	userRoleKey = "identity.userRole"
+)
+
+var secret []byte
+
+// ErrorBody 生成身份校验失败（401/403）的响应体，默认为 {"status":"error","message":...}，
+// 错误格式不同的服务在启动时替换
+var ErrorBody = func(status int, message string) any {
+	return gin.H{"status": "error", "message": message}
+}
+
+// Init 从 INTERNAL_IDENTITY_SECRET 环境变量读取签名密钥，必须与网关一致
+func Init() error {
+	s := os.Getenv("INTERNAL_IDENTITY_SECRET")
+	if len(s) < MinSecretLength {
+		return fmt.Errorf("INTERNAL_IDENTITY_SECRET must be at least %d bytes", MinSecretLength)
+	}
+	secret = []byte(s)
+	return nil
+}
+
+// Verify 校验断言签名和有效期，返回其中的用户
+func Verify(key []byte, assertion string, now time.Time) (Identity, error) {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 || parts[0] != encodedHeader {
+		return Identity{}, ErrInvalidAssertion
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, ErrInvalidAssertion
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return Identity{}, ErrInvalidAssertion
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Identity{}, ErrInvalidAssertion
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.UserID == 0 || c.Role == "" {
+		return Identity{}, ErrInvalidAssertion
+	}
+	if now.After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)) || time.Unix(c.IssuedAt, 0).After(now.Add(clockSkew)) {
+		return Identity{}, fmt.Errorf("%w: expired", ErrInvalidAssertion)
+	}
+	return c.Identity, nil
+}
+
+// Middleware 校验网关附带的身份断言。没有断言的请求按未登录处理，断言无效时返回 401。
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assertion := c.GetHeader(Header)
+		// 身份头只能来自校验过的断言，处理函数看不到客户端伪造的值
+		c.Request.Header.Del(Header)
+		c.Request.Header.Del(UserIDHeader)
+		c.Request.Header.Del(UserRoleHeader)
+		if assertion == "" {
+			c.Next()
+			return
+		}
+		id, err := Verify(secret, assertion, time.Now())
+		if err != nil {
+			logger.FromContext(c.Request.Context()).WithError(err).Warn("Rejected identity assertion")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorBody(http.StatusUnauthorized, "身份校验失败"))
+			return
+		}
+		c.Set(userIDKey, id.UserID)
+		c.Set(userRoleKey, id.Role)
+		c.Next()
+	}
+}
+
+// UserID 返回当前用户 ID，未登录时为 0
+func UserID(c *gin.Context) uint {
+	return c.GetUint(userIDKey)
+}
+
+// Role 返回当前用户角色，未登录时为空
+func Role(c *gin.Context) string {
+	return c.GetString(userRoleKey)
+}
+
+// RequireRole 只放行已登录且角色为 role 的用户：未登录返回 401，角色不符返回 403。
+// 网关的路由表也检查角色，这里防止绕过网关或路由配置遗漏
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if UserID(c) == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorBody(http.StatusUnauthorized, "请先登录"))
+			return
+		}
+		if Role(c) != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorBody(http.StatusForbidden, "权限不足"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader 请求 ID 所在的请求头，由网关生成，服务之间调用时继续传递
+const RequestIDHeader = "X-Request-ID"
+
+// Log 服务日志，JSON 格式输出到标准输出，级别由 LOG_LEVEL 环境变量控制（默认 info）。
+// 启动时调用 Init 设置服务名；已有自己日志配置的服务可以直接替换为自己的 *logrus.Logger
+var Log = New("")
+
+// Init 把 Log 换成带 service 字段的日志，要在输出第一条日志之前调用
+func Init(service string) {
+	Log = New(service)
+}
+
+// New 创建 JSON 格式的日志，service 不为空时每条日志都带 service 字段
+func New(service string) *logrus.Logger {
+	l := logrus.New()
+	l.SetOutput(os.Stdout)
+	l.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	level, err := logrus.ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	l.SetLevel(level)
+	if service != "" {
+		l.AddHook(serviceHook(service))
+	}
+	return l
+}
+
+type serviceHook string
+
+func (h serviceHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h serviceHook) Fire(entry *logrus.Entry) error {
+	entry.Data["service"] = string(h)
+	return nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 把请求 ID 放进 context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID 取出 context 中的请求 ID，没有时返回空串
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext 返回带请求 ID 的日志
+func FromContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(Log)
+	if id := RequestID(ctx); id != "" {
+		entry = entry.WithField("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		entry = entry.WithFields(logrus.Fields{"trace_id": sc.TraceID().String(), "span_id": sc.SpanID().String()})
+	}
+	return entry
+}
+
+// NewRequest 创建出站请求，并带上 ctx 中的请求 ID
+func NewRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if id := RequestID(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	return req, nil
+}
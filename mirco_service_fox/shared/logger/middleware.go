@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Middleware 读取网关传来的请求 ID（没有时生成一个），放进请求的 context 和响应头，
+// 在错误响应体中补上 request_id，并在请求结束后输出一条 JSON 访问日志
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Header(RequestIDHeader, requestID)
+		c.Set("RequestID", requestID)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), requestID))
+
+		finish := WrapErrorBody(c, requestID)
+		c.Next()
+		finish()
+
+		path := c.Request.URL.Path
+		status := c.Writer.Status()
+		entry := FromContext(c.Request.Context()).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       path,
+			"route":      c.FullPath(),
+			"status":     status,
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+		})
+		if len(c.Errors) > 0 {
+			entry = entry.WithField("error", c.Errors.String())
+		}
+		switch {
+		case status >= 500:
+			entry.Error("request completed")
+		case status >= 400:
+			entry.Warn("request completed")
+		case path == "/health" || strings.HasPrefix(path, "/health/") || path == "/live" || path == "/metrics":
+			// 探针请求很频繁，只在 debug 级别输出
+			entry.Debug("request completed")
+		default:
+			entry.Info("request completed")
+		}
+	}
+}
+
+// validRequestID 外部传入的请求 ID 会原样写进日志和响应，只接受长度有限的可见字符
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorBodyWriter 在 4xx/5xx 的 JSON 响应体中补上 request_id 字段，
+// 方便用户反馈问题时直接给出请求 ID。其余响应原样透传。
+type errorBodyWriter struct {
+	gin.ResponseWriter
+	requestID string
+	buf       bytes.Buffer
+	buffering bool
+}
+
+// WrapErrorBody 替换 c.Writer，返回的函数需要在 c.Next() 之后调用以写出缓存的错误响应
+func WrapErrorBody(c *gin.Context, requestID string) func() {
+	w := &errorBodyWriter{ResponseWriter: c.Writer, requestID: requestID}
+	c.Writer = w
+	return w.finish
+}
+
+func (w *errorBodyWriter) shouldBuffer() bool {
+	if w.buffering {
+		return true
+	}
+	if w.ResponseWriter.Written() || w.Status() < 400 ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return false
+	}
+	w.buffering = true
+	return true
+}
+
+func (w *errorBodyWriter) Write(b []byte) (int, error) {
+	if w.shouldBuffer() {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *errorBodyWriter) WriteString(s string) (int, error) {
+	if w.shouldBuffer() {
+		return w.buf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *errorBodyWriter) Flush() {
+	if !w.buffering {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *errorBodyWriter) finish() {
+	if !w.buffering {
+		return
+	}
+	w.buffering = false
+	body := w.buf.Bytes()
+
+	var obj map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if dec.Decode(&obj) == nil && obj != nil {
+		if _, ok := obj["request_id"]; !ok {
+			obj["request_id"] = w.requestID
+			if b, err := json.Marshal(obj); err == nil {
+				body = b
+			}
+		}
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,459 @@
+// Package openapi 根据路由表和请求、响应结构体生成 OpenAPI 3 文档，在 /openapi.json 提供给网关合并。
+// 字段名取 json 标签（查询参数取 form 标签），binding 标签中的 required、oneof、gt、min、max 等转换为对应的约束，
+// 所以文档与接口实际绑定的结构体保持一致。
+package openapi
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version 生成的文档版本
+const Version = "3.0.3"
+
+// Document OpenAPI 文档，只包含用到的字段
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem 小写的请求方法 -> 接口
+type PathItem map[string]*Operation
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+type Operation struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationID string               `json:"operationId,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path | query
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Schema JSON Schema 的子集。
+// XOmitEmpty 对应 binding 的 omitempty：值为零值（空字符串、0）时不检查其它约束
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	XOmitEmpty           bool               `json:"x-omitempty,omitempty"`
+}
+
+// Route 一个接口的描述
+type Route struct {
+	Method      string
+	Path        string // gin 路径，:name 转换为 {name}
+	Tag         string
+	Summary     string
+	Description string
+	Params      map[string]string // 路径参数说明
+	Query       any               // 查询参数结构体，字段用 form 标签
+	Body        any               // 请求体结构体
+	BodyType    string            // 请求体类型，默认 application/json
+	Status      int               // 成功时的状态码，默认 200
+	Response    any               // 成功时的响应体结构体，为 nil 时只有说明
+}
+
+// Spec 服务的 OpenAPI 文档
+type Spec struct {
+	doc   Document
+	types map[string]reflect.Type // 已注册的 schema 名称 -> 类型，名称冲突时加包名区分
+}
+
+// New 创建空文档
+func New(title, version string) *Spec {
+	return &Spec{
+		doc: Document{
+			OpenAPI:    Version,
+			Info:       Info{Title: title, Version: version},
+			Paths:      make(map[string]PathItem),
+			Components: Components{Schemas: make(map[string]*Schema)},
+		},
+		types: make(map[string]reflect.Type),
+	}
+}
+
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// Add 添加接口
+func (s *Spec) Add(r Route) {
+	path := ginParam.ReplaceAllString(r.Path, "{$1}")
+	op := &Operation{
+		Tags:        []string{r.Tag},
+		Summary:     r.Summary,
+		Description: r.Description,
+		OperationID: operationID(r.Method, path),
+		Responses:   make(map[string]*Response),
+	}
+	for _, m := range ginParam.FindAllStringSubmatch(r.Path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name: m[1], In: "path", Required: true, Description: r.Params[m[1]], Schema: &Schema{Type: "string"},
+		})
+	}
+	if r.Query != nil {
+		op.Parameters = append(op.Parameters, s.queryParams(reflect.TypeOf(r.Query))...)
+	}
+	if r.Body != nil {
+		bodyType := r.BodyType
+		if bodyType == "" {
+			bodyType = "application/json"
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{bodyType: {Schema: s.schemaOf(reflect.TypeOf(r.Body))}},
+		}
+	}
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := &Response{Description: http.StatusText(status)}
+	if r.Response != nil {
+		resp.Content = map[string]MediaType{"application/json": {Schema: s.schemaOf(reflect.TypeOf(r.Response))}}
+	}
+	op.Responses[strconv.Itoa(status)] = resp
+	op.Responses["default"] = &Response{Description: "错误，响应体中的 message 或 error 为错误说明"}
+
+	if s.doc.Paths[path] == nil {
+		s.doc.Paths[path] = make(PathItem)
+	}
+	s.doc.Paths[path][strings.ToLower(r.Method)] = op
+}
+
+// Document 返回生成的文档
+func (s *Spec) Document() *Document {
+	return &s.doc
+}
+
+// Handler 返回文档的 JSON
+func (s *Spec) Handler() gin.HandlerFunc {
+	body, err := json.Marshal(&s.doc)
+	return func(c *gin.Context) {
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
+
+// Missing 返回已注册但文档中没有描述的 /api 接口，启动时输出警告，提醒修改接口时同步更新文档
+func (s *Spec) Missing(routes gin.RoutesInfo) []string {
+	var missing []string
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Path, "/api/") {
+			continue
+		}
+		path := ginParam.ReplaceAllString(r.Path, "{$1}")
+		if _, ok := s.doc.Paths[path][strings.ToLower(r.Method)]; !ok {
+			missing = append(missing, r.Method+" "+r.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// operationID 由方法和路径生成，如 post_api_teacher_experiments_experiment_id
+func operationID(method, path string) string {
+	id := strings.ToLower(method) + strings.NewReplacer("/", "_", "{", "", "}", "", "-", "_", ".", "_").Replace(path)
+	return strings.TrimSuffix(id, "_")
+}
+
+// queryParams 把结构体字段转换为查询参数，form 标签可以带默认值，如 form:"page,default=1"
+func (s *Spec) queryParams(t reflect.Type) []Parameter {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("form")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		schema := s.schemaOf(f.Type)
+		required := s.applyBinding(schema, f)
+		if v, ok := strings.CutPrefix(opts, "default="); ok {
+			schema.Default = parseValue(f.Type, v)
+		}
+		params = append(params, Parameter{
+			Name: name, In: "query", Required: required, Description: f.Tag.Get("doc"), Schema: schema,
+		})
+	}
+	return params
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	fileType      = reflect.TypeOf(multipart.FileHeader{})
+)
+
+// schemaOf 把 Go 类型转换为 schema，具名结构体放到 components 中引用
+func (s *Spec) schemaOf(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		schema := s.schemaOf(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == fileType:
+		// multipart/form-data 中上传的文件
+		return &Schema{Type: "string", Format: "binary"}
+	case t.Kind() == reflect.Struct && (t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType)):
+		// 自定义序列化的结构体（如 gorm.DeletedAt 和各种时间类型）都序列化为字符串
+		return &Schema{Type: "string", Nullable: true}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.register(t)}
+	default:
+		// interface{} 等任意类型
+		return &Schema{}
+	}
+}
+
+// register 把具名结构体加入 components，返回 schema 名称
+func (s *Spec) register(t reflect.Type) string {
+	name := t.Name()
+	if existing, ok := s.types[name]; ok && existing != t {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	if _, ok := s.types[name]; ok {
+		return name
+	}
+	s.types[name] = t
+	s.doc.Components.Schemas[name] = &Schema{Type: "object"} // 先占位，支持递归引用
+	s.doc.Components.Schemas[name] = s.structSchema(t)
+	return name
+}
+
+// structSchema 展开结构体字段，匿名嵌入的结构体（如 gorm.Model）字段合并到外层
+func (s *Spec) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded := s.structSchema(ft)
+				for k, v := range embedded.Properties {
+					schema.Properties[k] = v
+				}
+				schema.Required = append(schema.Required, embedded.Required...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := s.schemaOf(f.Type)
+		if desc := f.Tag.Get("doc"); desc != "" {
+			if prop.Ref != "" {
+				// $ref 不能和其它字段并列，说明写不进去
+				prop = &Schema{Ref: prop.Ref}
+			} else {
+				prop.Description = desc
+			}
+		}
+		if s.applyBinding(prop, f) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+	return schema
+}
+
+// applyBinding 把 binding 标签转换为约束，返回字段是否必填
+func (s *Spec) applyBinding(schema *Schema, f reflect.StructField) bool {
+	tag := f.Tag.Get("binding")
+	if tag == "" || tag == "-" || schema.Ref != "" {
+		return strings.Contains(tag, "required") && !strings.Contains(tag, "required_if")
+	}
+	t := f.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "omitempty":
+			schema.XOmitEmpty = true
+		case "oneof":
+			for _, v := range strings.Fields(arg) {
+				schema.Enum = append(schema.Enum, parseValue(t, v))
+			}
+		case "gt", "gte", "min":
+			setBound(schema, t, arg, true, name == "gt")
+		case "lt", "lte", "max":
+			setBound(schema, t, arg, false, name == "lt")
+		case "len":
+			setBound(schema, t, arg, true, false)
+			setBound(schema, t, arg, false, false)
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		}
+	}
+	return required
+}
+
+// setBound 数字设置 minimum/maximum，字符串设置长度，数组设置元素个数
+func setBound(schema *Schema, t reflect.Type, arg string, lower, exclusive bool) {
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		size := int(n)
+		if exclusive && lower {
+			size++
+		} else if exclusive {
+			size--
+		}
+		target := &schema.MinLength
+		switch {
+		case t.Kind() == reflect.String && !lower:
+			target = &schema.MaxLength
+		case t.Kind() != reflect.String && lower:
+			target = &schema.MinItems
+		case t.Kind() != reflect.String:
+			target = &schema.MaxItems
+		}
+		*target = &size
+	default:
+		if lower {
+			schema.Minimum, schema.ExclusiveMinimum = &n, exclusive
+		} else {
+			schema.Maximum, schema.ExclusiveMaximum = &n, exclusive
+		}
+	}
+}
+
+// parseValue 按字段类型解析 oneof、default 中的值
+func parseValue(t reflect.Type, v string) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
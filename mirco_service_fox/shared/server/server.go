@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"shared/logger"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Options 优雅退出参数
+type Options struct {
+	GracePeriod time.Duration // 等待正在处理的请求结束的最长时间，超时后强制关闭连接
+	DrainDelay  time.Duration // 就绪检查开始失败后继续接受请求的时间，等负载均衡摘掉本实例
+}
+
+// OptionsFromEnv 从 SHUTDOWN_GRACE_PERIOD、SHUTDOWN_DRAIN_DELAY 读取退出参数，默认 20s 和 5s。
+// 两者之和要小于 k8s 的 terminationGracePeriodSeconds
+func OptionsFromEnv() Options {
+	return Options{
+		GracePeriod: durationEnv("SHUTDOWN_GRACE_PERIOD", 20*time.Second),
+		DrainDelay:  durationEnv("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+	}
+}
+
+func durationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		logger.Log.WithFields(logrus.Fields{"key": key, "value": value}).Warn("Invalid duration, using default")
+		return defaultValue
+	}
+	return d
+}
+
+var draining atomic.Bool
+
+// Draining 收到退出信号后返回 true，就绪检查据此返回 503
+func Draining() bool {
+	return draining.Load()
+}
+
+// Run 启动 srv 并阻塞到服务退出。收到 SIGTERM 或 SIGINT 后：
+//  1. Draining 变为 true，就绪检查开始失败，继续正常处理请求 DrainDelay；
+//  2. 停止接受新连接，等待正在处理的请求结束，最多 GracePeriod，超时后强制关闭；
+//  3. 依次执行 cleanups，关闭连接池等资源。
+//
+// 监听失败时直接返回错误
+func Run(srv *http.Server, opts Options, cleanups ...func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sig)
+
+	select {
+	case err := <-errCh:
+		return err
+	case s := <-sig:
+		logger.Log.WithFields(logrus.Fields{
+			"signal":       s.String(),
+			"drain_delay":  opts.DrainDelay.String(),
+			"grace_period": opts.GracePeriod.String(),
+		}).Info("Shutting down, readiness now failing")
+	}
+	draining.Store(true)
+	time.Sleep(opts.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.GracePeriod)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Log.WithError(err).Warn("Grace period expired, closing remaining connections")
+		srv.Close()
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Log.WithError(err).Error("Server stopped with error")
+	}
+
+	for _, cleanup := range cleanups {
+		if err := cleanup(); err != nil {
+			logger.Log.WithError(err).Error("Cleanup failed during shutdown")
+		}
+	}
+	logger.Log.Info("Server stopped")
+	return nil
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 导出方式，由 OTEL_TRACES_EXPORTER 环境变量选择
+const (
+	ExporterOTLP   = "otlp"   // 通过 OTLP/HTTP 发送到 OTEL_EXPORTER_OTLP_ENDPOINT（默认 localhost:4318）
+	ExporterStdout = "stdout" // 输出到标准输出，本地调试用
+	ExporterNone   = "none"   // 关闭导出（默认）
+)
+
+// Init 时设置的服务名，Tracer 使用
+var tracerName string
+
+// Init 初始化全局 TracerProvider 和 W3C traceparent 传播。
+// 即使关闭导出也会设置传播器，保证上游传来的 traceparent 能继续往下游传。
+// 返回的函数在进程退出前调用，把缓存的 span 发送出去。
+func Init(ctx context.Context, service string) (func(context.Context) error, error) {
+	tracerName = service
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch name := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")); name {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout, "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s exporter: %w", service, err)
+	}
+
+	// OTEL_SERVICE_NAME、OTEL_RESOURCE_ATTRIBUTES 可以覆盖默认的服务名
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", service)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Middleware gin 中间件，为每个请求创建服务端 span，探针和指标请求不记录
+func Middleware(service string) gin.HandlerFunc {
+	return otelgin.Middleware(service, otelgin.WithFilter(func(r *http.Request) bool {
+		path := r.URL.Path
+		return path != "/health" && !strings.HasPrefix(path, "/health/") && path != "/live" && path != "/metrics"
+	}))
+}
+
+// Transport 包装出站请求的 RoundTripper，为每次调用创建客户端 span 并注入 traceparent
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
+
+// Client 返回带链路追踪的 http.Client，用于调用其他服务，timeout 为 0 表示不设超时
+func Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: Transport(http.DefaultTransport)}
+}
+
+// Tracer 返回本服务的 Tracer，用于给耗时操作单独记录 span
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
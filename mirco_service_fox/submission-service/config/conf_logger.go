@@ -2,6 +2,7 @@ package config
 
 type Logger struct {
 	Level        string `yaml:"level"`
+	Format       string `yaml:"format"` //json（默认）或 text
 	Prefix       string `yaml:"prefix"`
 	Director     string `yaml:"director"`
 	ShowLine     bool   `yaml:"show_Line"`      //是否显示行号
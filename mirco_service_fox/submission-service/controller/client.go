@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"shared/identity"
+	"shared/logger"
+	"shared/tracing"
+	"time"
+)
+
+// serviceClient 调用其他服务的 /internal 接口时使用，带链路追踪
+var serviceClient = tracing.Client(10 * time.Second)
+
+// judgeClient 调用评测服务时使用。评测要逐个运行测试用例，超时比其他服务长，
+// 但短于网关 student-submit 路由的 60s，评测服务卡住时学生能收到本服务的错误而不是网关的 504
+var judgeClient = tracing.Client(50 * time.Second)
+
+// postJSON 以 JSON 请求体调用评测服务，带上当前请求的请求 ID
+func postJSON(ctx context.Context, url string, body []byte) (*http.Response, error) {
+	req, err := logger.NewRequest(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return judgeClient.Do(req)
+}
+
+// getInternalJSON 调用其他服务的 /internal GET 接口，带上本服务的令牌
+func getInternalJSON(ctx context.Context, url string) (*http.Response, error) {
+	req, err := logger.NewRequest(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	identity.SetServiceCredentials(req)
+	return serviceClient.Do(req)
+}
+
+// postInternalJSON 以 JSON 请求体调用其他服务的 /internal POST 接口，带上本服务的令牌
+func postInternalJSON(ctx context.Context, url string, body []byte) (*http.Response, error) {
+	req, err := logger.NewRequest(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	identity.SetServiceCredentials(req)
+	return serviceClient.Do(req)
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"shared/server"
+	"submission/config"
+	"submission/global"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthCheck 健康检查：正在退出或数据库不可用时返回 503；
+// 判题服务不可用时仍返回 200，但 status 为 degraded，方便网关汇总依赖状态
+func HealthCheck(c *gin.Context) {
+	if server.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+	if global.DB == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": "database not initialized"})
+		return
+	}
+	sqlDB, err := global.DB.DB()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": "database error"})
+		return
+	}
+	if err := sqlDB.Ping(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": "database ping failed"})
+		return
+	}
+
+	judge := judgeHealth()
+	status := "healthy"
+	if judge["status"] != "up" {
+		status = "degraded"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       status,
+		"dependencies": gin.H{"judge": judge},
+	})
+}
+
+// 判题服务探测的超时和结果缓存时间。网关的健康检查超时是 2s，
+// 探测必须远短于它；缓存避免每次健康检查都请求判题服务
+const (
+	judgeProbeTimeout = 500 * time.Millisecond
+	judgeProbeTTL     = 5 * time.Second
+)
+
+var (
+	judgeProbeClient = &http.Client{Timeout: judgeProbeTimeout}
+
+	judgeProbeMu     sync.Mutex
+	judgeProbeResult gin.H
+	judgeProbeAt     time.Time
+)
+
+// judgeHealth 返回判题服务的状态，缓存过期时重新探测，同一时间只有一个请求在探测
+func judgeHealth() gin.H {
+	judgeProbeMu.Lock()
+	defer judgeProbeMu.Unlock()
+	if judgeProbeResult != nil && time.Since(judgeProbeAt) < judgeProbeTTL {
+		return judgeProbeResult
+	}
+
+	judge := gin.H{"status": "up"}
+	start := time.Now()
+	resp, err := judgeProbeClient.Get(config.LoadConfig().JudgeServiceURL + "/health")
+	judge["latency_ms"] = time.Since(start).Milliseconds()
+	if err != nil {
+		judge["status"] = "down"
+		judge["error"] = err.Error()
+	} else {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			judge["status"] = "down"
+			judge["error"] = fmt.Sprintf("status %d", resp.StatusCode)
+		}
+	}
+	judgeProbeResult, judgeProbeAt = judge, time.Now()
+	return judge
+}
@@ -1,11 +1,19 @@
 package controller
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"shared/logger"
+	"shared/tracing"
 	"submission/config"
+	"submission/metrics"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TestCase struct {
@@ -34,15 +42,10 @@ type EvaluationResponse struct {
 	} `json:"summary"`
 }
 
-func getScore(question Question, ans struct {
-	QuestionID string "json:\"question_id\""
-	Type       string "json:\"type\""
-	Answer     string "json:\"answer,omitempty\""
-	Code       string "json:\"code,omitempty\""
-	Language   string "json:\"language,omitempty\""
-}) (int, string) {
+func getScore(ctx context.Context, question Question, ans SubmitAnswer) (int, string) {
 	score := 0
 	feedback := ""
+	metrics.QuestionsGraded.WithLabelValues(question.Type).Inc()
 	switch question.Type {
 	case "choice", "blank":
 		if ans.Answer == question.CorrectAnswer {
@@ -53,8 +56,9 @@ func getScore(question Question, ans struct {
 		}
 	case "code":
 		// 调用评测服务进行代码评测
-		result, err := evaluateCode(ans.Code, ans.Language, question.TestCases)
+		result, err := evaluateCode(ctx, ans.Code, ans.Language, question.TestCases)
 		if err != nil {
+			logger.FromContext(ctx).WithError(err).WithField("question_id", question.ID).Error("代码评测失败")
 			feedback = fmt.Sprintf("Evaluation error: %v", err)
 		} else {
 			score = int(float64(question.Score) * result.Summary.PassRate / 100)
@@ -65,7 +69,23 @@ func getScore(question Question, ans struct {
 }
 
 // evaluateCode 调用评测服务进行代码评测
-func evaluateCode(code, language, testCasesJSON string) (*EvaluationResponse, error) {
+func evaluateCode(ctx context.Context, code, language, testCasesJSON string) (result *EvaluationResponse, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "judge.evaluate", trace.WithAttributes(
+		attribute.String("judge.language", language),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(
+				attribute.Int("judge.passed_cases", result.Summary.PassedCases),
+				attribute.Int("judge.total_cases", result.Summary.TotalCases),
+			)
+		}
+		span.End()
+	}()
+
 	// 解析测试用例
 	var testCases []TestCase
 	if err := json.Unmarshal([]byte(testCasesJSON), &testCases); err != nil {
@@ -87,20 +107,25 @@ func evaluateCode(code, language, testCasesJSON string) (*EvaluationResponse, er
 	cfg := config.LoadConfig()
 	// 调用评测服务
 	judgeURL := cfg.JudgeServiceURL + "/evaluate"
-	resp, err := http.Post(judgeURL, "application/json", bytes.NewBuffer(requestBody))
+	start := time.Now()
+	resp, err := postJSON(ctx, judgeURL, requestBody)
 	if err != nil {
+		metrics.JudgeRequestDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		metrics.JudgeRequestDuration.WithLabelValues("bad_status").Observe(time.Since(start).Seconds())
 		return nil, fmt.Errorf("evaluation service returned status: %d", resp.StatusCode)
 	}
 
 	// 解析响应
 	var response EvaluationResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		metrics.JudgeRequestDuration.WithLabelValues("invalid_response").Observe(time.Since(start).Seconds())
 		return nil, err
 	}
+	metrics.JudgeRequestDuration.WithLabelValues("ok").Observe(time.Since(start).Seconds())
 	return &response, nil
 }
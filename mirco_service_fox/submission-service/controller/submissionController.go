@@ -1,16 +1,17 @@
 package controller
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"shared/identity"
 	"strconv"
 	"strings"
 	"submission/config"
 	"submission/global"
+	"submission/metrics"
 	"submission/models"
 	"time"
 
@@ -19,11 +20,28 @@ import (
 	"gorm.io/gorm"
 )
 
+// SaveAnswerInput 保存的一道题的答案
+type SaveAnswerInput struct {
+	QuestionID string `json:"question_id" binding:"required"`
+	Type       string `json:"type" binding:"required,oneof=choice blank code"`
+	Answer     string `json:"answer" binding:"required_if=Type choice required_if=Type blank"`
+	Code       string `json:"code" binding:"required_if=Type code"`
+	Language   string `json:"language" binding:"required_if=Type code,oneof=cpp java python"`
+}
+
+// SaveAnswerRequest 保存答案的请求结构体
+type SaveAnswerRequest struct {
+	Answers []SaveAnswerInput `json:"answers" binding:"required"`
+}
+
 func SaveAnswer(c *gin.Context) {
-	db := global.DB
+	db := global.DB.WithContext(c.Request.Context())
 	experimentID := c.Param("experiment_id")
-	ID, _ := strconv.ParseUint(c.GetHeader("X-User-ID"), 10, 32)
-	studentID := uint(ID)
+	studentID := identity.UserID(c)
+	if studentID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "请先登录"})
+		return
+	}
 	//studentID1, _ := c.Get("studentID")
 	//转为uint
 	//studentID, err := strconv.ParseUint(fmt.Sprintf("%v", studentID1), 10, 32)
@@ -31,16 +49,7 @@ func SaveAnswer(c *gin.Context) {
 	//	c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Invalid student ID"})
 	//	return
 	//}
-	type Answer struct {
-		QuestionID string `json:"question_id" binding:"required"`
-		Type       string `json:"type" binding:"required,oneof=choice blank code"`
-		Answer     string `json:"answer" binding:"required_if=Type choice required_if=Type blank"`
-		Code       string `json:"code" binding:"required_if=Type code"`
-		Language   string `json:"language" binding:"required_if=Type code,oneof=cpp java python"`
-	}
-	var req struct {
-		Answers []Answer `json:"answers" binding:"required"`
-	}
+	var req SaveAnswerRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Invalid request"})
@@ -53,8 +62,8 @@ func SaveAnswer(c *gin.Context) {
 
 	//验证试验是否存在
 	//localhost:8082/api/experiments/getById/{experiment_id}
-	experimentURL := fmt.Sprintf("%s/api/experiments/getById/%s", cfg.ExperimentServiceURL, experimentID)
-	resp, err := http.Get(experimentURL)
+	experimentURL := fmt.Sprintf("%s/internal/experiments/getById/%s", cfg.ExperimentServiceURL, experimentID)
+	resp, err := getInternalJSON(c.Request.Context(), experimentURL)
 	if err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to verify experiment"})
@@ -125,13 +134,13 @@ func SaveAnswer(c *gin.Context) {
 
 	// 验证题目属于当前实验
 	//localhost:8082/api/experiments/validate-questions
-	validationURL := fmt.Sprintf("%s/api/experiments/validateQuestion", cfg.ExperimentServiceURL)
+	validationURL := fmt.Sprintf("%s/internal/experiments/validQuestion", cfg.ExperimentServiceURL)
 	validationPayload := gin.H{
 		"experiment_id": experimentID,
 		"question_ids":  validQuestionIDs,
 	}
 	payloadBytes, _ := json.Marshal(validationPayload)
-	resp, err = http.Post(validationURL, "application/json", bytes.NewBuffer(payloadBytes))
+	resp, err = postInternalJSON(c.Request.Context(), validationURL, payloadBytes)
 	if err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to validate questions"})
@@ -174,12 +183,12 @@ func SaveAnswer(c *gin.Context) {
 		}
 
 		//localhost:8082/api/experiments/questionDetail
-		questionDetailURL := fmt.Sprintf("%s/experiments/questionDetail", cfg.ExperimentServiceURL)
+		questionDetailURL := fmt.Sprintf("%s/internal/experiments/questionDetail", cfg.ExperimentServiceURL)
 		detailPayload := gin.H{
 			"question_id": ans.QuestionID,
 		}
 		payloadBytes, _ := json.Marshal(detailPayload)
-		resp, err = http.Post(questionDetailURL, "application/json", bytes.NewBuffer(payloadBytes))
+		resp, err = postInternalJSON(c.Request.Context(), questionDetailURL, payloadBytes)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to fetch question details"})
 			return
@@ -339,20 +348,37 @@ type Experiment struct {
 	Title      string    `json:"title"`
 }
 
+// SubmitAnswer 提交的一道题的答案
+type SubmitAnswer struct {
+	QuestionID string `json:"question_id"`
+	Type       string `json:"type"`
+	Answer     string `json:"answer,omitempty"`
+	Code       string `json:"code,omitempty"`
+	Language   string `json:"language,omitempty"`
+}
+
+// SubmitRequest 提交实验的请求结构体
+type SubmitRequest struct {
+	Answers []SubmitAnswer `json:"answers"`
+}
+
 func SubmitExperiment(c *gin.Context) {
-	db := global.DB
-	ID, _ := strconv.ParseUint(c.GetHeader("X-User-ID"), 10, 32)
-	studentID := uint(ID)
+	db := global.DB.WithContext(c.Request.Context())
+	studentID := identity.UserID(c)
+	if studentID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "请先登录"})
+		return
+	}
 	experimentID := c.Param("experiment_id")
 	cfg := config.LoadConfig()
 	// 1. 检查实验是否已过期
 	//localhost:8082/api/experiment/experimentDetail
-	experimentURL := fmt.Sprintf("%s/api/experiments/experimentDetail", cfg.ExperimentServiceURL)
+	experimentURL := fmt.Sprintf("%s/internal/experiments/experimentDetail", cfg.ExperimentServiceURL)
 	payload := gin.H{
 		"experiment_id": experimentID,
 	}
 	payloadBytes, _ := json.Marshal(payload)
-	resp, err := http.Post(experimentURL, "application/json", bytes.NewBuffer(payloadBytes))
+	resp, err := postInternalJSON(c.Request.Context(), experimentURL, payloadBytes)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to fetch experiment details"})
 		return
@@ -388,15 +414,7 @@ func SubmitExperiment(c *gin.Context) {
 		return
 	}
 	// 2. 解析请求体中的答案
-	var req struct {
-		Answers []struct {
-			QuestionID string `json:"question_id"`
-			Type       string `json:"type"`
-			Answer     string `json:"answer,omitempty"`
-			Code       string `json:"code,omitempty"`
-			Language   string `json:"language,omitempty"`
-		} `json:"answers"`
-	}
+	var req SubmitRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Invalid request body"})
@@ -447,13 +465,13 @@ func SubmitExperiment(c *gin.Context) {
 
 	// 验证题目属于当前实验
 	//localhost:8082/api/experiments/validate-questions
-	validationURL := fmt.Sprintf("%s/api/experiments/validateQuestion", cfg.ExperimentServiceURL)
+	validationURL := fmt.Sprintf("%s/internal/experiments/validQuestion", cfg.ExperimentServiceURL)
 	validationPayload := gin.H{
 		"experiment_id": experimentID,
 		"question_ids":  validQuestionIDs,
 	}
 	payloadBytes, _ = json.Marshal(validationPayload)
-	resp, err = http.Post(validationURL, "application/json", bytes.NewBuffer(payloadBytes))
+	resp, err = postInternalJSON(c.Request.Context(), validationURL, payloadBytes)
 	if err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to validate questions"})
@@ -476,12 +494,12 @@ func SubmitExperiment(c *gin.Context) {
 
 	for _, ans := range req.Answers {
 		//localhost:8082/api/experiments/questionDetail
-		questionDetailURL := fmt.Sprintf("%s/api/experiments/questionDetail", cfg.ExperimentServiceURL)
+		questionDetailURL := fmt.Sprintf("%s/internal/experiments/questionDetail", cfg.ExperimentServiceURL)
 		detailPayload := gin.H{
 			"question_id": ans.QuestionID,
 		}
 		payloadBytes, _ := json.Marshal(detailPayload)
-		resp, err = http.Post(questionDetailURL, "application/json", bytes.NewBuffer(payloadBytes))
+		resp, err = postInternalJSON(c.Request.Context(), questionDetailURL, payloadBytes)
 		if err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to fetch question details"})
@@ -519,7 +537,7 @@ func SubmitExperiment(c *gin.Context) {
 				qSubmission.Answer = ""
 			}
 			qSubmission.UpdatedAt = now
-			qSubmission.Score, qSubmission.Feedback = getScore(question, ans)
+			qSubmission.Score, qSubmission.Feedback = getScore(c.Request.Context(), question, ans)
 			totalScore += qSubmission.Score
 			results = append(results, gin.H{
 				"question_id": ans.QuestionID,
@@ -554,7 +572,7 @@ func SubmitExperiment(c *gin.Context) {
 				qSubmission.Code = ans.Code
 				qSubmission.Language = ans.Language
 			}
-			qSubmission.Score, qSubmission.Feedback = getScore(question, ans)
+			qSubmission.Score, qSubmission.Feedback = getScore(c.Request.Context(), question, ans)
 			totalScore += qSubmission.Score
 			results = append(results, gin.H{
 				"question_id": ans.QuestionID,
@@ -583,6 +601,7 @@ func SubmitExperiment(c *gin.Context) {
 		})
 	}
 	tx.Commit()
+	metrics.SubmissionsGraded.Inc()
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
 		"data": gin.H{
@@ -595,9 +614,12 @@ func SubmitExperiment(c *gin.Context) {
 }
 
 func GetSubmissions(c *gin.Context) {
-	db := global.DB
-	ID, _ := strconv.ParseUint(c.GetHeader("X-User-ID"), 10, 32)
-	studentID := uint(ID)
+	db := global.DB.WithContext(c.Request.Context())
+	studentID := identity.UserID(c)
+	if studentID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "请先登录"})
+		return
+	}
 	cfg := config.LoadConfig()
 
 	// 分页参数
@@ -656,12 +678,12 @@ func GetSubmissions(c *gin.Context) {
 	for i, sub := range submissions {
 		//获取Experiment
 		//localhost:8082/api/experiment/experimentDetail
-		experimentURL := fmt.Sprintf("%s/api/experiments/experimentDetail", cfg.ExperimentServiceURL)
+		experimentURL := fmt.Sprintf("%s/internal/experiments/experimentDetail", cfg.ExperimentServiceURL)
 		payload := gin.H{
 			"experiment_id": experimentID,
 		}
 		payloadBytes, _ := json.Marshal(payload)
-		resp, err := http.Post(experimentURL, "application/json", bytes.NewBuffer(payloadBytes))
+		resp, err := postInternalJSON(c.Request.Context(), experimentURL, payloadBytes)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to fetch experiment details"})
 			return
@@ -685,12 +707,12 @@ func GetSubmissions(c *gin.Context) {
 			for _, qs := range qSubs {
 				//获取Question
 				//localhost:8082/api/experiments/questionDetail
-				questionDetailURL := fmt.Sprintf("%s/api/experiments/questionDetail", cfg.ExperimentServiceURL)
+				questionDetailURL := fmt.Sprintf("%s/internal/experiments/questionDetail", cfg.ExperimentServiceURL)
 				detailPayload := gin.H{
 					"question_id": qs.QuestionID,
 				}
 				payloadBytes, _ := json.Marshal(detailPayload)
-				resp, err = http.Post(questionDetailURL, "application/json", bytes.NewBuffer(payloadBytes))
+				resp, err = postInternalJSON(c.Request.Context(), questionDetailURL, payloadBytes)
 				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to fetch question details"})
 					return
@@ -747,7 +769,7 @@ func GetSubmissions(c *gin.Context) {
 
 func GetSubmissionStatus(c *gin.Context) {
 	// 查询学生的提交状态
-	db := global.DB
+	db := global.DB.WithContext(c.Request.Context())
 	experimentID := c.Param("experiment_id")
 	studentID := c.Param("student_id")
 	var submission models.ExperimentSubmission
@@ -788,7 +810,7 @@ func GetStudentAns(c *gin.Context) {
 		return
 	}
 
-	if err := global.DB.Where("submission_id = ? AND question_id = ?", q.SubId, q.QId).
+	if err := global.DB.WithContext(c.Request.Context()).Where("submission_id = ? AND question_id = ?", q.SubId, q.QId).
 		First(&qSubmission).Error; err == nil {
 	} else {
 		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "Submission or question not found"})
@@ -801,7 +823,7 @@ func GetStudentAns(c *gin.Context) {
 }
 
 func UpdateExperimentStatusToInProgress(c *gin.Context) {
-	db := global.DB
+	db := global.DB.WithContext(c.Request.Context())
 	experimentID := c.Param("experiment_id")
 	//是否存在该实验
 	var experiment models.ExperimentSubmission
@@ -828,7 +850,7 @@ func UpdateExperimentStatusToInProgress(c *gin.Context) {
 
 // DeleteExperimentSubmissions 删除实验相关的所有提交记录
 func DeleteExperimentSubmissions(c *gin.Context) {
-	db := global.DB
+	db := global.DB.WithContext(c.Request.Context())
 	experimentID := c.Param("experiment_id")
 
 	if experimentID == "" {
@@ -883,3 +905,42 @@ func DeleteExperimentSubmissions(c *gin.Context) {
 		"message": "实验提交记录删除成功",
 	})
 }
+
+// GradeRecord 成绩导出中一个学生的成绩
+type GradeRecord struct {
+	StudentID    uint      `json:"student_id"`
+	SubmissionID string    `json:"submission_id"`
+	TotalScore   int       `json:"total_score"`
+	SubmittedAt  time.Time `json:"submitted_at"`
+}
+
+// GetExperimentGrades 导出实验中已提交学生的成绩，按学生 ID 排序，供教师和导出成绩的脚本使用
+func GetExperimentGrades(c *gin.Context) {
+	db := global.DB.WithContext(c.Request.Context())
+	experimentID := c.Param("experiment_id")
+
+	var submissions []models.ExperimentSubmission
+	if err := db.Where("experiment_id = ? AND status = ?", experimentID, "submitted").
+		Order("student_id").
+		Find(&submissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Database query failed"})
+		return
+	}
+
+	grades := make([]GradeRecord, len(submissions))
+	for i, sub := range submissions {
+		grades[i] = GradeRecord{
+			StudentID:    sub.StudentID,
+			SubmissionID: sub.ID,
+			TotalScore:   sub.TotalScore,
+			SubmittedAt:  sub.SubmittedAt,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"experiment_id": experimentID,
+			"grades":        grades,
+		},
+	})
+}
@@ -23,7 +23,6 @@ func InitConf() {
 		panic(fmt.Errorf("解析配置文件失败: %s", err))
 	}
 	log.Println("配置文件读取成功")
-	fmt.Println(c)
 	// 将配置文件内容赋值给全局变量
 	global.Config = c
 }
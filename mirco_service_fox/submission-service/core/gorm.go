@@ -10,6 +10,7 @@ import (
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	otelgorm "gorm.io/plugin/opentelemetry/tracing"
 )
 
 func InitGorm() *gorm.DB {
@@ -69,6 +70,10 @@ func InitGorm() *gorm.DB {
 	//if err != nil {
 	//	global.Log.Fatalf("%s", fmt.Sprintf("连接mysql数据库失败: %s", dsn))
 	//}
+	// 每条 SQL 记录一个 span，挂在请求的链路下（需要 db.WithContext）
+	if err := db.Use(otelgorm.NewPlugin(otelgorm.WithoutMetrics())); err != nil {
+		global.Log.Warnf("注册 GORM 链路追踪插件失败: %v", err)
+	}
 	sqlDB, _ := db.DB()
 	sqlDB.SetMaxIdleConns(10)               //设置连接池的空闲连接数
 	sqlDB.SetMaxOpenConns(100)              //设置连接池的最大连接数
@@ -1,6 +1,12 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"shared/identity"
+	"shared/logger"
+	"shared/server"
+	"shared/tracing"
 	"submission/core"
 	"submission/global"
 	"submission/routers"
@@ -11,9 +17,41 @@ func main() {
 	core.InitConf()
 	// 初始化日志
 	global.Log = core.InitLogger()
+	logger.Log = global.Log
+	// 初始化链路追踪
+	shutdownTracing, err := tracing.Init(context.Background(), "submission-service")
+	if err != nil {
+		global.Log.Fatalf("初始化链路追踪失败: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+	// 内部身份断言的签名密钥，没有时无法识别用户，直接退出
+	if err := identity.Init(); err != nil {
+		global.Log.Fatalf("初始化身份校验失败: %v", err)
+	}
+	// 调用实验服务内部接口的令牌，以及允许调用本服务内部接口的实验服务令牌
+	if err := identity.InitServiceToken("submission-service"); err != nil {
+		global.Log.Fatalf("初始化服务令牌失败: %v", err)
+	}
+	if err := identity.AllowCallers("experiment-service"); err != nil {
+		global.Log.Fatalf("初始化服务令牌失败: %v", err)
+	}
 	//连接数据库
 	global.DB = core.InitGorm()
 	router := routers.InitRouter()
 
-	router.Run(global.Config.System.Addr()) // listen and serve on
+	// 收到 SIGTERM 后等正在处理的请求结束，再关闭数据库连接池
+	srv := &http.Server{Addr: global.Config.System.Addr(), Handler: router}
+	opts := server.Options{GracePeriod: global.Config.System.GracePeriod(), DrainDelay: global.Config.System.DrainDelay()}
+	if err := server.Run(srv, opts, closeDB); err != nil {
+		global.Log.Fatalf("启动服务失败: %v", err)
+	}
+}
+
+// closeDB 关闭数据库连接池
+func closeDB() error {
+	sqlDB, err := global.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// SubmissionsGraded 完成评分的实验提交数
+	SubmissionsGraded = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "submissions_graded_total",
+		Help: "Number of experiment submissions graded.",
+	})
+
+	// QuestionsGraded 完成评分的题目数，按题型区分
+	QuestionsGraded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "questions_graded_total",
+		Help: "Number of questions graded, by question type.",
+	}, []string{"type"})
+
+	// JudgeRequestDuration 调用判题服务的耗时，outcome 为 ok / error / bad_status / invalid_response
+	JudgeRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "judge_request_duration_seconds",
+		Help:    "Latency of calls to the judge service, by outcome.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"outcome"})
+)
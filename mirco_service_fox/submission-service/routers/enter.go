@@ -1,8 +1,13 @@
 package routers
 
 import (
-	"submission/global"
 	"net/http"
+	"shared/identity"
+	"shared/logger"
+	"shared/metrics"
+	"shared/tracing"
+	"submission/controller"
+	"submission/global"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -10,21 +15,32 @@ import (
 
 func InitRouter() *gin.Engine {
 	gin.SetMode(global.Config.System.Env)
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), tracing.Middleware("submission-service"), logger.Middleware(), identity.Middleware())
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},                   // 允许前端源
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},            // 允许的 HTTP 方法
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"}, // 允许的请求头
-		ExposeHeaders:    []string{"Content-Length"},                          // 暴露的响应头
-		AllowCredentials: true,                                                // 允许发送 cookie 或认证信息
-		MaxAge:           12 * 60 * 60,                                        // 预检请求缓存时间（秒）
+		AllowOrigins:     []string{"http://localhost:3000"},                                   // 允许前端源
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},                            // 允许的 HTTP 方法
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Request-ID"}, // 允许的请求头
+		ExposeHeaders:    []string{"Content-Length", "X-Request-ID"},                          // 暴露的响应头
+		AllowCredentials: true,                                                                // 允许发送 cookie 或认证信息
+		MaxAge:           12 * 60 * 60,                                                        // 预检请求缓存时间（秒）
 	}))
+	router.Use(metrics.Middleware())
 	submission(router)
-	
-	// 添加健康检查端点
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
+
+	// 轻量活性探针：不依赖数据库，仅用于判断进程存活
+	router.GET("/live", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "alive"})
 	})
-	
+	// 添加健康检查端点
+	router.GET("/health", controller.HealthCheck)
+	router.GET("/metrics", metrics.Handler())
+	// 接口文档，网关合并各服务的文档后对外提供
+	spec := Spec()
+	router.GET("/openapi.json", spec.Handler())
+	for _, route := range spec.Missing(router.Routes()) {
+		global.Log.Warnf("接口 %s 没有写入 OpenAPI 文档", route)
+	}
+
 	return router
 }
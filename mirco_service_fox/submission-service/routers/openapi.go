@@ -0,0 +1,121 @@
+package routers
+
+import (
+	"net/http"
+	"shared/openapi"
+	"submission/controller"
+	"time"
+)
+
+// 下面的结构体只用于生成文档，描述接口中用 gin.H 拼出的请求参数和响应
+
+type savedQuestion struct {
+	QuestionID           string `json:"question_id"`
+	QuestionSubmissionID string `json:"question_submission_id"`
+	UpdatedAt            string `json:"updated_at" doc:"RFC3339 时间"`
+}
+
+type savedAnswers struct {
+	SubmissionID   string          `json:"submission_id"`
+	StudentID      uint            `json:"student_id"`
+	ExperimentID   string          `json:"experiment_id"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	SavedQuestions []savedQuestion `json:"saved_questions" doc:"本次和之前保存过的全部题目"`
+}
+
+type saveResult struct {
+	Status string       `json:"status"`
+	Data   savedAnswers `json:"data"`
+}
+
+type questionResult struct {
+	QuestionID string `json:"question_id"`
+	Type       string `json:"type"`
+	Score      string `json:"score" doc:"得分/满分"`
+	Feedback   string `json:"feedback"`
+}
+
+type submitted struct {
+	SubmissionID string           `json:"submission_id"`
+	TotalScore   string           `json:"total_score" doc:"得分/满分"`
+	Results      []questionResult `json:"results"`
+	SubmittedAt  time.Time        `json:"submitted_at"`
+}
+
+type submitResult struct {
+	Status string    `json:"status"`
+	Data   submitted `json:"data"`
+}
+
+type submissionQuery struct {
+	Page         int    `form:"page,default=1"`
+	Limit        int    `form:"limit,default=10"`
+	ExperimentID string `form:"experiment_id" doc:"只看这个实验的提交"`
+}
+
+type gradedQuestion struct {
+	QuestionID  string `json:"question_id"`
+	Type        string `json:"type"`
+	Score       int    `json:"score"`
+	Feedback    string `json:"feedback"`
+	Explanation string `json:"explanation" doc:"实验截止后才返回"`
+}
+
+type submissionRecord struct {
+	SubmissionID    string           `json:"submission_id"`
+	ExperimentID    string           `json:"experiment_id"`
+	ExperimentTitle string           `json:"experiment_title"`
+	TotalScore      int              `json:"total_score"`
+	Status          string           `json:"status"`
+	SubmittedAt     string           `json:"submitted_at" doc:"RFC3339 时间"`
+	Results         []gradedQuestion `json:"results"`
+}
+
+type pagination struct {
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"`
+	Total int64 `json:"total"`
+}
+
+type submissionList struct {
+	Status     string             `json:"status"`
+	Data       []submissionRecord `json:"data"`
+	Pagination pagination         `json:"pagination"`
+}
+
+type gradeExport struct {
+	ExperimentID string                   `json:"experiment_id"`
+	Grades       []controller.GradeRecord `json:"grades" doc:"已提交的学生，按学生 ID 排序"`
+}
+
+type gradeList struct {
+	Status string      `json:"status"`
+	Data   gradeExport `json:"data"`
+}
+
+// Spec 生成本服务对外接口的 OpenAPI 文档，修改接口时同步更新
+func Spec() *openapi.Spec {
+	spec := openapi.New("submission-service", "1.0.0")
+	params := map[string]string{"experiment_id": "实验 ID"}
+
+	spec.Add(openapi.Route{
+		Method: http.MethodPost, Path: "/api/student/experiments/:experiment_id/save", Tag: "student",
+		Summary: "保存答案", Description: "可以多次保存，同一道题以最后一次为准",
+		Params: params, Body: controller.SaveAnswerRequest{}, Response: saveResult{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodPost, Path: "/api/student/experiments/:experiment_id/submit", Tag: "student",
+		Summary: "提交实验", Description: "提交后立即评分，代码题调用评测服务运行测试用例",
+		Params: params, Body: controller.SubmitRequest{}, Response: submitResult{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/student/submissions", Tag: "student",
+		Summary: "学生的提交记录", Query: submissionQuery{}, Response: submissionList{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/teacher/experiments/:experiment_id/grades", Tag: "teacher",
+		Summary: "导出实验成绩", Description: "只包含已提交的学生",
+		Params: params, Response: gradeList{},
+	})
+	return spec
+}
@@ -1,6 +1,7 @@
 package routers
 
 import (
+	"shared/identity"
 	"submission/controller"
 
 	"github.com/gin-gonic/gin"
@@ -11,10 +12,17 @@ func submission(r *gin.Engine) *gin.Engine {
 	s.POST("/experiments/:experiment_id/save", controller.SaveAnswer)
 	s.POST("/experiments/:experiment_id/submit", controller.SubmitExperiment)
 	s.GET("/submissions", controller.GetSubmissions)
-	s.GET("/submissions/:experiment_id/:student_id/status", controller.GetSubmissionStatus)
-	s.POST("/submissions/:submission_id/GetStudentAns", controller.GetStudentAns)
-	s.PUT("/submissions/:experiment_id/UpdateExperimentStatus", controller.UpdateExperimentStatusToInProgress)
-	s.DELETE("/experiments/:experiment_id/submissions", controller.DeleteExperimentSubmissions)
+
+	// 教师导出成绩，也可以用带 grades:read 权限的 API Key 调用
+	teacher := r.Group("/api/teacher", identity.RequireRole("teacher"))
+	teacher.GET("/experiments/:experiment_id/grades", controller.GetExperimentGrades)
+
+	// 实验服务调用的内部接口，网关不对外暴露
+	internal := r.Group("/internal", identity.RequireService())
+	internal.GET("/submissions/:experiment_id/:student_id/status", controller.GetSubmissionStatus)
+	internal.POST("/submissions/:submission_id/GetStudentAns", controller.GetStudentAns)
+	internal.PUT("/submissions/:experiment_id/UpdateExperimentStatus", controller.UpdateExperimentStatusToInProgress)
+	internal.DELETE("/experiments/:experiment_id/submissions", controller.DeleteExperimentSubmissions)
 
 	return r
 }
@@ -0,0 +1,154 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"lh/models"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix API Key 明文的固定前缀，方便在日志和代码仓库中扫描泄露的 Key
+const APIKeyPrefix = "sfk_"
+
+// API Key 可以申请的权限范围，网关路由表中每条路由声明读写分别需要哪个范围
+var APIKeyScopes = map[string]string{
+	"experiments:read":   "查看实验",
+	"experiments:write":  "创建、修改、删除实验",
+	"groups:read":        "查看学生和分组",
+	"groups:write":       "修改分组",
+	"notifications:read": "查看通知",
+	"grades:read":        "导出成绩",
+}
+
+const (
+	// MaxActiveAPIKeys 每个用户同时有效的 API Key 数量上限
+	MaxActiveAPIKeys = 20
+	// 最近使用时间的更新间隔，避免每个请求都写数据库
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyInvalid = errors.New("api key invalid")
+	ErrAPIKeyExpired = errors.New("api key expired")
+	ErrAPIKeyRevoked = errors.New("api key revoked")
+	ErrAPIKeyScope   = errors.New("api key scope invalid")
+	ErrAPIKeyLimit   = errors.New("too many active api keys")
+)
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeScopes 去重排序，包含未知范围或为空时返回 ErrAPIKeyScope
+func NormalizeScopes(scopes []string) ([]string, error) {
+	set := make(map[string]bool, len(scopes))
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		if _, ok := APIKeyScopes[s]; !ok {
+			return nil, ErrAPIKeyScope
+		}
+		set[s] = true
+	}
+	if len(set) == 0 {
+		return nil, ErrAPIKeyScope
+	}
+	result := make([]string, 0, len(set))
+	for s := range set {
+		result = append(result, s)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// APIKeyScopeList 返回 Key 的权限范围
+func APIKeyScopeList(key models.APIKey) []string {
+	return strings.Fields(key.Scopes)
+}
+
+// CreateAPIKey 生成 API Key，返回明文和保存的记录，scopes 需已经过 NormalizeScopes
+func CreateAPIKey(db *gorm.DB, userID uint, name string, scopes []string, expiresAt time.Time) (string, models.APIKey, error) {
+	var active int64
+	if err := db.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Count(&active).Error; err != nil {
+		return "", models.APIKey{}, err
+	}
+	if active >= MaxActiveAPIKeys {
+		return "", models.APIKey{}, ErrAPIKeyLimit
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", models.APIKey{}, err
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	record := models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:len(APIKeyPrefix)+8],
+		KeyHash:   hashAPIKey(key),
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: expiresAt,
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return "", models.APIKey{}, err
+	}
+	return key, record, nil
+}
+
+// VerifyAPIKey 校验 API Key，返回所属用户和 Key 记录，并更新最近使用时间
+func VerifyAPIKey(db *gorm.DB, key string) (models.User, models.APIKey, error) {
+	var record models.APIKey
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return models.User{}, record, ErrAPIKeyInvalid
+	}
+	err := db.Where("key_hash = ?", hashAPIKey(key)).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.User{}, record, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return models.User{}, record, err
+	}
+	now := time.Now()
+	if record.RevokedAt != nil {
+		return models.User{}, record, ErrAPIKeyRevoked
+	}
+	if !now.Before(record.ExpiresAt) {
+		return models.User{}, record, ErrAPIKeyExpired
+	}
+	var user models.User
+	if err := db.First(&user, record.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.User{}, record, ErrAPIKeyInvalid
+		}
+		return models.User{}, record, err
+	}
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= apiKeyTouchInterval {
+		if err := db.Model(&record).Update("last_used_at", now).Error; err != nil {
+			return models.User{}, record, err
+		}
+	}
+	return user, record, nil
+}
+
+// RevokeAPIKey 作废用户自己的 API Key，Key 不存在或不属于该用户时返回 ErrAPIKeyInvalid
+func RevokeAPIKey(db *gorm.DB, userID, keyID uint) error {
+	result := db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyInvalid
+	}
+	return nil
+}
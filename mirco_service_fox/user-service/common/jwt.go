@@ -1,47 +1,45 @@
 package common
 
 import (
+	"lh/global"
 	"lh/models"
-	"os"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 )
 
-// jwt加密密钥
-func getJWTKey() []byte {
-	key := os.Getenv("JWT_SECRET")
-	if key == "" {
-		return []byte("a_secret_crect") // 默认密钥，生产环境不建议使用默认值
-	}
-	return []byte(key)
-}
-
-// Claims token的claim
+// Claims token的claim，字段名与网关解析时使用的一致
 type Claims struct {
-	UserID uint
-	Role   string
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role"`
+	//毫秒精度的签发时间，网关注销用户全部令牌时据此区分同一秒内注销前后签发的令牌
+	IssuedAtMs int64 `json:"iat_ms"`
 	jwt.StandardClaims
 }
 
-// 发放token
+// 发放访问令牌
 func ReleaseToken(user models.User) (string, error) {
 
-	//token的有效期
-	expirationTime := time.Now().Add(7 * 24 * time.Hour)
+	//token的有效期，访问令牌短期有效，过期后用刷新令牌换新的
+	now := time.Now()
+	expirationTime := now.Add(global.Config.Auth.AccessTTL())
 
 	claims := &Claims{
 
 		//自定义字段
-		UserID: user.ID,
-		Role:   user.Role,
+		UserID:     user.ID,
+		Role:       user.Role,
+		IssuedAtMs: now.UnixMilli(),
 		//标准字段
 		StandardClaims: jwt.StandardClaims{
 
+			//令牌ID，网关按它注销单个令牌
+			Id: uuid.New().String(),
 			//过期时间
 			ExpiresAt: expirationTime.Unix(),
 			//发放的时间
-			IssuedAt: time.Now().Unix(),
+			IssuedAt: now.Unix(),
 			//发放者
 			Issuer: "127.0.0.1",
 			//主题
@@ -49,9 +47,13 @@ func ReleaseToken(user models.User) (string, error) {
 		},
 	}
 
-	//使用jwt密钥生成token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(getJWTKey())
+	//使用当前签名密钥生成token，kid 告诉网关用哪个公钥校验
+	if activeKey == nil {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(activeKey.method, claims)
+	token.Header["kid"] = activeKey.kid
+	tokenString, err := token.SignedString(activeKey.private)
 
 	if err != nil {
 		return "", err
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"lh/global"
+	"lh/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token invalid")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	// ErrRefreshTokenReused 已轮换或已作废的令牌再次出现，整个家族已作废
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ReleaseRefreshToken 发放刷新令牌，familyID 为空时开始一个新的家族（即一次新的登录）
+func ReleaseRefreshToken(db *gorm.DB, userID uint, familyID string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+	record := models.RefreshToken{
+		TokenHash: hashRefreshToken(token),
+		UserID:    userID,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(global.Config.Auth.RefreshTTL()),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken 用刷新令牌换一个新的刷新令牌，旧令牌标记为已使用。
+// 已使用或已作废的令牌再次出现时作废整个家族并返回 ErrRefreshTokenReused。
+func RotateRefreshToken(db *gorm.DB, token string) (models.User, string, error) {
+	var user models.User
+	var newToken string
+	reused := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var record models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashRefreshToken(token)).
+			First(&record).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if record.UsedAt != nil || record.RevokedAt != nil {
+			// 在事务中作废家族，返回 nil 让作废操作提交
+			reused = true
+			return revokeFamily(tx, record.FamilyID, now)
+		}
+		if now.After(record.ExpiresAt) {
+			return ErrRefreshTokenExpired
+		}
+		if err := tx.Model(&record).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&user, record.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+		newToken, err = ReleaseRefreshToken(tx, record.UserID, record.FamilyID)
+		return err
+	})
+	if err != nil {
+		return models.User{}, "", err
+	}
+	if reused {
+		return models.User{}, "", ErrRefreshTokenReused
+	}
+	return user, newToken, nil
+}
+
+// RevokeRefreshToken 作废刷新令牌所在的整个家族，用于退出登录，令牌不存在时忽略
+func RevokeRefreshToken(db *gorm.DB, token string) error {
+	var record models.RefreshToken
+	err := db.Where("token_hash = ?", hashRefreshToken(token)).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return revokeFamily(db, record.FamilyID, time.Now())
+}
+
+// RevokeUserRefreshTokens 作废用户的全部刷新令牌，用于修改密码或管理员强制下线
+func RevokeUserRefreshTokens(db *gorm.DB, userID uint) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func revokeFamily(db *gorm.DB, familyID string, now time.Time) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
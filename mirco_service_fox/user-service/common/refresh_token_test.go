@@ -0,0 +1,118 @@
+package common
+
+import (
+	"errors"
+	"lh/config"
+	"lh/global"
+	"lh/models"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 每个测试使用独立的内存数据库
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存数据库只在一个连接内有效
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.User{}, &models.RefreshToken{}); err != nil {
+		t.Fatal(err)
+	}
+	if global.Config == nil {
+		global.Config = &config.Config{}
+	}
+	return db
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	// 每一步用 use 指定的令牌（0 为登录时发放的，n 为第 n 次轮换得到的）调用 RotateRefreshToken
+	tests := []struct {
+		name  string
+		steps []int
+		// 每一步的期望结果，nil 表示轮换成功
+		want []error
+		// 最后一步之后所有令牌是否都已作废
+		familyRevoked bool
+	}{
+		{"rotate twice", []int{0, 1}, []error{nil, nil}, false},
+		{"reuse of the login token", []int{0, 0}, []error{nil, ErrRefreshTokenReused}, true},
+		{"reuse after later rotations", []int{0, 1, 0}, []error{nil, nil, ErrRefreshTokenReused}, true},
+		{"newest token is dead after reuse", []int{0, 0, 1}, []error{nil, ErrRefreshTokenReused, ErrRefreshTokenReused}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			user := models.User{Name: "alice", Telephone: "13800000000", Password: "x", Role: "student"}
+			db.Create(&user)
+			login, err := ReleaseRefreshToken(db, user.ID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			issued := []string{login}
+			for i, use := range tt.steps {
+				got, next, err := RotateRefreshToken(db, issued[use])
+				if !errors.Is(err, tt.want[i]) {
+					t.Fatalf("step %d: RotateRefreshToken(token %d) error = %v, want %v", i, use, err, tt.want[i])
+				}
+				if err != nil {
+					continue
+				}
+				if got.ID != user.ID || next == "" || next == issued[use] {
+					t.Fatalf("step %d: RotateRefreshToken() = user %d, token %q", i, got.ID, next)
+				}
+				issued = append(issued, next)
+			}
+
+			var active int64
+			db.Model(&models.RefreshToken{}).Where("revoked_at IS NULL").Count(&active)
+			if revoked := active == 0; revoked != tt.familyRevoked {
+				t.Errorf("%d tokens still active, want family revoked = %v", active, tt.familyRevoked)
+			}
+		})
+	}
+}
+
+func TestRotateRefreshTokenRejects(t *testing.T) {
+	db := newTestDB(t)
+	user := models.User{Name: "bob", Telephone: "13800000001", Password: "x", Role: "student"}
+	db.Create(&user)
+
+	expired, _ := ReleaseRefreshToken(db, user.ID, "")
+	db.Model(&models.RefreshToken{}).Where("token_hash = ?", hashRefreshToken(expired)).
+		Update("expires_at", time.Now().Add(-time.Minute))
+	loggedOut, _ := ReleaseRefreshToken(db, user.ID, "")
+	if err := RevokeRefreshToken(db, loggedOut); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := ReleaseRefreshToken(db, user.ID, "")
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"unknown token", "not-a-token", ErrRefreshTokenInvalid},
+		{"expired token", expired, ErrRefreshTokenExpired},
+		{"token after logout", loggedOut, ErrRefreshTokenReused},
+		{"other login unaffected", other, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := RotateRefreshToken(db, tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("RotateRefreshToken() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"lh/config"
+	"math/big"
+	"os"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ErrNoSigningKey 没有配置令牌签名密钥
+var ErrNoSigningKey = errors.New("no jwt signing key configured")
+
+// signingKey 已加载的签名密钥
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+var (
+	signingKeys []*signingKey
+	activeKey   *signingKey
+)
+
+// LoadSigningKeys 读取配置中的全部签名密钥，active_kid 指定的密钥用于签发新令牌，
+// 其余密钥只在 JWKS 中发布，供网关校验轮换前签发的令牌
+func LoadSigningKeys(cfg config.Auth) error {
+	if len(cfg.SigningKeys) == 0 {
+		return ErrNoSigningKey
+	}
+	keys := make([]*signingKey, 0, len(cfg.SigningKeys))
+	seen := make(map[string]bool, len(cfg.SigningKeys))
+	for _, k := range cfg.SigningKeys {
+		if k.Kid == "" {
+			return fmt.Errorf("signing key %s: kid is required", k.PrivateKeyFile)
+		}
+		if seen[k.Kid] {
+			return fmt.Errorf("signing key %s: duplicate kid", k.Kid)
+		}
+		seen[k.Kid] = true
+		data, err := os.ReadFile(k.PrivateKeyFile)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", k.Kid, err)
+		}
+		key, err := parseSigningKey(k.Kid, data)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", k.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+
+	activeKid := cfg.ActiveKid
+	if activeKid == "" {
+		activeKid = keys[0].kid
+	}
+	for _, k := range keys {
+		if k.kid == activeKid {
+			signingKeys, activeKey = keys, k
+			return nil
+		}
+	}
+	return fmt.Errorf("active_kid %s is not in signing_keys", activeKid)
+}
+
+// parseSigningKey 解析 PEM 格式的私钥，按密钥类型确定签名算法
+func parseSigningKey(kid string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	var (
+		key interface{}
+		err error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key must be at least 2048 bits")
+		}
+		return &signingKey{kid: kid, method: jwt.SigningMethodRS256, private: k, public: &k.PublicKey}, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("only P-256 ECDSA keys are supported")
+		}
+		return &signingKey{kid: kid, method: jwt.SigningMethodES256, private: k, public: &k.PublicKey}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// JWK 公钥的 JSON Web Key 表示（RFC 7517）
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet 发布给网关的公钥集合
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS 返回全部签名密钥的公钥
+func PublicJWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(signingKeys))}
+	for _, k := range signingKeys {
+		jwk := JWK{Kid: k.kid, Use: "sig", Alg: k.method.Alg()}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			jwk.Kty = "EC"
+			jwk.Crv = "P-256"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32)))
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
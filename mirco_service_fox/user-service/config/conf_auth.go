@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+type Auth struct {
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`  //访问令牌有效期，默认15分钟
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"` //刷新令牌有效期，默认7天
+	ActiveKid       string        `yaml:"active_kid"`        //签发新令牌使用的密钥，默认第一个
+	SigningKeys     []SigningKey  `yaml:"signing_keys"`      //签名密钥，轮换期间同时保留新旧密钥
+}
+
+// SigningKey 令牌签名密钥，支持 RSA（RS256）和 P-256 ECDSA（ES256）私钥
+type SigningKey struct {
+	Kid            string `yaml:"kid"`
+	PrivateKeyFile string `yaml:"private_key_file"` //PEM 格式的私钥文件
+}
+
+// AccessTTL 访问令牌有效期
+func (a Auth) AccessTTL() time.Duration {
+	if a.AccessTokenTTL > 0 {
+		return a.AccessTokenTTL
+	}
+	return 15 * time.Minute
+}
+
+// RefreshTTL 刷新令牌有效期
+func (a Auth) RefreshTTL() time.Duration {
+	if a.RefreshTokenTTL > 0 {
+		return a.RefreshTokenTTL
+	}
+	return 7 * 24 * time.Hour
+}
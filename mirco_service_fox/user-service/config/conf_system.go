@@ -1,13 +1,34 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type System struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
-	Env  string `yaml:"env"`
+	Host                string        `yaml:"host"`
+	Port                int           `yaml:"port"`
+	Env                 string        `yaml:"env"`
+	ShutdownGracePeriod time.Duration `yaml:"shutdown_grace_period"` //退出时等待请求结束的最长时间，默认20秒
+	ShutdownDrainDelay  time.Duration `yaml:"shutdown_drain_delay"`  //退出时就绪检查失败后继续接受请求的时间，默认5秒
 }
 
 func (s System) Addr() string {
 	return fmt.Sprintf("%s:%d", s.Host, s.Port)
 }
+
+// GracePeriod 退出时等待请求结束的最长时间
+func (s System) GracePeriod() time.Duration {
+	if s.ShutdownGracePeriod > 0 {
+		return s.ShutdownGracePeriod
+	}
+	return 20 * time.Second
+}
+
+// DrainDelay 退出时就绪检查失败后继续接受请求的时间，设为负数时不等待
+func (s System) DrainDelay() time.Duration {
+	if s.ShutdownDrainDelay != 0 {
+		return max(s.ShutdownDrainDelay, 0)
+	}
+	return 5 * time.Second
+}
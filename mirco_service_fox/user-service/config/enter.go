@@ -4,4 +4,5 @@ type Config struct {
 	Mysql  Mysql  `yaml:"mysql"`
 	Logger Logger `yaml:"logger"`
 	System System `yaml:"system"`
+	Auth   Auth   `yaml:"auth"`
 }
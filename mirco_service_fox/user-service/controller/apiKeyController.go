@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"errors"
+	"lh/common"
+	"lh/models"
+	"net/http"
+	"shared/identity"
+	"shared/logger"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAPIKeyDays = 90
+	maxAPIKeyDays     = 365
+)
+
+// CreateAPIKeyRequest 创建 API Key 的请求结构体
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"` //有效天数，默认90天，最长365天
+}
+
+type verifyAPIKeyRequest struct {
+	Key string `json:"key"`
+}
+
+func apiKeyResponse(key models.APIKey) gin.H {
+	return gin.H{
+		"id":           key.ID,
+		"name":         key.Name,
+		"prefix":       key.Prefix,
+		"scopes":       common.APIKeyScopeList(key),
+		"expires_at":   key.ExpiresAt,
+		"last_used_at": key.LastUsedAt,
+		"revoked_at":   key.RevokedAt,
+		"created_at":   key.CreatedAt,
+	}
+}
+
+// GetAPIKeyScopes 列出可以申请的权限范围
+func GetAPIKeyScopes(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": common.APIKeyScopes,
+	})
+}
+
+// CreateAPIKey 为当前教师创建 API Key，明文只在这里返回一次
+func CreateAPIKey(ctx *gin.Context) {
+	if identity.Role(ctx) != "teacher" {
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"code":    403,
+			"message": "只有教师可以创建 API Key",
+		})
+		return
+	}
+	var req CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len([]rune(req.Name)) > 64 {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    422,
+			"message": "名称不能为空且不超过64个字符",
+		})
+		return
+	}
+	scopes, err := common.NormalizeScopes(req.Scopes)
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    422,
+			"message": "权限范围为空或不存在",
+		})
+		return
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultAPIKeyDays
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAPIKeyDays {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    422,
+			"message": "有效天数必须在1到365之间",
+		})
+		return
+	}
+
+	userID := identity.UserID(ctx)
+	db := common.GetDB().WithContext(ctx.Request.Context())
+	expiresAt := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+	key, record, err := common.CreateAPIKey(db, userID, req.Name, scopes, expiresAt)
+	if errors.Is(err, common.ErrAPIKeyLimit) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    422,
+			"message": "有效的 API Key 数量已达上限，请先作废不用的 Key",
+		})
+		return
+	}
+	if err != nil {
+		logger.FromContext(ctx.Request.Context()).WithError(err).Error("api key create error")
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "系统异常",
+		})
+		return
+	}
+	logger.FromContext(ctx.Request.Context()).WithFields(logrus.Fields{
+		"user_id": userID,
+		"key_id":  record.ID,
+		"scopes":  scopes,
+	}).Info("api key created")
+	data := apiKeyResponse(record)
+	data["key"] = key
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"data":    data,
+		"message": "创建成功，请妥善保存，Key 不会再次显示",
+	})
+}
+
+// GetAPIKeys 列出当前用户的全部 API Key（不含明文）
+func GetAPIKeys(ctx *gin.Context) {
+	var keys []models.APIKey
+	if err := common.GetDB().WithContext(ctx.Request.Context()).
+		Where("user_id = ?", identity.UserID(ctx)).
+		Order("created_at DESC").
+		Find(&keys).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "数据库查询失败",
+		})
+		return
+	}
+	data := make([]gin.H, len(keys))
+	for i, key := range keys {
+		data[i] = apiKeyResponse(key)
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": data,
+	})
+}
+
+// RevokeAPIKey 作废当前用户的一个 API Key，网关缓存过期后（默认30秒内）失效
+func RevokeAPIKey(ctx *gin.Context) {
+	keyID := common.StrToUint(ctx.Param("id"))
+	userID := identity.UserID(ctx)
+	db := common.GetDB().WithContext(ctx.Request.Context())
+	err := common.RevokeAPIKey(db, userID, keyID)
+	if errors.Is(err, common.ErrAPIKeyInvalid) {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "API Key 不存在或已作废",
+		})
+		return
+	}
+	if err != nil {
+		logger.FromContext(ctx.Request.Context()).WithError(err).Error("api key revoke error")
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "系统异常",
+		})
+		return
+	}
+	logger.FromContext(ctx.Request.Context()).WithFields(logrus.Fields{"user_id": userID, "key_id": keyID}).Info("api key revoked")
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "已作废",
+	})
+}
+
+// POST /internal/api-keys/verify 网关校验请求携带的 API Key
+func VerifyAPIKey(ctx *gin.Context) {
+	var req verifyAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Key == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "缺少 API Key",
+		})
+		return
+	}
+	db := common.GetDB().WithContext(ctx.Request.Context())
+	user, key, err := common.VerifyAPIKey(db, req.Key)
+	switch {
+	case errors.Is(err, common.ErrAPIKeyInvalid), errors.Is(err, common.ErrAPIKeyExpired), errors.Is(err, common.ErrAPIKeyRevoked):
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": "API Key 无效或已过期",
+		})
+		return
+	case err != nil:
+		logger.FromContext(ctx.Request.Context()).WithError(err).Error("api key verify error")
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "系统异常",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"data": gin.H{
+			"key_id":     key.ID,
+			"user_id":    user.ID,
+			"role":       user.Role,
+			"scopes":     common.APIKeyScopeList(key),
+			"expires_at": key.ExpiresAt,
+		},
+	})
+}
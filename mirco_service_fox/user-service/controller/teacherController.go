@@ -42,7 +42,7 @@ func (ct CustomTime) Time() time.Time {
 
 // GetStudentList 获取学生列表
 func GetStudentList(c *gin.Context) {
-	db := global.DB
+	db := global.DB.WithContext(c.Request.Context())
 	var students []models.User
 	if err := db.Model(&models.User{}).
 		Where("Role = ?", "student").Find(&students).Error; err != nil {
@@ -63,7 +63,7 @@ func GetStudentList(c *gin.Context) {
 
 // GetStudentListWithGroup 获取带分组的学生列表
 func GetStudentListWithGroup(c *gin.Context) {
-	db := common.GetDB()
+	db := common.GetDB().WithContext(c.Request.Context())
 
 	// 解析分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -133,13 +133,21 @@ func GetStudentListWithGroup(c *gin.Context) {
 	})
 }
 
+// CreateGroupRequest 创建分组的请求结构体
+type CreateGroupRequest struct {
+	GroupName  string   `json:"group_name" binding:"required"`
+	StudentIDs []string `json:"student_ids" binding:"required"`
+}
+
+// UpdateGroupRequest 更新分组的请求结构体，至少提供一个字段
+type UpdateGroupRequest struct {
+	GroupName  string   `json:"group_name"`
+	StudentIDs []string `json:"student_ids"`
+}
+
 // CreateStudentGroup 创建学生分组
 func CreateStudentGroup(c *gin.Context) {
-	// 创建分组的请求结构体
-	var req struct {
-		GroupName  string   `json:"group_name" binding:"required"`
-		StudentIDs []string `json:"student_ids" binding:"required"`
-	}
+	var req CreateGroupRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
@@ -147,7 +155,7 @@ func CreateStudentGroup(c *gin.Context) {
 		})
 		return
 	}
-	db := global.DB
+	db := global.DB.WithContext(c.Request.Context())
 	studentIDs := make([]uint, 0, len(req.StudentIDs))
 	for _, idStr := range req.StudentIDs {
 		id, err := strconv.ParseUint(idStr, 10, 64)
@@ -239,7 +247,7 @@ func GetStudentGroup(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	offset := (page - 1) * limit
 
-	db := global.DB
+	db := global.DB.WithContext(c.Request.Context())
 
 	// 查询分组总数
 	var total int64
@@ -319,11 +327,8 @@ func GetStudentGroup(c *gin.Context) {
 
 // UpdateStudentGroup 更新分组情况
 func UpdateStudentGroup(c *gin.Context) {
-	var req struct {
-		GroupName  string   `json:"group_name"`
-		StudentIDs []string `json:"student_ids"`
-	}
-	db := global.DB
+	var req UpdateGroupRequest
+	db := global.DB.WithContext(c.Request.Context())
 	groupIDstr := c.Param("group_id")
 	groupID := common.StrToUint(groupIDstr)
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -444,7 +449,7 @@ func DeleteStudentGroup(c *gin.Context) {
 		return
 	}
 
-	db := global.DB
+	db := global.DB.WithContext(c.Request.Context())
 
 	// 检查分组是否存在
 	var group models.Group
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"errors"
+	"lh/common"
+	"lh/global"
+	"lh/models"
+	"net/http"
+	"shared/logger"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RefreshRequest 刷新令牌和退出登录的请求结构体
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// releaseTokens 发放访问令牌和刷新令牌，familyID 为空表示新的登录
+func releaseTokens(db *gorm.DB, user models.User, familyID string) (gin.H, error) {
+	token, err := common.ReleaseToken(user)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := common.ReleaseRefreshToken(db, user.ID, familyID)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"token":         "Bearer " + token,
+		"expires_in":    int(global.Config.Auth.AccessTTL().Seconds()),
+		"refresh_token": refreshToken,
+	}, nil
+}
+
+// Refresh 用刷新令牌换新的访问令牌和刷新令牌，旧的刷新令牌随即失效
+func Refresh(ctx *gin.Context) {
+	var req RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "缺少刷新令牌",
+		})
+		return
+	}
+
+	db := common.GetDB().WithContext(ctx.Request.Context())
+	log := logger.FromContext(ctx.Request.Context())
+	user, refreshToken, err := common.RotateRefreshToken(db, req.RefreshToken)
+	switch {
+	case errors.Is(err, common.ErrRefreshTokenReused):
+		log.Warn("refresh token reuse detected, token family revoked")
+		fallthrough
+	case errors.Is(err, common.ErrRefreshTokenInvalid), errors.Is(err, common.ErrRefreshTokenExpired):
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "登录已失效，请重新登录",
+		})
+		return
+	case err != nil:
+		log.WithError(err).Error("refresh token rotate error")
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "系统异常",
+		})
+		return
+	}
+
+	token, err := common.ReleaseToken(user)
+	if err != nil {
+		log.WithError(err).Error("token generate error")
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "系统异常",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"token":         "Bearer " + token,
+			"expires_in":    int(global.Config.Auth.AccessTTL().Seconds()),
+			"refresh_token": refreshToken,
+		},
+		"message": "刷新成功",
+	})
+}
+
+// Logout 作废请求中的刷新令牌，访问令牌由网关注销
+func Logout(ctx *gin.Context) {
+	var req RefreshRequest
+	_ = ctx.ShouldBindJSON(&req)
+	if req.RefreshToken != "" {
+		db := common.GetDB().WithContext(ctx.Request.Context())
+		if err := common.RevokeRefreshToken(db, req.RefreshToken); err != nil {
+			logger.FromContext(ctx.Request.Context()).WithError(err).Error("refresh token revoke error")
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "退出登录失败",
+			})
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "已退出登录",
+	})
+}
+
+// RevokeUserTokens 作废用户的全部刷新令牌，网关注销用户全部令牌时调用。
+// 返回注销时间点（毫秒）和访问令牌有效期，网关据此注销此前签发的访问令牌，
+// 时间点与令牌的签发时间都取自本服务的时钟
+func RevokeUserTokens(ctx *gin.Context) {
+	userID := common.StrToUint(ctx.Param("id"))
+	if userID == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "用户ID不合法",
+		})
+		return
+	}
+	db := common.GetDB().WithContext(ctx.Request.Context())
+	if err := common.RevokeUserRefreshTokens(db, userID); err != nil {
+		logger.FromContext(ctx.Request.Context()).WithError(err).Error("refresh token revoke error")
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "系统异常",
+		})
+		return
+	}
+	// 刷新令牌作废之后取时间点，作废过程中刷新得到的访问令牌也会被注销
+	revokedBefore := time.Now()
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"revoked_before_ms":  revokedBefore.UnixMilli(),
+			"access_token_ttl_s": int(global.Config.Auth.AccessTTL().Seconds()),
+		},
+		"message": "已作废该用户的全部刷新令牌",
+	})
+}
+
+// JWKS 发布令牌校验公钥，网关定期拉取
+func JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, common.PublicJWKS())
+}
@@ -4,8 +4,9 @@ import (
 	"lh/common"
 	"lh/global"
 	"lh/models"
-	"log"
 	"net/http"
+	"shared/identity"
+	"shared/logger"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -13,7 +14,7 @@ import (
 
 func Register(ctx *gin.Context) {
 
-	db := global.DB
+	db := global.DB.WithContext(ctx.Request.Context())
 
 	//获取参数
 	//此处使用Bind()函数，可以处理不同格式的前端数据
@@ -105,7 +106,7 @@ func Register(ctx *gin.Context) {
 // 登录
 func Login(ctx *gin.Context) {
 
-	db := common.GetDB()
+	db := common.GetDB().WithContext(ctx.Request.Context())
 
 	//获取参数
 	//此处使用Bind()函数，可以处理不同格式的前端数据
@@ -163,29 +164,29 @@ func Login(ctx *gin.Context) {
 		})
 		return
 	}
-	//发放token
-	token, err := common.ReleaseToken(user)
+	//发放访问令牌和刷新令牌
+	data, err := releaseTokens(db, user, "")
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": "系统异常",
 		})
 		//记录下错误
-		log.Printf("token generate error: %v", err)
+		logger.FromContext(ctx.Request.Context()).WithError(err).Error("token generate error")
 		return
 	}
 	//返回结果
 	ctx.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"data":    gin.H{"token": "Bearer " + token},
+		"data":    data,
 		"message": "登录成功",
 	})
 }
 
 func Info(ctx *gin.Context) {
-	userID := common.StrToUint(ctx.GetHeader("X-User-ID"))
+	userID := identity.UserID(ctx)
 	var user models.User
-	if err := global.DB.First(&user, userID).Error; err != nil {
+	if err := global.DB.WithContext(ctx.Request.Context()).First(&user, userID).Error; err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"status":  "error",
 			"message": "用户不存在",
@@ -216,7 +217,7 @@ type UserUpdate struct {
 
 func Update(ctx *gin.Context) {
 	//修改用户信息
-	db := common.GetDB()
+	db := common.GetDB().WithContext(ctx.Request.Context())
 	//绑定User模型并添加额外的字段
 	var requestUser UserUpdate
 	err := ctx.Bind(&requestUser)
@@ -225,9 +226,9 @@ func Update(ctx *gin.Context) {
 	}
 
 	//获取用户信息
-	userId := common.StrToUint(ctx.GetHeader("X-User-ID"))
+	userId := identity.UserID(ctx)
 	var userr models.User
-	if err := global.DB.First(&userr, userId).Error; err != nil {
+	if err := global.DB.WithContext(ctx.Request.Context()).First(&userr, userId).Error; err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"status":  "error",
 			"message": "用户不存在",
@@ -352,6 +353,12 @@ func Update(ctx *gin.Context) {
 		AvatarUrl: requestUser.AvatarUrl,
 		Email:     requestUser.Email,
 	})
+	//修改了密码则作废已发放的刷新令牌，其他设备需要重新登录
+	if requestUser.Password != userr.Password {
+		if err := common.RevokeUserRefreshTokens(db, userr.ID); err != nil {
+			logger.FromContext(ctx.Request.Context()).WithError(err).Error("refresh token revoke error")
+		}
+	}
 	//返回结果
 	ctx.JSON(http.StatusOK, gin.H{
 		"code":     200,
@@ -366,7 +373,7 @@ func Update(ctx *gin.Context) {
 func GetUserByID(ctx *gin.Context) {
 	id := ctx.Param("id")
 	var user models.User
-	if err := global.DB.First(&user, id).Error; err != nil {
+	if err := global.DB.WithContext(ctx.Request.Context()).First(&user, id).Error; err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"status":  "error",
 			"message": "用户不存在",
@@ -7,6 +7,7 @@ import (
 	"lh/global"
 	"os"
 	"path"
+	"time"
 )
 
 const (
@@ -53,17 +54,39 @@ func (t *LogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	}
 	return b.Bytes(), nil
 }
+
+// 日志中的 service 字段
+const serviceName = "user-service"
+
+// newFormatter 默认输出 JSON，logger.format 为 text 时使用上面的彩色文本格式，方便本地调试
+func newFormatter() logrus.Formatter {
+	if global.Config.Logger.Format == "text" {
+		return &LogFormatter{}
+	}
+	return &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano}
+}
+
+type serviceHook string
+
+func (h serviceHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h serviceHook) Fire(entry *logrus.Entry) error {
+	entry.Data["service"] = string(h)
+	return nil
+}
+
 func InitLogger() *logrus.Logger {
 	mlog := logrus.New()
 	mlog.SetOutput(os.Stdout)
 	mlog.SetReportCaller(global.Config.Logger.ShowLine) //设置是否显示行号
-	mlog.SetFormatter(&LogFormatter{})
+	mlog.SetFormatter(newFormatter())
 	level, err := logrus.ParseLevel(global.Config.Logger.Level)
 	if err != nil {
 		fmt.Println("日志级别错误，使用默认级别")
 		level = logrus.DebugLevel
 	}
 	mlog.SetLevel(level)
+	mlog.AddHook(serviceHook(serviceName))
 	InitDefaultLogger()
 	return mlog
 }
@@ -71,7 +94,7 @@ func InitDefaultLogger() {
 	//全局logger
 	logrus.SetOutput(os.Stdout)
 	logrus.SetReportCaller(global.Config.Logger.ShowLine)
-	logrus.SetFormatter(&LogFormatter{})
+	logrus.SetFormatter(newFormatter())
 	level, err := logrus.ParseLevel(global.Config.Logger.Level)
 	if err != nil {
 		fmt.Println("日志级别错误，使用默认级别")
@@ -1,9 +1,18 @@
 package main
 
 import (
+	"context"
+	"lh/common"
 	"lh/core"
 	"lh/global"
 	"lh/routers"
+	"net/http"
+	"shared/identity"
+	"shared/logger"
+	"shared/server"
+	"shared/tracing"
+
+	"github.com/gin-gonic/gin"
 )
 
 func main() {
@@ -11,9 +20,46 @@ func main() {
 	core.InitConf()
 	// 初始化日志
 	global.Log = core.InitLogger()
+	logger.Log = global.Log
+	// 初始化链路追踪
+	shutdownTracing, err := tracing.Init(context.Background(), "user-service")
+	if err != nil {
+		global.Log.Fatalf("初始化链路追踪失败: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+	// 身份校验失败时按本服务的 {"code","message"} 格式返回
+	identity.ErrorBody = func(status int, message string) any {
+		return gin.H{"code": status, "message": message}
+	}
+	// 内部身份断言的签名密钥，没有时无法识别用户，直接退出
+	if err := identity.Init(); err != nil {
+		global.Log.Fatalf("初始化身份校验失败: %v", err)
+	}
+	// 网关注销令牌、通知服务校验用户时调用内部接口
+	if err := identity.AllowCallers("gateway", "notification-service"); err != nil {
+		global.Log.Fatalf("初始化服务令牌失败: %v", err)
+	}
+	// 加载令牌签名密钥，没有可用密钥时无法签发令牌，直接退出
+	if err := common.LoadSigningKeys(global.Config.Auth); err != nil {
+		global.Log.Fatalf("加载令牌签名密钥失败: %v", err)
+	}
 	//连接数据库
 	global.DB = core.InitGorm()
 	router := routers.InitRouter()
 
-	router.Run(global.Config.System.Addr()) // listen and serve on
+	// 收到 SIGTERM 后等正在处理的请求结束，再关闭数据库连接池
+	srv := &http.Server{Addr: global.Config.System.Addr(), Handler: router}
+	opts := server.Options{GracePeriod: global.Config.System.GracePeriod(), DrainDelay: global.Config.System.DrainDelay()}
+	if err := server.Run(srv, opts, closeDB); err != nil {
+		global.Log.Fatalf("启动服务失败: %v", err)
+	}
+}
+
+// closeDB 关闭数据库连接池
+func closeDB() error {
+	sqlDB, err := global.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
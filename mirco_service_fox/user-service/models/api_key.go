@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// APIKey 脚本等机器客户端使用的 API Key，只保存哈希，明文只在创建时返回一次。
+// 请求携带 X-API-Key 时网关按所属用户的身份转发，并且只放行 Scopes 覆盖的接口
+type APIKey struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"size:64;not null" json:"name"`
+	Prefix     string     `gorm:"size:16;not null" json:"prefix"` //明文的前几位，方便用户辨认是哪个 Key
+	KeyHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes     string     `gorm:"size:255;not null" json:"-"` //空格分隔
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
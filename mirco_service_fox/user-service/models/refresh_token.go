@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RefreshToken 刷新令牌，只保存哈希。
+// 同一次登录轮换出来的令牌属于同一个 FamilyID，发现旧令牌被重复使用时整个家族一起作废。
+type RefreshToken struct {
+	ID        uint   `gorm:"primarykey"`
+	TokenHash string `gorm:"size:64;not null;uniqueIndex"`
+	UserID    uint   `gorm:"not null;index"`
+	FamilyID  string `gorm:"size:36;not null;index"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time //已轮换，再次出现说明令牌泄露
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
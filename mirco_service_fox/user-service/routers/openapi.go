@@ -0,0 +1,238 @@
+package routers
+
+import (
+	"lh/controller"
+	"net/http"
+	"shared/openapi"
+	"time"
+)
+
+// 下面的结构体只用于生成文档，描述接口中用 gin.H 拼出的请求参数和响应
+
+type pageQuery struct {
+	Page  int `form:"page,default=1"`
+	Limit int `form:"limit,default=10"`
+}
+
+type pagination struct {
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"`
+	Total int64 `json:"total"`
+}
+
+type codeMessage struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type registerRequest struct {
+	Name      string `json:"name" doc:"用户名"`
+	Telephone string `json:"telephone" doc:"11 位手机号"`
+	Password  string `json:"password" doc:"不少于 6 位"`
+	Role      string `json:"role" doc:"student | teacher"`
+}
+
+type loginRequest struct {
+	Name      string `json:"name" doc:"用户名，和手机号二选一"`
+	Telephone string `json:"telephone" doc:"手机号，和用户名二选一"`
+	Password  string `json:"password"`
+}
+
+type userResult struct {
+	Code     int    `json:"code"`
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Message  string `json:"message"`
+}
+
+type tokens struct {
+	Token        string `json:"token" doc:"带 Bearer 前缀的访问令牌"`
+	ExpiresIn    int    `json:"expires_in" doc:"访问令牌有效期（秒）"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type tokenResult struct {
+	Code    int    `json:"code"`
+	Data    tokens `json:"data"`
+	Message string `json:"message"`
+}
+
+type profile struct {
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Telephone string    `json:"telephone"`
+	Role      string    `json:"role"`
+	AvatarURL string    `json:"avatar_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type studentIDList struct {
+	StudentIDs []string `json:"student_ids"`
+}
+
+type groupStudent struct {
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	Telephone string    `json:"telephone"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	GroupIDs  []string  `json:"group_ids"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type groupStudentList struct {
+	Code       int            `json:"code"`
+	Data       []groupStudent `json:"data"`
+	Pagination pagination     `json:"pagination"`
+	Message    string         `json:"message"`
+}
+
+type group struct {
+	GroupID      string   `json:"group_id"`
+	GroupName    string   `json:"group_name"`
+	StudentCount int      `json:"student_count"`
+	StudentIDs   []string `json:"student_ids"`
+}
+
+type groupList struct {
+	Code       int        `json:"code"`
+	Data       []group    `json:"data"`
+	Pagination pagination `json:"pagination"`
+	Message    string     `json:"message"`
+}
+
+type createdGroup struct {
+	GroupID    string   `json:"group_id"`
+	GroupName  string   `json:"group_name"`
+	StudentIDs []string `json:"student_ids"`
+}
+
+type createdGroupResult struct {
+	Code    int          `json:"code"`
+	Data    createdGroup `json:"data"`
+	Message string       `json:"message"`
+}
+
+type updatedGroup struct {
+	GroupID    string   `json:"group_id"`
+	GroupName  string   `json:"group_name"`
+	StudentIDs []string `json:"student_ids"`
+	UpdatedAt  string   `json:"updated_at" doc:"RFC3339 时间"`
+}
+
+type updatedGroupResult struct {
+	Code    int          `json:"code"`
+	Data    updatedGroup `json:"data"`
+	Message string       `json:"message"`
+}
+
+type apiKey struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" doc:"明文的前几位，用于辨认"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type apiKeyList struct {
+	Code int      `json:"code"`
+	Data []apiKey `json:"data"`
+}
+
+type createdAPIKey struct {
+	apiKey
+	Key string `json:"key" doc:"明文，只在创建时返回一次"`
+}
+
+type createdAPIKeyResult struct {
+	Code    int           `json:"code"`
+	Data    createdAPIKey `json:"data"`
+	Message string        `json:"message"`
+}
+
+type apiKeyScopes struct {
+	Code int               `json:"code"`
+	Data map[string]string `json:"data" doc:"权限范围 -> 说明"`
+}
+
+// Spec 生成本服务对外接口的 OpenAPI 文档，修改接口时同步更新
+func Spec() *openapi.Spec {
+	spec := openapi.New("user-service", "1.0.0")
+
+	spec.Add(openapi.Route{
+		Method: http.MethodPost, Path: "/api/auth/register", Tag: "auth",
+		Summary: "注册", Body: registerRequest{}, Response: userResult{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodPost, Path: "/api/auth/login", Tag: "auth",
+		Summary: "登录", Body: loginRequest{}, Response: tokenResult{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodPost, Path: "/api/auth/refresh", Tag: "auth",
+		Summary: "刷新令牌", Description: "用刷新令牌换新的访问令牌和刷新令牌，旧的刷新令牌随即失效",
+		Body: controller.RefreshRequest{}, Response: tokenResult{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodPost, Path: "/api/auth/logout", Tag: "auth",
+		Summary: "退出登录", Description: "作废请求中的刷新令牌，访问令牌由网关注销",
+		Body: controller.RefreshRequest{}, Response: codeMessage{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/auth/profile", Tag: "auth",
+		Summary: "当前用户信息", Response: profile{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodPut, Path: "/api/auth/update", Tag: "auth",
+		Summary: "修改当前用户信息", Description: "只修改传了的字段，修改密码时需要 old_password",
+		Body: controller.UserUpdate{}, Response: userResult{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/student_list", Tag: "teacher",
+		Summary: "全部学生的 ID", Response: studentIDList{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/teacher/students", Tag: "teacher",
+		Summary: "学生列表，带所在分组", Query: pageQuery{}, Response: groupStudentList{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodPost, Path: "/api/teacher/groups", Tag: "teacher",
+		Summary: "创建学生分组", Body: controller.CreateGroupRequest{},
+		Status: http.StatusCreated, Response: createdGroupResult{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/teacher/groups", Tag: "teacher",
+		Summary: "分组列表", Query: pageQuery{}, Response: groupList{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodPut, Path: "/api/teacher/groups/:group_id", Tag: "teacher",
+		Summary: "修改分组", Description: "student_ids 会替换分组中原有的学生",
+		Params: map[string]string{"group_id": "分组 ID"},
+		Body:   controller.UpdateGroupRequest{}, Response: updatedGroupResult{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodDelete, Path: "/api/teacher/groups/:group_id", Tag: "teacher",
+		Summary: "删除分组", Params: map[string]string{"group_id": "分组 ID"}, Response: codeMessage{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/teacher/api-keys/scopes", Tag: "api-keys",
+		Summary: "可以申请的权限范围", Response: apiKeyScopes{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodGet, Path: "/api/teacher/api-keys", Tag: "api-keys",
+		Summary: "当前用户的 API Key", Response: apiKeyList{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodPost, Path: "/api/teacher/api-keys", Tag: "api-keys",
+		Summary: "创建 API Key", Body: controller.CreateAPIKeyRequest{}, Response: createdAPIKeyResult{},
+	})
+	spec.Add(openapi.Route{
+		Method: http.MethodDelete, Path: "/api/teacher/api-keys/:id", Tag: "api-keys",
+		Summary: "作废 API Key", Params: map[string]string{"id": "API Key 的 ID"}, Response: codeMessage{},
+	})
+	return spec
+}
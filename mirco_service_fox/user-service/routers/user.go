@@ -2,6 +2,7 @@ package routers
 
 import (
 	"lh/controller"
+	"shared/identity"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,18 +13,27 @@ func CollectRoutes(r *gin.Engine) *gin.Engine {
 	user.POST("/register", controller.Register)
 	//登录
 	user.POST("/login", controller.Login)
+	//刷新令牌
+	user.POST("/refresh", controller.Refresh)
+	//退出登录（网关注销访问令牌后转发过来）
+	user.POST("/logout", controller.Logout)
 	//返回用户信息
 	user.GET("/profile", controller.Info)
 	//修改用户信息
 	user.PUT("/update", controller.Update)
 	r.GET("/api/student_list", controller.GetStudentList)
-	TeacherGroup := r.Group("/api/teacher")
+	//令牌校验公钥
+	r.GET("/.well-known/jwks.json", controller.JWKS)
+	TeacherGroup := r.Group("/api/teacher", identity.RequireRole("teacher"))
 	{
 		ExperimentRoutes_Teacher(TeacherGroup) // 挂载实验路由
 	}
-	internal := r.Group("/internal")
+	//服务间调用的内部接口，网关不对外暴露，只允许持有服务令牌的调用方访问
+	internal := r.Group("/internal", identity.RequireService())
 	{
 		internal.GET("/users/:id", controller.GetUserByID)
+		internal.POST("/users/:id/revoke-tokens", controller.RevokeUserTokens)
+		internal.POST("/api-keys/verify", controller.VerifyAPIKey)
 	}
 
 	return r
@@ -36,4 +46,9 @@ func ExperimentRoutes_Teacher(r *gin.RouterGroup) {
 	r.GET("/groups", controller.GetStudentGroup)
 	r.PUT("/groups/:group_id", controller.UpdateStudentGroup)
 	r.DELETE("/groups/:group_id", controller.DeleteStudentGroup)
+	//脚本使用的 API Key
+	r.GET("/api-keys/scopes", controller.GetAPIKeyScopes)
+	r.GET("/api-keys", controller.GetAPIKeys)
+	r.POST("/api-keys", controller.CreateAPIKey)
+	r.DELETE("/api-keys/:id", controller.RevokeAPIKey)
 }
@@ -26,9 +26,10 @@ export default function LoginPage() {
         throw new Error(responseData.message || '登录失败');
       }
   
-      // 3. 存储令牌
-      const { token } = responseData.data;
+      // 3. 存储令牌，访问令牌过期后用刷新令牌换新的（见 utils/axios.js）
+      const { token, refresh_token: refreshToken } = responseData.data;
       localStorage.setItem('token', token);
+      localStorage.setItem('refresh_token', refreshToken);
   
       // alert(token)
       // 4. 获取用户信息
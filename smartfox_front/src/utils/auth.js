@@ -1,7 +1,21 @@
 // src/utils/auth.js
-export const logout = () => {
+import axios from 'axios';
+import instance from './axios';
+
+export const logout = async () => {
+    // 先让网关注销访问令牌、作废刷新令牌，失败（如令牌已过期、网络错误）时照常登出
+    // 不经过 instance 的拦截器，避免 401 时再次刷新、登出
+    const token = localStorage.getItem('token');
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (token || refreshToken) {
+      await axios.post(`${instance.defaults.baseURL}/auth/logout`, { refresh_token: refreshToken }, {
+        headers: token ? { Authorization: token } : {}
+      }).catch(() => {});
+    }
+
     // 清除所有认证相关存储
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
     localStorage.removeItem('user_role');
     localStorage.removeItem('user_id');
     
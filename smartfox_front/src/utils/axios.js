@@ -24,10 +24,48 @@ instance.interceptors.request.use(
   }
 );
 
+// 登录前调用的认证接口返回 401 时不刷新令牌
+const isAuthRequest = config => /(^|\/)auth\/(login|register|refresh)$/.test(config.url || '');
+
+// 正在进行的刷新请求，同时失效的多个请求共用一次刷新，避免旧的刷新令牌被重复使用
+let refreshing = null;
+
+// 用刷新令牌换新的访问令牌，刷新令牌同时轮换，两者都保存下来
+const refreshToken = () => {
+  if (!refreshing) {
+    const token = localStorage.getItem('refresh_token');
+    refreshing = (token
+      ? axios.post(`${instance.defaults.baseURL}/auth/refresh`, { refresh_token: token })
+      : Promise.reject(new Error('no refresh token')))
+      .then(({ data }) => {
+        localStorage.setItem('token', data.data.token);
+        localStorage.setItem('refresh_token', data.data.refresh_token);
+        return data.data.token;
+      })
+      .finally(() => {
+        refreshing = null;
+      });
+  }
+  return refreshing;
+};
+
 // 响应拦截器 - 统一处理错误
 instance.interceptors.response.use(
   response => response.data,
-  error => {
+  async error => {
+    const original = error.config;
+    // 访问令牌过期：刷新一次后重发原请求，刷新失败才登出
+    if (error.response && error.response.status === 401 && original && !original._retried && !isAuthRequest(original)) {
+      original._retried = true;
+      try {
+        const token = await refreshToken();
+        original.headers.Authorization = token;
+        return instance(original);
+      } catch (refreshError) {
+        logout();
+        return Promise.reject(error);
+      }
+    }
     if (error.response) {
       switch (error.response.status) {
         case 401: